@@ -2,7 +2,9 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/data"
@@ -17,23 +19,113 @@ type FrameData interface {
 	GetTableFrameDefinition() data.Frame
 
 	getLabels() map[string]string
+	missingFields() []string
+	defaultedFields() []string
+}
+
+var errMissingTimestamp = errors.New("missing timestamp")
+
+// GetLabels returns the labels that identify the series a FrameData item belongs to
+func GetLabels(frameData FrameData) map[string]string {
+	return frameData.getLabels()
+}
+
+// MissingFields returns the names of the fields frameData needs to be turned into frame rows which the API
+// left out. Items with missing fields should be skipped, the accessors fall back to zero values for them
+func MissingFields(frameData FrameData) []string {
+	return frameData.missingFields()
+}
+
+// DefaultedFields returns the names of the optional fields the API left out, for which the accessors
+// substitute zero values instead of the item being skipped
+func DefaultedFields(frameData FrameData) []string {
+	return frameData.defaultedFields()
+}
+
+// labeledFrameData decorates a FrameData item with extra labels which also become part of its series key
+type labeledFrameData struct {
+	FrameData
+	labels map[string]string
+}
+
+// WithLabels returns frameData with the extra labels attached to its graph series
+func WithLabels(frameData FrameData, labels map[string]string) FrameData {
+	return &labeledFrameData{FrameData: frameData, labels: labels}
+}
+
+func (l *labeledFrameData) GetKey() string {
+	names := make([]string, 0, len(l.labels))
+	for name := range l.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	key := l.FrameData.GetKey()
+	for _, name := range names {
+		key = fmt.Sprintf("%s-%s", key, l.labels[name])
+	}
+	return key
+}
+
+func (l *labeledFrameData) GetGraphFrameDefinition() data.Frame {
+	frame := l.FrameData.GetGraphFrameDefinition()
+	for _, field := range frame.Fields {
+		if field.Labels == nil {
+			continue
+		}
+		for name, value := range l.labels {
+			field.Labels[name] = value
+		}
+	}
+	return frame
+}
+
+func (l *labeledFrameData) getLabels() map[string]string {
+	labels := l.FrameData.getLabels()
+	for name, value := range l.labels {
+		labels[name] = value
+	}
+	return labels
+}
+
+// columnFrameData decorates a FrameData item with an extra string column in its table frame. Unlike labels the
+// column does not take part in the series key
+type columnFrameData struct {
+	FrameData
+	name  string
+	value string
+}
+
+// WithTableColumn returns frameData with an extra string column holding value appended to its table rows
+func WithTableColumn(frameData FrameData, name string, value string) FrameData {
+	return &columnFrameData{FrameData: frameData, name: name, value: value}
+}
+
+func (c *columnFrameData) GetTableVals(timestamp time.Time) []any {
+	return append(c.FrameData.GetTableVals(timestamp), c.value)
+}
+
+func (c *columnFrameData) GetTableFrameDefinition() data.Frame {
+	frame := c.FrameData.GetTableFrameDefinition()
+	frame.Fields = append(frame.Fields, data.NewField(c.name, nil, []string{}))
+	return frame
 }
 
 // Monitor Errors
 func (errorCount *MonitorErrorCount) GetTimestamp() (time.Time, error) {
-	return time.Parse(time.RFC3339, *errorCount.Timestamp)
+	return parseTimestamp(errorCount.Timestamp)
 }
 
 func (errorCount *MonitorErrorCount) GetGraphVals(timestamp time.Time) []any {
-	return []any{timestamp, int64(*errorCount.Count)}
+	return []any{timestamp, errorCount.count()}
 }
 
 func (errorCount *MonitorErrorCount) GetTableVals(timestamp time.Time) []any {
-	return []any{timestamp, int64(*errorCount.Count), *errorCount.Instance, *errorCount.Check, *errorCount.MonitorLogicalName}
+	return []any{timestamp, errorCount.count(), valueOrZero(errorCount.Instance), valueOrZero(errorCount.Check), valueOrZero(errorCount.MonitorLogicalName), errorCount.errorMessage()}
 }
 
 func (errorCount *MonitorErrorCount) GetKey() string {
-	return fmt.Sprintf("%s-%s-%s", *errorCount.Instance, *errorCount.Check, *errorCount.MonitorLogicalName)
+	return fmt.Sprintf("%s-%s-%s", valueOrZero(errorCount.Instance), valueOrZero(errorCount.Check), valueOrZero(errorCount.MonitorLogicalName))
 }
 
 func (errorCount *MonitorErrorCount) GetGraphFrameDefinition() data.Frame {
@@ -57,6 +149,7 @@ func (errorCount *MonitorErrorCount) GetTableFrameDefinition() data.Frame {
 			data.NewField("instance", nil, []string{}),
 			data.NewField("check", nil, []string{}),
 			data.NewField("monitor", nil, []string{}),
+			data.NewField("error_message", nil, []string{}),
 		},
 		Meta: &data.FrameMeta{
 			Type:                   data.FrameTypeTimeSeriesWide,
@@ -65,32 +158,61 @@ func (errorCount *MonitorErrorCount) GetTableFrameDefinition() data.Frame {
 	}
 }
 
+// count returns the error count as the int64 of the count fields. The count is decoded from its JSON literal
+// into an int, never through a float64, and int is at most 64 bits wide, so large counts are kept exactly
+func (errorCount *MonitorErrorCount) count() int64 {
+	return int64(valueOrZero(errorCount.Count))
+}
+
 func (errorCount *MonitorErrorCount) getLabels() map[string]string {
-	return map[string]string{"instance": *errorCount.Instance, "check": *errorCount.Check, "monitor": *errorCount.MonitorLogicalName}
+	return map[string]string{"instance": valueOrZero(errorCount.Instance), "check": valueOrZero(errorCount.Check), "monitor": valueOrZero(errorCount.MonitorLogicalName)}
+}
+
+func (errorCount *MonitorErrorCount) missingFields() []string {
+	return missing(map[string]bool{
+		"timestamp":            errorCount.Timestamp == nil,
+		"count":                errorCount.Count == nil,
+		"instance":             errorCount.Instance == nil,
+		"check":                errorCount.Check == nil,
+		"monitor_logical_name": errorCount.MonitorLogicalName == nil,
+	})
+}
+
+func (errorCount *MonitorErrorCount) defaultedFields() []string {
+	return []string{}
+}
+
+// The API does not expose the underlying error message yet, so derive one from the failing check
+func (errorCount *MonitorErrorCount) errorMessage() string {
+	if errorCount.Check == nil || *errorCount.Check == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s failed", *errorCount.Check)
 }
 
 // Monitor Telemetry
 func (te *MonitorTelemetry) GetTimestamp() (time.Time, error) {
-	return time.Parse(time.RFC3339, *te.Timestamp)
+	return parseTimestamp(te.Timestamp)
 }
 
+// A telemetry sample without a value is kept as a null, so Grafana renders a gap rather than a zero
 func (te *MonitorTelemetry) GetGraphVals(timestamp time.Time) []any {
-	return []any{timestamp, *te.Value}
+	return []any{timestamp, te.Value}
 }
 
 func (te *MonitorTelemetry) GetTableVals(timestamp time.Time) []any {
-	return []any{timestamp, *te.Value, *te.Instance, *te.Check, *te.MonitorLogicalName}
+	return []any{timestamp, te.Value, valueOrZero(te.Instance), valueOrZero(te.Check), valueOrZero(te.MonitorLogicalName)}
 }
 
 func (te *MonitorTelemetry) GetKey() string {
-	return fmt.Sprintf("%s-%s-%s", *te.Instance, *te.Check, *te.MonitorLogicalName)
+	return fmt.Sprintf("%s-%s-%s", valueOrZero(te.Instance), valueOrZero(te.Check), valueOrZero(te.MonitorLogicalName))
 }
 
 func (te *MonitorTelemetry) GetGraphFrameDefinition() data.Frame {
 	return data.Frame{
 		Fields: []*data.Field{
 			data.NewField("time", nil, make([]time.Time, 0)),
-			data.NewField("response time (ms)", te.getLabels(), make([]float32, 0)),
+			data.NewField("response time (ms)", te.getLabels(), make([]*float32, 0)),
 		},
 		Meta: &data.FrameMeta{
 			Type:                   data.FrameTypeTimeSeriesMulti,
@@ -103,7 +225,7 @@ func (te *MonitorTelemetry) GetTableFrameDefinition() data.Frame {
 	return data.Frame{
 		Fields: []*data.Field{
 			data.NewField("time", nil, []time.Time{}),
-			data.NewField("response time (ms)", nil, []float32{}),
+			data.NewField("response time (ms)", nil, []*float32{}),
 			data.NewField("instance", nil, []string{}),
 			data.NewField("check", nil, []string{}),
 			data.NewField("monitor", nil, []string{}),
@@ -116,24 +238,39 @@ func (te *MonitorTelemetry) GetTableFrameDefinition() data.Frame {
 }
 
 func (te *MonitorTelemetry) getLabels() map[string]string {
-	return map[string]string{"instance": *te.Instance, "check": *te.Check, "monitor": *te.MonitorLogicalName}
+	return map[string]string{"instance": valueOrZero(te.Instance), "check": valueOrZero(te.Check), "monitor": valueOrZero(te.MonitorLogicalName)}
+}
+
+// Telemetry is only skipped without a timestamp. A missing value is a null and missing labels are left empty
+func (te *MonitorTelemetry) missingFields() []string {
+	return missing(map[string]bool{
+		"timestamp": te.Timestamp == nil,
+	})
+}
+
+func (te *MonitorTelemetry) defaultedFields() []string {
+	return missing(map[string]bool{
+		"instance":             te.Instance == nil,
+		"check":                te.Check == nil,
+		"monitor_logical_name": te.MonitorLogicalName == nil,
+	})
 }
 
 // Status Page Changes
 func (spc *StatusPageComponentChange) GetTimestamp() (time.Time, error) {
-	return time.Parse(time.RFC3339, *spc.Timestamp)
+	return parseTimestamp(spc.Timestamp)
 }
 
 func (spc *StatusPageComponentChange) GetGraphVals(timestamp time.Time) []any {
-	return []any{timestamp, spcStatusToInt(*spc.Status)}
+	return []any{timestamp, spcStatusToInt(valueOrZero(spc.Status))}
 }
 
 func (spc *StatusPageComponentChange) GetTableVals(timestamp time.Time) []any {
-	return []any{timestamp, spcStatusToInt(*spc.Status), *spc.Component, *spc.MonitorLogicalName}
+	return []any{timestamp, spcStatusToInt(valueOrZero(spc.Status)), valueOrZero(spc.Component), valueOrZero(spc.MonitorLogicalName)}
 }
 
 func (spc *StatusPageComponentChange) GetKey() string {
-	return fmt.Sprintf("%s-%s", *spc.Component, *spc.MonitorLogicalName)
+	return fmt.Sprintf("%s-%s", valueOrZero(spc.Component), valueOrZero(spc.MonitorLogicalName))
 }
 
 func (spc *StatusPageComponentChange) GetGraphFrameDefinition() data.Frame {
@@ -165,34 +302,97 @@ func (spc *StatusPageComponentChange) GetTableFrameDefinition() data.Frame {
 }
 
 func (spc *StatusPageComponentChange) getLabels() map[string]string {
-	return map[string]string{"component": *spc.Component, "monitor": *spc.MonitorLogicalName}
+	return map[string]string{"component": valueOrZero(spc.Component), "monitor": valueOrZero(spc.MonitorLogicalName)}
+}
+
+func (spc *StatusPageComponentChange) missingFields() []string {
+	return missing(map[string]bool{
+		"timestamp":            spc.Timestamp == nil,
+		"status":               spc.Status == nil,
+		"component":            spc.Component == nil,
+		"monitor_logical_name": spc.MonitorLogicalName == nil,
+	})
+}
+
+func (spc *StatusPageComponentChange) defaultedFields() []string {
+	return []string{}
+}
+
+// StatusPageStatusUp is the numeric value of the statuses which mean a component is up
+const StatusPageStatusUp int8 = 2
+
+// StatusPageStatusMaintenance is the numeric value of the statuses which mean a component is under maintenance
+const StatusPageStatusMaintenance int8 = 1
+
+// StatusPageStatus returns the numeric value of the status of spc, as used in its frames
+func StatusPageStatus(spc *StatusPageComponentChange) int8 {
+	return spcStatusToInt(valueOrZero(spc.Status))
+}
+
+// statusPageStatuses maps the upstream status page statuses to the numeric values of the frames. Statuses
+// which are not listed map to 0, unknown
+var statusPageStatuses = map[string]int8{
+	"under_maintenance":    1,
+	"up":                   2,
+	"operational":          2,
+	"Good":                 2,
+	"Information":          2,
+	"NotApplicable":        2,
+	"Advisory":             2,
+	"Healthy":              2,
+	"available":            2,
+	"information":          2,
+	"Degraded":             3,
+	"Warning":              3,
+	"degraded":             3,
+	"disruption":           3,
+	"down":                 4,
+	"Disruption":           4,
+	"Critical":             4,
+	"outage":               4,
+	"degraded_performance": 4,
+	"major_outage":         4,
+	"partial_outage":       4,
+}
+
+// StatusPageStatuses returns a copy of the mapping of upstream status page statuses to numeric values
+func StatusPageStatuses() map[string]int8 {
+	statuses := make(map[string]int8, len(statusPageStatuses))
+	for status, value := range statusPageStatuses {
+		statuses[status] = value
+	}
+	return statuses
 }
 
 // Map statuses to numeric values for Frames
 func spcStatusToInt(status string) int8 {
-	statuses := map[string]int8{
-		"under_maintenance":    1,
-		"up":                   2,
-		"operational":          2,
-		"Good":                 2,
-		"Information":          2,
-		"NotApplicable":        2,
-		"Advisory":             2,
-		"Healthy":              2,
-		"available":            2,
-		"information":          2,
-		"Degraded":             3,
-		"Warning":              3,
-		"degraded":             3,
-		"disruption":           3,
-		"down":                 4,
-		"Disruption":           4,
-		"Critical":             4,
-		"outage":               4,
-		"degraded_performance": 4,
-		"major_outage":         4,
-		"partial_outage":       4,
+	return statusPageStatuses[status]
+}
+
+func parseTimestamp(timestamp *string) (time.Time, error) {
+	if timestamp == nil {
+		return time.Time{}, errMissingTimestamp
+	}
+	return time.Parse(time.RFC3339, *timestamp)
+}
+
+// valueOrZero dereferences an optional API field, returning the zero value when the API left it out
+func valueOrZero[T any](value *T) T {
+	if value == nil {
+		var zero T
+		return zero
+	}
+	return *value
+}
+
+// missing returns the sorted names of the fields flagged as missing
+func missing(fields map[string]bool) []string {
+	names := make([]string, 0)
+	for name, isMissing := range fields {
+		if isMissing {
+			names = append(names, name)
+		}
 	}
-	result := statuses[status]
-	return result
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMonitorErrorCountErrorMessage(t *testing.T) {
+	check := "CreateBucket"
+	empty := ""
+	tests := []struct {
+		name       string
+		errorCount MonitorErrorCount
+		want       string
+	}{
+		{name: "derives the message from the check", errorCount: MonitorErrorCount{Check: &check}, want: "CreateBucket failed"},
+		{name: "returns an empty message when the check is absent", errorCount: MonitorErrorCount{}, want: ""},
+		{name: "returns an empty message when the check is empty", errorCount: MonitorErrorCount{Check: &empty}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.errorCount.errorMessage(); got != tt.want {
+				t.Errorf("errorMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingFields(t *testing.T) {
+	timestamp := "2022-12-07T18:28:06Z"
+	name := "name"
+	count := 1
+	value := float32(100)
+	tests := []struct {
+		name      string
+		frameData FrameData
+		want      []string
+	}{
+		{
+			name:      "complete error count",
+			frameData: &MonitorErrorCount{Check: &name, Count: &count, Instance: &name, MonitorLogicalName: &name, Timestamp: &timestamp},
+			want:      []string{},
+		},
+		{
+			name:      "error count without count and instance",
+			frameData: &MonitorErrorCount{Check: &name, MonitorLogicalName: &name, Timestamp: &timestamp},
+			want:      []string{"count", "instance"},
+		},
+		{
+			name:      "telemetry without value is complete, the value being null",
+			frameData: &MonitorTelemetry{Check: &name, Instance: &name, MonitorLogicalName: &name, Timestamp: &timestamp},
+			want:      []string{},
+		},
+		{
+			name:      "status page change without anything",
+			frameData: &StatusPageComponentChange{},
+			want:      []string{"component", "monitor_logical_name", "status", "timestamp"},
+		},
+		{
+			name:      "labeled error count without monitor",
+			frameData: WithLabels(&MonitorErrorCount{Check: &name, Count: &count, Instance: &name, Timestamp: &timestamp}, map[string]string{"shared": "true"}),
+			want:      []string{"monitor_logical_name"},
+		},
+		{
+			name:      "telemetry without labels is still complete",
+			frameData: &MonitorTelemetry{Timestamp: &timestamp, Value: &value},
+			want:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MissingFields(tt.frameData); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MissingFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonitorTelemetryDefaultedFields(t *testing.T) {
+	timestamp := "2022-12-07T18:28:06Z"
+	value := float32(100)
+	te := MonitorTelemetry{Timestamp: &timestamp, Value: &value}
+
+	if got, want := DefaultedFields(&te), []string{"check", "instance", "monitor_logical_name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DefaultedFields() = %v, want %v", got, want)
+	}
+	if got, want := GetLabels(&te), map[string]string{"instance": "", "check": "", "monitor": ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetLabels() = %v, want %v", got, want)
+	}
+}
+
+// 2^53 + 1 has no exact float64, so any float conversion on the way would change it
+func TestMonitorErrorCountLargeCount(t *testing.T) {
+	if strconv.IntSize < 64 {
+		t.Skip("counts past 32 bits do not decode into the generated int field on this platform")
+	}
+	var errorCount MonitorErrorCount
+	if err := json.Unmarshal([]byte(`{"count": 9007199254740993, "timestamp": "2022-12-07T18:28:06Z"}`), &errorCount); err != nil {
+		t.Fatal(err)
+	}
+	want := int64(9007199254740993)
+
+	graph := errorCount.GetGraphFrameDefinition()
+	graph.AppendRow(errorCount.GetGraphVals(time.Time{})...)
+	if got := graph.Fields[1].At(0); got != want {
+		t.Errorf("graph count = %v, want %d", got, want)
+	}
+
+	table := errorCount.GetTableFrameDefinition()
+	table.AppendRow(errorCount.GetTableVals(time.Time{})...)
+	if got := table.Fields[1].At(0); got != want {
+		t.Errorf("table count = %v, want %d", got, want)
+	}
+}
+
+func TestMonitorTelemetryNullValue(t *testing.T) {
+	timestamp := "2022-12-07T18:28:06Z"
+	value := float32(100)
+
+	graph := (&MonitorTelemetry{}).GetGraphFrameDefinition()
+	graph.AppendRow((&MonitorTelemetry{Timestamp: &timestamp, Value: &value}).GetGraphVals(time.Time{})...)
+	graph.AppendRow((&MonitorTelemetry{Timestamp: &timestamp}).GetGraphVals(time.Time{})...)
+	if got := graph.Fields[1].At(0).(*float32); got == nil || *got != value {
+		t.Errorf("graph value = %v, want %v", got, value)
+	}
+	if got := graph.Fields[1].At(1).(*float32); got != nil {
+		t.Errorf("graph value without a sample = %v, want null", *got)
+	}
+
+	table := (&MonitorTelemetry{}).GetTableFrameDefinition()
+	table.AppendRow((&MonitorTelemetry{Timestamp: &timestamp}).GetTableVals(time.Time{})...)
+	if got := table.Fields[1].At(0).(*float32); got != nil {
+		t.Errorf("table value without a sample = %v, want null", *got)
+	}
+}
+
+func TestAccessorsWithMissingFields(t *testing.T) {
+	for _, frameData := range []FrameData{&MonitorErrorCount{}, &MonitorTelemetry{}, &StatusPageComponentChange{}} {
+		if _, err := frameData.GetTimestamp(); err == nil {
+			t.Errorf("%T: expected an error for a missing timestamp", frameData)
+		}
+		// None of these may panic on the nil fields
+		frameData.GetGraphVals(time.Time{})
+		frameData.GetTableVals(time.Time{})
+		frameData.GetKey()
+		frameData.GetGraphFrameDefinition()
+		GetLabels(frameData)
+	}
+}
+
+// Identifiers which look numeric must reach the labels verbatim, leading zeros included
+func TestGetLabelsPreservesNumericLookingValues(t *testing.T) {
+	timestamp := "2022-12-07T18:28:06Z"
+	instance, check, monitor, component := "007", "0123", "00", "042"
+	count := 1
+	value := float32(100)
+	tests := []struct {
+		name      string
+		frameData FrameData
+		want      map[string]string
+	}{
+		{
+			name:      "error count",
+			frameData: &MonitorErrorCount{Check: &check, Count: &count, Instance: &instance, MonitorLogicalName: &monitor, Timestamp: &timestamp},
+			want:      map[string]string{"instance": "007", "check": "0123", "monitor": "00"},
+		},
+		{
+			name:      "telemetry",
+			frameData: &MonitorTelemetry{Check: &check, Instance: &instance, MonitorLogicalName: &monitor, Timestamp: &timestamp, Value: &value},
+			want:      map[string]string{"instance": "007", "check": "0123", "monitor": "00"},
+		},
+		{
+			name:      "status page change",
+			frameData: &StatusPageComponentChange{Component: &component, MonitorLogicalName: &monitor, Timestamp: &timestamp},
+			want:      map[string]string{"component": "042", "monitor": "00"},
+		},
+		{
+			name:      "labeled error count",
+			frameData: WithLabels(&MonitorErrorCount{Check: &check, Count: &count, Instance: &instance, MonitorLogicalName: &monitor, Timestamp: &timestamp}, map[string]string{"shared": "false"}),
+			want:      map[string]string{"instance": "007", "check": "0123", "monitor": "00", "shared": "false"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetLabels(tt.frameData); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetLabels() = %v, want %v", got, tt.want)
+			}
+			frame := tt.frameData.GetGraphFrameDefinition()
+			if got := map[string]string(frame.Fields[1].Labels); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("graph field labels = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	// Embed the time zone database, the timezone query option must not depend on the host having one
+	_ "time/tzdata"
 
 	"github.com/Metrist-Software/metrist-grafana-datasource/pkg/plugin"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
@@ -0,0 +1,167 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// queryCacheMaxEntries bounds the number of responses held by the query cache
+	queryCacheMaxEntries = 256
+	// queryCacheTimeRounding is what the time range is rounded to in cache keys, so that panels refreshed a
+	// few seconds apart share their entries
+	queryCacheTimeRounding = 10 * time.Second
+)
+
+type queryCacheEntry struct {
+	response  backend.DataResponse
+	expiresAt time.Time
+}
+
+// queryCache caches query responses for a short time, so identical queries of several panels only fetch once
+type queryCache struct {
+	mu      sync.Mutex
+	entries map[string]queryCacheEntry
+}
+
+// queryCacheKey identifies query by its JSON and its time range rounded to queryCacheTimeRounding
+func queryCacheKey(query backend.DataQuery) string {
+	from := query.TimeRange.From.Truncate(queryCacheTimeRounding).Unix()
+	to := query.TimeRange.To.Truncate(queryCacheTimeRounding).Unix()
+	return fmt.Sprintf("%d-%d-%s", from, to, query.JSON)
+}
+
+// get returns the cached response of key unless it expired
+func (c *queryCache) get(key string) (backend.DataResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return backend.DataResponse{}, false
+	}
+	return entry.response, true
+}
+
+// set caches response under key for ttl. When the cache is full the expired entries are evicted first, then
+// the entry closest to expiring
+func (c *queryCache) set(key string, response backend.DataResponse, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]queryCacheEntry)
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= queryCacheMaxEntries {
+		c.evict()
+	}
+	c.entries[key] = queryCacheEntry{response: response, expiresAt: time.Now().Add(ttl)}
+}
+
+func (c *queryCache) evict() {
+	current := time.Now()
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if current.After(entry.expiresAt) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.expiresAt.Before(oldest) {
+			oldestKey, oldest = key, entry.expiresAt
+		}
+	}
+	if len(c.entries) >= queryCacheMaxEntries {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// cacheDirectives are the Cache-Control directives Grafana forwarded with a query request
+type cacheDirectives struct {
+	present bool
+	noCache bool
+	noStore bool
+	maxAge  *time.Duration
+}
+
+// requestCacheDirectives parses the Cache-Control header of a query request. Grafana prefixes forwarded
+// headers with http_ in some versions, so both forms are accepted
+func requestCacheDirectives(headers map[string]string) cacheDirectives {
+	var directives cacheDirectives
+	for name, value := range headers {
+		if !strings.EqualFold(strings.TrimPrefix(name, "http_"), "Cache-Control") {
+			continue
+		}
+		directives.present = true
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			switch {
+			case directive == "no-cache":
+				directives.noCache = true
+			case directive == "no-store":
+				directives.noStore = true
+			case strings.HasPrefix(directive, "max-age="):
+				if seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil && seconds >= 0 {
+					maxAge := time.Duration(seconds) * time.Second
+					directives.maxAge = &maxAge
+				}
+			}
+		}
+	}
+	return directives
+}
+
+// ttl returns how long a response may be cached for, given the configured query cache ttl
+func (c cacheDirectives) ttl(configured time.Duration) time.Duration {
+	if c.noCache || c.noStore {
+		return 0
+	}
+	if c.maxAge != nil && *c.maxAge < configured {
+		return *c.maxAge
+	}
+	return configured
+}
+
+// applyCacheHint returns frames with a cacheTtlSeconds hint in their custom metadata, next to the custom
+// metadata they already carry, such as their group. Cached responses are shared, so the frames and their
+// metadata are copied rather than modified
+func applyCacheHint(frames data.Frames, ttl time.Duration) data.Frames {
+	hinted := make(data.Frames, len(frames))
+	for i, frame := range frames {
+		hinted[i] = frame
+		if frame == nil {
+			continue
+		}
+		meta := data.FrameMeta{}
+		if frame.Meta != nil {
+			meta = *frame.Meta
+		}
+		custom := make(map[string]any)
+		switch existing := meta.Custom.(type) {
+		case nil:
+		case map[string]any:
+			for key, value := range existing {
+				custom[key] = value
+			}
+		case map[string]string:
+			for key, value := range existing {
+				custom[key] = value
+			}
+		default:
+			// Custom metadata of any other shape has no room for the hint
+			continue
+		}
+		custom["cacheTtlSeconds"] = int64(ttl / time.Second)
+		meta.Custom = custom
+		copied := *frame
+		copied.Meta = &meta
+		hinted[i] = &copied
+	}
+	return hinted
+}
@@ -3,11 +3,15 @@ package plugin
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
+	"runtime/debug"
+	"strings"
 	"time"
 
 	"github.com/Metrist-Software/metrist-grafana-datasource/pkg/internal"
@@ -15,6 +19,8 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -28,32 +34,57 @@ var (
 	errRemoteRequest                   = errors.New("remote request error")
 	errRemoteResponse                  = errors.New("remote response error")
 	errMissingApiKey                   = errors.New("missing api key")
-	errTimerangeLimitExceeded          = errors.New("time range cannot exceed 90 days")
+	errInvalidCACertificate            = errors.New("no valid PEM certificates found in ca certificate")
+	errRateLimited                     = errors.New("rate limited by the Metrist API")
+	errTimerangeLimitExceeded          = errors.New("time range limit exceeded")
 	errTelemetryRequestedOutsideBounds = errors.New("telemetry is only available for the past 90 days")
+	errInvalidSloTarget                = errors.New("slo target must be between 0 and 100")
+	errUnknownEnvironment              = errors.New("unknown environment")
+	errQueryPanicked                   = errors.New("query panicked")
+	errInvalidHistogramBuckets         = errors.New("histogram buckets need at least two increasing bounds")
 )
 
 const (
-	durationThreeMonths = 3 * 30 * 24 * time.Hour
+	// durationThreeMonths is how long the API keeps data for
+	durationThreeMonths   = 3 * 30 * 24 * time.Hour
+	defaultRequestTimeout = 30 * time.Second
 )
 
+// healthCheckRetryDelay is how long CheckHealth waits before retrying a server error
+var healthCheckRetryDelay = time.Second
+
+// now is the clock the time range checks are made against, replaced by tests which need a fixed time
+var now = time.Now
+
 // NewDatasource creates a new datasource instance.
 func NewDatasource(settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+	opts, err := settings.HTTPClientOptions()
+	if err != nil {
+		return nil, fmt.Errorf("http client options: %w", err)
+	}
+
+	dsSettings, err := loadDatasourceSettings(settings)
+	if err != nil {
+		return nil, fmt.Errorf("datasource settings: %w", err)
+	}
+
+	logLevel, err := dsSettings.logLevel()
+	if err != nil {
+		return nil, fmt.Errorf("datasource settings: %w", err)
+	}
+	logger := withLevel(log.DefaultLogger, logLevel)
 	logRequestMeta := func(ctx context.Context, req *http.Request) error {
-		log.DefaultLogger.Debug("request url: %s, header %s", req.URL.String(), req.Header)
+		logger.Debug("upstream request", "url", req.URL.String(), "header", req.Header)
 		return nil
 	}
 
-	opts, err := settings.HTTPClientOptions()
+	rootCAs, err := loadRootCAs(settings.DecryptedSecureJSONData["caCertificate"])
 	if err != nil {
-		return nil, fmt.Errorf("http client options: %w", err)
+		return nil, fmt.Errorf("ca certificate: %w", err)
 	}
 
-	opts.ConfigureTLSConfig = func(opts httpclient.Options, tlsConfig *tls.Config) {
-		if internal.Environment == "local" {
-			// We skip TLS verification if running against local as self signed certificates may be being used
-			tlsConfig.InsecureSkipVerify = true
-		}
-	}
+	opts.ConfigureTLSConfig = configureTLS(dsSettings, rootCAs)
+	configureHTTPClientOptions(&opts, dsSettings)
 
 	cl, err := httpclient.New(opts)
 	if err != nil {
@@ -65,22 +96,142 @@ func NewDatasource(settings backend.DataSourceInstanceSettings) (instancemgmt.In
 		return nil, errMissingApiKey
 	}
 
-	openApiClient, err := internal.NewClientWithResponses(internal.Endpoint(), internal.WithHTTPClient(cl), internal.WithRequestEditorFn(withAPIKey(apiKey)), internal.WithRequestEditorFn(logRequestMeta))
+	clientOptions := []internal.ClientOption{internal.WithHTTPClient(cl), internal.WithRequestEditorFn(withAPIKey(apiKey, dsSettings.authHeaderName(), dsSettings.AuthScheme)), internal.WithRequestEditorFn(logRequestMeta)}
+	if dsSettings.RequestsPerSecond > 0 {
+		// A single limiter is shared by every request of this datasource instance
+		limiter := rate.NewLimiter(rate.Limit(dsSettings.RequestsPerSecond), int(math.Max(1, dsSettings.RequestsPerSecond)))
+		clientOptions = append(clientOptions, internal.WithRequestEditorFn(withRateLimit(limiter)))
+	}
+
+	openApiClient, err := internal.NewClientWithResponses(internal.Endpoint(), clientOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("internal new client: %w", err)
 	}
 
+	environmentClients := make(map[string]internal.ClientWithResponsesInterface, len(dsSettings.Environments))
+	for environment, endpoint := range dsSettings.Environments {
+		client, err := internal.NewClientWithResponses(endpoint, clientOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("internal new client for environment %q: %w", environment, err)
+		}
+		environmentClients[environment] = withPageSize(client, dsSettings.PageSize)
+	}
+
 	return &Datasource{
-		settings:      settings,
-		httpClient:    cl,
-		openApiClient: openApiClient,
+		settings:           settings,
+		dsSettings:         dsSettings,
+		httpClient:         cl,
+		openApiClient:      withPageSize(openApiClient, dsSettings.PageSize),
+		environmentClients: environmentClients,
+		logLevel:           logLevel,
 	}, nil
 }
 
 type Datasource struct {
 	settings      backend.DataSourceInstanceSettings
+	dsSettings    datasourceSettings
 	httpClient    *http.Client
 	openApiClient internal.ClientWithResponsesInterface
+	// environmentClients are the clients of the configured environments, by environment name
+	environmentClients map[string]internal.ClientWithResponsesInterface
+	monitorNames       monitorNameCache
+	queries            queryCache
+	// logLevel is the lowest level the datasource logs at, every level when NoLevel
+	logLevel log.Level
+}
+
+// logger returns the logger of the datasource, which drops the lines below its log level
+func (d *Datasource) logger() log.Logger {
+	return withLevel(log.DefaultLogger, d.logLevel)
+}
+
+// clientFor returns the client of environment, or the default client when no environment is given
+func (d *Datasource) clientFor(environment string) (internal.ClientWithResponsesInterface, error) {
+	if environment == "" {
+		return d.openApiClient, nil
+	}
+	client, ok := d.environmentClients[environment]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errUnknownEnvironment, environment)
+	}
+	return client, nil
+}
+
+// monitorNamesFor returns the monitor display names of the environment query runs against
+func (d *Datasource) monitorNamesFor(ctx context.Context, query backend.DataQuery) (map[string]string, error) {
+	var qm queryModel
+	if err := json.Unmarshal(query.JSON, &qm); err != nil {
+		return nil, err
+	}
+	client, err := d.clientFor(qm.Environment)
+	if err != nil {
+		return nil, err
+	}
+	return d.monitorNames.get(ctx, qm.Environment, client)
+}
+
+func loadDatasourceSettings(settings backend.DataSourceInstanceSettings) (datasourceSettings, error) {
+	var dsSettings datasourceSettings
+	if len(settings.JSONData) == 0 {
+		return dsSettings, nil
+	}
+
+	if err := json.Unmarshal(settings.JSONData, &dsSettings); err != nil {
+		return dsSettings, err
+	}
+	return dsSettings, nil
+}
+
+// configureHTTPClientOptions applies the datasource timeout and connection pool settings on top of Grafana's defaults
+func configureHTTPClientOptions(opts *httpclient.Options, dsSettings datasourceSettings) {
+	if opts.Timeouts == nil {
+		timeouts := httpclient.DefaultTimeoutOptions
+		timeouts.Timeout = defaultRequestTimeout
+		opts.Timeouts = &timeouts
+	}
+
+	// Grafana already sets the timeout configured for data sources, which is only overridden when set
+	if dsSettings.RequestTimeoutSeconds > 0 {
+		opts.Timeouts.Timeout = time.Duration(dsSettings.RequestTimeoutSeconds) * time.Second
+	}
+
+	if dsSettings.MaxIdleConns > 0 {
+		// All requests go to the same Metrist host so the per host limit matches the global one
+		opts.Timeouts.MaxIdleConns = dsSettings.MaxIdleConns
+		opts.Timeouts.MaxIdleConnsPerHost = dsSettings.MaxIdleConns
+	}
+	if dsSettings.MaxConnsPerHost > 0 {
+		opts.Timeouts.MaxConnsPerHost = dsSettings.MaxConnsPerHost
+	}
+}
+
+// loadRootCAs returns the system roots extended with the given PEM encoded CA bundle,
+// or nil when no bundle is configured so the system roots are used as is
+func loadRootCAs(caCertificate string) (*x509.CertPool, error) {
+	if caCertificate == "" {
+		return nil, nil
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if ok := rootCAs.AppendCertsFromPEM([]byte(caCertificate)); !ok {
+		return nil, errInvalidCACertificate
+	}
+	return rootCAs, nil
+}
+
+func configureTLS(dsSettings datasourceSettings, rootCAs *x509.CertPool) httpclient.ConfigureTLSConfigFunc {
+	return func(opts httpclient.Options, tlsConfig *tls.Config) {
+		if rootCAs != nil {
+			tlsConfig.RootCAs = rootCAs
+		}
+		if internal.Environment == "local" && dsSettings.SkipTlsVerify {
+			// Self signed certificates may be used when running against local, so allow opting out of verification
+			tlsConfig.InsecureSkipVerify = true
+		}
+	}
 }
 
 func (d *Datasource) Dispose() {
@@ -89,24 +240,62 @@ func (d *Datasource) Dispose() {
 
 // QueryData go through each query and routes them to the appropriate query handler
 func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
-	log.DefaultLogger.Debug("QueryData called", "numQueries", len(req.Queries))
+	logger := newRequestLogger(d.logger())
+	logger.Debug("QueryData called", "numQueries", len(req.Queries))
 	response := backend.NewQueryDataResponse()
+	directives := requestCacheDirectives(req.Headers)
 
 	for _, q := range req.Queries {
-		if err := ensureTimeRangeWithinLimits(q.TimeRange.Duration()); err != nil {
-			log.DefaultLogger.Error("time range error: %w", err)
+		queryLogger := loggerWith(logger, "refId", q.RefID)
+		if err := applyDefaultIncludeShared(&q, d.dsSettings.DefaultIncludeShared); err != nil {
+			queryLogger.Error("query defaults error", "error", err)
+			response.Responses[q.RefID] = backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+			continue
+		}
+		if err := applyTimeRangeOverride(&q); err != nil {
+			queryLogger.Error("time range override error", "error", err)
+			response.Responses[q.RefID] = backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+			continue
+		}
+		if err := ensureTimeRangeWithinLimits(q.TimeRange.Duration(), d.dsSettings.timeRangeLimit()); err != nil {
+			queryLogger.Error("time range error", "error", err)
 			response.Responses[q.RefID] = backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
 			continue
 		}
 
-		res, err := d.query(ctx, req.PluginContext, q)
+		cacheTTL := time.Duration(d.dsSettings.QueryCacheTTLSeconds) * time.Second
+		cacheKey := queryCacheKey(q)
+		if cacheTTL > 0 && !directives.noCache {
+			if res, ok := d.queries.get(cacheKey); ok {
+				queryLogger.Debug("query served from cache")
+				response.Responses[q.RefID] = withCacheHint(res, directives, cacheTTL)
+				continue
+			}
+		}
+
+		res, err := d.safeQuery(ctx, req.PluginContext, q, queryLogger)
 		if err != nil {
-			log.DefaultLogger.Error("error %v", err)
+			queryLogger.Error("query error", "error", err)
+		}
+
+		if err == nil && len(res.Frames) > 0 {
+			// The aliases are configured, so they still apply when the display names can't be fetched
+			names, err := d.monitorNamesFor(ctx, q)
+			if err != nil {
+				queryLogger.Warn("could not fetch monitor display names", "error", err)
+			}
+			applyMonitorNames(res.Frames, d.dsSettings.withMonitorAliases(names))
+		}
+		// Cached responses are shared between queries, so they are only stored once nothing modifies them
+		if ttl := directives.ttl(cacheTTL); err == nil && res.Error == nil && ttl > 0 && !directives.noStore {
+			d.queries.set(cacheKey, res, ttl)
 		}
 
 		switch {
 		case err == nil:
 			// Do nothing
+		case errors.Is(err, errRateLimited):
+			res = backend.ErrDataResponse(backend.StatusTooManyRequests, err.Error())
 		case errors.Is(err, context.DeadlineExceeded):
 			res = backend.ErrDataResponse(backend.StatusTimeout, "gateway timeout")
 		case errors.Is(err, errRemoteRequest):
@@ -117,28 +306,97 @@ func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataReques
 			res = backend.ErrDataResponse(backend.StatusInternal, err.Error())
 		}
 
-		response.Responses[q.RefID] = res
+		response.Responses[q.RefID] = withCacheHint(res, directives, cacheTTL)
 	}
 
 	return response, nil
 }
 
-func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery) (backend.DataResponse, error) {
+// withCacheHint annotates the frames of res with how long they may be cached when the request carried cache
+// directives. The hint is the ttl the response is cached for by the query cache, see cacheDirectives.ttl
+func withCacheHint(res backend.DataResponse, directives cacheDirectives, configured time.Duration) backend.DataResponse {
+	if !directives.present || res.Error != nil {
+		return res
+	}
+	res.Frames = applyCacheHint(res.Frames, directives.ttl(configured))
+	return res
+}
+
+// safeQuery runs query, turning a panic of its handler into an error so that only this query fails
+func (d *Datasource) safeQuery(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery, logger log.Logger) (res backend.DataResponse, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("query panicked", "panic", r, "stack", string(debug.Stack()))
+			res, err = backend.DataResponse{}, fmt.Errorf("%w: %v", errQueryPanicked, r)
+		}
+	}()
+	return d.query(ctx, pCtx, query, logger)
+}
+
+func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery, logger log.Logger) (backend.DataResponse, error) {
 	var qm queryModel
 	if err := json.Unmarshal(query.JSON, &qm); err != nil {
 		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
 	}
 
+	// Without monitors the upstream behavior is undefined, so don't issue a potentially huge request
+	if len(qm.Monitors) == 0 {
+		return noMonitorsResponse(), nil
+	}
+
+	client, err := d.clientFor(qm.Environment)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	var handler func(context.Context, backend.DataQuery, internal.ClientWithResponsesInterface, log.Logger) (backend.DataResponse, error)
 	switch qm.QueryType {
-	case "GetMonitorErrors":
-		return QueryMonitorErrors(ctx, query, d.openApiClient)
-	case "GetMonitorTelemetry":
-		return QueryMonitorTelemetry(ctx, query, d.openApiClient)
-	case "GetMonitorStatusPageChanges":
-		return QueryMonitorStatusPageChanges(ctx, query, d.openApiClient)
+	case queryTypeMonitorErrors:
+		handler = QueryMonitorErrors
+	case queryTypeMonitorTelemetry:
+		handler = QueryMonitorTelemetry
+	case queryTypeMonitorStatusPageChanges:
+		handler = func(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
+			return QueryMonitorStatusPageChanges(ctx, query, client, d.dsSettings.statusPalette(), logger)
+		}
+	case queryTypeTopErrors:
+		handler = QueryTopErrors
+	case queryTypeMonitorOverview:
+		handler = QueryMonitorOverview
+	case queryTypeSloBurnRate:
+		handler = QuerySloBurnRate
+	case queryTypeErrorRatio:
+		handler = QueryErrorRatio
+	case queryTypeInstanceCount:
+		handler = QueryInstanceCount
+	case queryTypeLastSeen:
+		handler = QueryLastSeen
+	case queryTypeStatusPageAvailability:
+		handler = QueryStatusPageAvailability
+	case queryTypeTelemetryHistogram:
+		handler = QueryTelemetryHistogram
+	case queryTypeErroringCheckCount:
+		handler = QueryErroringCheckCount
+	case queryTypeInstanceAvailability:
+		handler = QueryInstanceAvailability
 	default:
 		return backend.DataResponse{}, nil
 	}
+
+	res, err := handler(ctx, query, client, logger)
+	if err == nil && qm.Limit > 0 {
+		res.Frames = applyRowLimit(res.Frames, qm.Limit)
+	}
+	return res, err
+}
+
+// noMonitorsResponse is an empty response with a notice telling the user to select monitors
+func noMonitorsResponse() backend.DataResponse {
+	frame := data.NewFrame("")
+	frame.Meta = &data.FrameMeta{
+		Notices: []data.Notice{{Severity: data.NoticeSeverityInfo, Text: "Select at least one monitor to query"}},
+	}
+	return backend.DataResponse{Frames: data.Frames{frame}}
 }
 
 // CheckHealth handles health checks sent from Grafana to the plugin.
@@ -148,15 +406,38 @@ func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, quer
 func (d *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
 	resp, err := d.openApiClient.BackendWebVerifyAuthControllerGetWithResponse(ctx)
 	if err != nil {
-		log.DefaultLogger.Debug("verify auth controller error: %w", err)
-		return nil, err
+		d.logger().Debug("verify auth controller error", "error", err)
+		return unreachableResult(err), nil
+	}
+
+	// Retry once so a momentary upstream outage doesn't fail the test button
+	if resp.StatusCode() >= http.StatusInternalServerError {
+		d.logger().Debug("verify auth controller server error, retrying", "status", resp.Status())
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(healthCheckRetryDelay):
+		}
+
+		if resp, err = d.openApiClient.BackendWebVerifyAuthControllerGetWithResponse(ctx); err != nil {
+			d.logger().Debug("verify auth controller error", "error", err)
+			return unreachableResult(err), nil
+		}
 	}
 
 	switch resp.StatusCode() {
 	case http.StatusOK:
+		message, missing := d.healthyMessage(ctx)
+		if len(missing) > 0 {
+			// The SDK has no warning status, so an error is reported for the test button to stand out
+			return &backend.CheckHealthResult{
+				Status:  backend.HealthStatusError,
+				Message: fmt.Sprintf("Warning: the API key can't access the required monitors %s", strings.Join(missing, ", ")),
+			}, nil
+		}
 		return &backend.CheckHealthResult{
 			Status:  backend.HealthStatusOk,
-			Message: "Data source is working!",
+			Message: message,
 		}, nil
 	case http.StatusUnauthorized:
 		return &backend.CheckHealthResult{
@@ -171,10 +452,51 @@ func (d *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRe
 	}
 }
 
+// unreachableResult is the health check result of a request which failed before Metrist answered, so
+// connectivity problems are told apart from an invalid API key
+func unreachableResult(err error) *backend.CheckHealthResult {
+	return &backend.CheckHealthResult{
+		Status:  backend.HealthStatusError,
+		Message: fmt.Sprintf("Could not reach Metrist API: %v", err),
+	}
+}
+
+// healthyMessage reports how many monitors the API key gives access to, along with the configured required
+// monitors it can't access. The monitor list is only informative, so when it can't be fetched the plain message
+// is returned and the required monitors aren't verified
+func (d *Datasource) healthyMessage(ctx context.Context) (string, []string) {
+	message := "Data source is working!"
+	resp, err := d.openApiClient.BackendWebMonitorListControllerGetWithResponse(ctx)
+	if err != nil {
+		d.logger().Debug("monitor list error", "error", err)
+		return message, nil
+	}
+	if resp.JSON200 == nil {
+		d.logger().Debug("monitor list non 200 status code", "status", resp.Status())
+		return message, nil
+	}
+
+	accessible := make(map[string]bool, len(*resp.JSON200))
+	for _, monitor := range *resp.JSON200 {
+		if monitor.LogicalName != nil {
+			accessible[*monitor.LogicalName] = true
+		}
+	}
+	missing := make([]string, 0)
+	for _, monitor := range d.dsSettings.RequiredMonitors {
+		if !accessible[monitor] {
+			missing = append(missing, monitor)
+		}
+	}
+	return fmt.Sprintf("%s %d monitors available", message, len(*resp.JSON200)), missing
+}
+
 // CallResource implements backend.CallResourceHandler
 func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	logger := newRequestLogger(d.logger())
+	logger.Debug("CallResource called", "path", req.Path, "url", req.URL)
+
 	// Parameters from getResource come in as query string parameters in the URL property
-	log.DefaultLogger.Debug("url %s", req.URL)
 	u, err := url.Parse(req.URL)
 	if err != nil {
 		return err
@@ -184,9 +506,19 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 
 	switch req.Path {
 	case "Monitors":
-		response, err := ResourceMonitorList(ctx, d.openApiClient)
+		response, err := ResourceMonitorList(ctx, d.openApiClient, queryStringValues.Get("onlyActive") == "true", d.dsSettings.MonitorAliases, queryStringValues.Get("format"))
+		if err != nil {
+			logger.Error("resource monitor list error", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   []byte(fmt.Sprintf(`{"message": "%s"}`, "internal server error")),
+			})
+		}
+		return sender.Send(&response)
+	case "MonitorSearch":
+		response, err := ResourceMonitorSearch(ctx, d.openApiClient, queryStringValues.Get("q"), d.dsSettings.MonitorAliases, queryStringValues.Get("format"))
 		if err != nil {
-			log.DefaultLogger.Error("resource monitor list error: %w", err)
+			logger.Error("monitor search error", "error", err)
 			return sender.Send(&backend.CallResourceResponse{
 				Status: http.StatusInternalServerError,
 				Body:   []byte(fmt.Sprintf(`{"message": "%s"}`, "internal server error")),
@@ -194,9 +526,9 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 		}
 		return sender.Send(&response)
 	case "Checks":
-		response, err := ResourceCheckList(ctx, d.openApiClient, queryStringValues["monitors"], queryStringValues.Get("includeShared") == "true")
+		response, err := ResourceCheckList(ctx, d.openApiClient, queryStringValues["monitors"], queryStringValues.Get("includeShared") == "true", d.dsSettings.ResourceFanOut, queryStringValues.Get("format"))
 		if err != nil {
-			log.DefaultLogger.Error("checks list error: %w", err)
+			logger.Error("checks list error", "error", err)
 			return sender.Send(&backend.CallResourceResponse{
 				Status: http.StatusInternalServerError,
 				Body:   []byte(fmt.Sprintf(`{"message": "%s"}`, "internal server error")),
@@ -204,9 +536,74 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 		}
 		return sender.Send(&response)
 	case "Instances":
-		response, err := ResourceInstanceList(ctx, d.openApiClient, queryStringValues["monitors"], queryStringValues.Get("includeShared") == "true")
+		response, err := ResourceInstanceList(ctx, d.openApiClient, queryStringValues["monitors"], queryStringValues.Get("includeShared") == "true", d.dsSettings.ResourceFanOut, queryStringValues.Get("format"))
+		if err != nil {
+			logger.Error("instances list error", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   []byte(fmt.Sprintf(`{"message": "%s"}`, "internal server error")),
+			})
+		}
+		return sender.Send(&response)
+	case "Health":
+		response, err := ResourceHealth(ctx, d.openApiClient)
+		if err != nil {
+			logger.Error("health error", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   []byte(fmt.Sprintf(`{"message": "%s"}`, "internal server error")),
+			})
+		}
+		return sender.Send(&response)
+	case "QueryTypes":
+		response, err := ResourceQueryTypes()
+		if err != nil {
+			logger.Error("query types error", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   []byte(fmt.Sprintf(`{"message": "%s"}`, "internal server error")),
+			})
+		}
+		return sender.Send(&response)
+	case "StatusMappings":
+		response, err := ResourceStatusMappings(d.dsSettings.statusPalette())
+		if err != nil {
+			logger.Error("status mappings error", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   []byte(fmt.Sprintf(`{"message": "%s"}`, "internal server error")),
+			})
+		}
+		return sender.Send(&response)
+	case "ExportCsv":
+		response, err := ResourceExportCsv(ctx, d.openApiClient, d.dsSettings.timeRangeLimit(), queryStringValues, logger)
 		if err != nil {
-			log.DefaultLogger.Error("instances list error: %w", err)
+			logger.Error("export csv error", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   []byte(fmt.Sprintf(`{"message": "%s"}`, "internal server error")),
+			})
+		}
+		return sender.Send(&response)
+	case "RawQuery":
+		if !d.dsSettings.Debug {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusNotFound,
+			})
+		}
+		response, err := ResourceRawQuery(ctx, d.openApiClient, d.dsSettings.timeRangeLimit(), queryStringValues)
+		if err != nil {
+			logger.Error("raw query error", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   []byte(fmt.Sprintf(`{"message": "%s"}`, "internal server error")),
+			})
+		}
+		return sender.Send(&response)
+	case "StatusBadge":
+		response, err := ResourceStatusBadge(ctx, d.openApiClient, d.dsSettings.statusPalette(), queryStringValues, logger)
+		if err != nil {
+			logger.Error("status badge error", "error", err)
 			return sender.Send(&backend.CallResourceResponse{
 				Status: http.StatusInternalServerError,
 				Body:   []byte(fmt.Sprintf(`{"message": "%s"}`, "internal server error")),
@@ -225,10 +622,80 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 	}
 }
 
-func ensureTimeRangeWithinLimits(duration time.Duration) error {
-	if duration.Truncate(time.Hour) > durationThreeMonths {
-		return errTimerangeLimitExceeded
+// applyDefaultIncludeShared sets includeShared in the JSON of q when the query leaves it out and the
+// datasource defaults to including shared data
+func applyDefaultIncludeShared(q *backend.DataQuery, defaultIncludeShared bool) error {
+	if !defaultIncludeShared {
+		return nil
+	}
+
+	var query monitorTelemetryQuery
+	if err := json.Unmarshal(q.JSON, &query); err != nil || query.IncludeShared != nil {
+		// Invalid JSON is left for the query handler to report
+		return nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(q.JSON, &raw); err != nil {
+		return nil
+	}
+	raw["includeShared"] = json.RawMessage("true")
+
+	queryJSON, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	q.JSON = queryJSON
+	return nil
+}
+
+// applyTimeRangeOverride replaces the time range of q with the absolute from/to overrides in its JSON, if any,
+// so the handlers and the time range limits all see the overridden range
+func applyTimeRangeOverride(q *backend.DataQuery) error {
+	var query monitorTelemetryQuery
+	if err := json.Unmarshal(q.JSON, &query); err != nil {
+		// Left for the query handler to report
+		return nil
+	}
+
+	timeRange := q.TimeRange
+	var err error
+	if query.FromOverride != "" {
+		if timeRange.From, err = time.Parse(time.RFC3339, query.FromOverride); err != nil {
+			return fmt.Errorf("invalid fromOverride: %w", err)
+		}
+	}
+	if query.ToOverride != "" {
+		if timeRange.To, err = time.Parse(time.RFC3339, query.ToOverride); err != nil {
+			return fmt.Errorf("invalid toOverride: %w", err)
+		}
+	}
+	if timeRange.To.Before(timeRange.From) {
+		return errors.New("time range override ends before it starts")
+	}
+
+	q.TimeRange = timeRange
+	return nil
+}
+
+// ensureTimeRangeWithinLimits rejects time ranges longer than limit, naming the requested duration so users
+// know how far to shorten it
+func ensureTimeRangeWithinLimits(duration time.Duration, limit time.Duration) error {
+	if duration.Truncate(time.Hour) > limit {
+		return fmt.Errorf("%w: the requested %s cannot exceed %s", errTimerangeLimitExceeded, formatDays(duration), formatDays(limit))
 	}
 
 	return nil
 }
+
+// formatDays formats duration in days, with a decimal only when it is not a whole number of days
+func formatDays(duration time.Duration) string {
+	days := duration.Hours() / 24
+	if days == 1 {
+		return "1 day"
+	}
+	if days == float64(int64(days)) {
+		return fmt.Sprintf("%d days", int64(days))
+	}
+	return fmt.Sprintf("%.1f days", days)
+}
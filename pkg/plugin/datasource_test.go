@@ -2,15 +2,42 @@ package plugin
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Metrist-Software/metrist-grafana-datasource/pkg/internal"
 	"github.com/google/go-cmp/cmp"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
+// withoutExecutedQueryString clears the executed query string of frames, as it embeds the query time range
+func withoutExecutedQueryString(frames data.Frames) data.Frames {
+	for _, frame := range frames {
+		if frame.Meta != nil {
+			frame.Meta.ExecutedQueryString = ""
+		}
+	}
+	return frames
+}
+
 func TestQueryMonitorTelemetry(t *testing.T) {
 	var value float32 = 100
 	timeRange := backend.TimeRange{
@@ -39,14 +66,14 @@ func TestQueryMonitorTelemetry(t *testing.T) {
 			want: data.Frames{{
 				Fields: []*data.Field{
 					data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:28:06.485416Z")}),
-					data.NewField("response time (ms)", data.Labels{"instance": "us-east-1", "check": "Check", "monitor": "awslambda"}, []float32{value}),
+					data.NewField("response time (ms)", data.Labels{"instance": "us-east-1", "check": "Check", "monitor": "awslambda"}, []*float32{&value}),
 				},
-				Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti, PreferredVisualization: data.VisTypeGraph},
+				Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti, PreferredVisualization: data.VisTypeGraph, Stats: wantFrameStats(1, 1)},
 			},
 				{
 					Fields: []*data.Field{
 						data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:28:06.485416Z")}),
-						data.NewField("response time (ms)", nil, []float32{100}),
+						data.NewField("response time (ms)", nil, []*float32{ptr[float32](100)}),
 						data.NewField("instance", nil, []string{"us-east-1"}),
 						data.NewField("check", nil, []string{"Check"}),
 						data.NewField("monitor", nil, []string{"awslambda"}),
@@ -82,7 +109,7 @@ func TestQueryMonitorTelemetry(t *testing.T) {
 			if len(resp.Responses) != 1 {
 				t.Fatal("QueryData must return a response")
 			}
-			if diff := cmp.Diff(test.want, resp.Responses["A"].Frames, data.FrameTestCompareOptions()...); diff != "" {
+			if diff := cmp.Diff(test.want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
 				t.Errorf("Result mismatch (-want +got):\n%s", diff)
 			}
 		})
@@ -117,7 +144,7 @@ func TestQueryMonitorStatusPageChanges(t *testing.T) {
 					data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:28:06.485416Z")}),
 					data.NewField("status", data.Labels{"component": "component1", "monitor": "monitor"}, []int8{2}),
 				},
-				Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti, PreferredVisualization: data.VisTypeGraph},
+				Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti, PreferredVisualization: data.VisTypeGraph, Stats: wantFrameStats(1, 1)},
 			},
 				{
 					Fields: []*data.Field{
@@ -125,6 +152,7 @@ func TestQueryMonitorStatusPageChanges(t *testing.T) {
 						data.NewField("status", nil, []int8{2}),
 						data.NewField("component", nil, []string{"component1"}),
 						data.NewField("monitor", nil, []string{"monitor"}),
+						data.NewField("duration", nil, []*float64{nil}),
 					},
 					Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide, PreferredVisualization: data.VisTypeTable},
 				},
@@ -166,7 +194,7 @@ func TestQueryMonitorStatusPageChanges(t *testing.T) {
 					field.Config = nil
 				}
 			}
-			if diff := cmp.Diff(test.want, resp.Responses["A"].Frames, data.FrameTestCompareOptions()...); diff != "" {
+			if diff := cmp.Diff(test.want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
 				t.Errorf("Result mismatch (-want +got):\n%s", diff)
 			}
 		})
@@ -202,7 +230,7 @@ func TestQueryMonitorErrors(t *testing.T) {
 					data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:28:06.485416Z")}),
 					data.NewField("count", data.Labels{"check": "check", "monitor": "monitor", "instance": "us-east-1"}, []int64{1}),
 				},
-				Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti, PreferredVisualization: data.VisTypeGraph},
+				Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti, PreferredVisualization: data.VisTypeGraph, Stats: wantFrameStats(1, 1)},
 			},
 				{
 					Fields: []*data.Field{
@@ -211,6 +239,7 @@ func TestQueryMonitorErrors(t *testing.T) {
 						data.NewField("instance", nil, []string{"us-east-1"}),
 						data.NewField("check", nil, []string{"check"}),
 						data.NewField("monitor", nil, []string{"monitor"}),
+						data.NewField("error_message", nil, []string{"check failed"}),
 					},
 					Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide, PreferredVisualization: data.VisTypeTable},
 				},
@@ -243,7 +272,7 @@ func TestQueryMonitorErrors(t *testing.T) {
 			if len(resp.Responses) != 1 {
 				t.Fatal("QueryData must return a response")
 			}
-			if diff := cmp.Diff(test.want, resp.Responses["A"].Frames, data.FrameTestCompareOptions()...); diff != "" {
+			if diff := cmp.Diff(test.want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
 				t.Errorf("Result mismatch (-want +got):\n%s", diff)
 			}
 		})
@@ -254,3 +283,2467 @@ func TestQueryMonitorErrors(t *testing.T) {
 func ptr[T any](v T) *T {
 	return &v
 }
+
+func TestCallResource(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		client     stubClient
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "routes to the monitor list",
+			path:       "Monitors",
+			client:     stubClient{monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{JSON200: &internal.MonitorListResponse{{LogicalName: ptr("awslambda"), Name: ptr("AWS Lambda")}}}},
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"label":"AWS Lambda","value":"awslambda"}]`,
+		},
+		{
+			name:       "returns the build hash",
+			path:       "BuildHash",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"hash": ""}`,
+		},
+		{
+			name:       "hides the raw query unless debugging is enabled",
+			path:       "RawQuery",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "routes to the status badge",
+			path:       "StatusBadge",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"monitor is required"}`,
+		},
+		{
+			name:       "returns not found for unknown paths",
+			path:       "Unknown",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ds := Datasource{openApiClient: &test.client}
+			sender := stubSender{}
+			err := ds.CallResource(context.Background(), &backend.CallResourceRequest{Path: test.path, URL: test.path}, &sender)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sender.responses) != 1 {
+				t.Fatalf("CallResource must send exactly one response, got %d", len(sender.responses))
+			}
+			if sender.responses[0].Status != test.wantStatus {
+				t.Errorf("CallResource() status = %d, want %d", sender.responses[0].Status, test.wantStatus)
+			}
+			if string(sender.responses[0].Body) != test.wantBody {
+				t.Errorf("CallResource() body = %s, want %s", sender.responses[0].Body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestCallResourceDoesNotPanic(t *testing.T) {
+	ds := Datasource{openApiClient: &stubClient{}}
+	requests := []*backend.CallResourceRequest{
+		{Path: "BuildHash", URL: "BuildHash", Headers: map[string][]string{"X-Test": {string([]byte{0xff, 0xfe})}}},
+		{Path: "BuildHash", URL: "%zz"},
+		{Path: "", URL: ""},
+	}
+
+	for _, req := range requests {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("CallResource() panicked for path %q, url %q: %v", req.Path, req.URL, r)
+				}
+			}()
+			_ = ds.CallResource(context.Background(), req, &stubSender{})
+		}()
+	}
+}
+
+func TestQueryMonitorStatusPageChangesFromAlerting(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	query := []byte(`{"monitors": ["awslambda"], "fromAlerting": true, "queryType": "GetMonitorStatusPageChanges"}`)
+	ds := Datasource{openApiClient: &stubClient{
+		statusPageResponse: internal.BackendWebStatusPageChangeControllerGetResponse{
+			JSON200: &internal.StatusPageChangesResponse{
+				Metadata: &internal.PagingMetadata{},
+				Entries: &[]internal.StatusPageComponentChange{
+					{Component: ptr("component1"), MonitorLogicalName: ptr("monitor"), Status: ptr("major_outage"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+					{Component: ptr("component1"), MonitorLogicalName: ptr("monitor"), Status: ptr("up"), Timestamp: ptr("2022-12-07T19:28:06Z")},
+					{Component: ptr("component1"), MonitorLogicalName: ptr("monitor"), Status: ptr("degraded"), Timestamp: ptr("2022-12-07T17:28:06Z")},
+				},
+			},
+		},
+	}}
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range resp.Responses["A"].Frames {
+		for _, field := range frame.Fields {
+			field.Config = nil
+		}
+	}
+
+	want := data.Frames{{
+		Fields: []*data.Field{
+			data.NewField("status", data.Labels{"component": "component1", "monitor": "monitor"}, []int8{2}),
+		},
+		Meta: &data.FrameMeta{Type: data.FrameTypeNumericMulti, Stats: wantFrameStats(1, 1)},
+	}}
+	if diff := cmp.Diff(want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryMonitorStatusPageChangesBinaryStatus(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	query := []byte(`{"monitors": ["awslambda"], "binaryStatus": true, "queryType": "GetMonitorStatusPageChanges"}`)
+	ds := Datasource{openApiClient: &stubClient{
+		statusPageResponse: internal.BackendWebStatusPageChangeControllerGetResponse{
+			JSON200: &internal.StatusPageChangesResponse{
+				Metadata: &internal.PagingMetadata{},
+				Entries: &[]internal.StatusPageComponentChange{
+					{Component: ptr("component1"), MonitorLogicalName: ptr("monitor"), Status: ptr("operational"), Timestamp: ptr("2022-12-07T17:28:06Z")},
+					{Component: ptr("component1"), MonitorLogicalName: ptr("monitor"), Status: ptr("degraded"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+					{Component: ptr("component1"), MonitorLogicalName: ptr("monitor"), Status: ptr("major_outage"), Timestamp: ptr("2022-12-07T19:28:06Z")},
+				},
+			},
+		},
+	}}
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := resp.Responses["A"].Frames
+	if len(frames) != 2 {
+		t.Fatalf("expected a graph and a table frame, got %d frames", len(frames))
+	}
+	for _, frame := range frames {
+		status := frame.Fields[1]
+		want := []int8{1, 0, 0}
+		got := make([]int8, status.Len())
+		for i := range got {
+			got[i] = status.At(i).(int8)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%s status mismatch (-want +got):\n%s", frame.Meta.PreferredVisualization, diff)
+		}
+		if mappings := status.Config.Mappings; len(mappings) != 2 {
+			t.Errorf("expected a two entry value mapping, got %d entries", len(mappings))
+		}
+	}
+}
+
+func TestQueryMonitorStatusPageChangesStatusText(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	client := &stubClient{
+		statusPageResponse: internal.BackendWebStatusPageChangeControllerGetResponse{
+			JSON200: &internal.StatusPageChangesResponse{
+				Metadata: &internal.PagingMetadata{},
+				Entries: &[]internal.StatusPageComponentChange{
+					{Component: ptr("component1"), MonitorLogicalName: ptr("monitor"), Status: ptr("operational"), Timestamp: ptr("2022-12-07T17:28:06Z")},
+					{Component: ptr("component1"), MonitorLogicalName: ptr("monitor"), Status: ptr("partial_outage"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+					{Component: ptr("component1"), MonitorLogicalName: ptr("monitor"), Status: ptr("operational"), Timestamp: ptr("2022-12-07T19:28:06Z")},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name           string
+		query          string
+		want           []map[string]string
+		wantStatusText []string
+	}{
+		{
+			name:  "graph",
+			query: `{"monitors": ["monitor"], "statusText": true}`,
+			want: []map[string]string{
+				{"component": "component1", "monitor": "monitor"},
+			},
+			wantStatusText: []string{"operational", "partial_outage", "operational"},
+		},
+		{
+			name:  "alerting",
+			query: `{"monitors": ["monitor"], "statusText": true, "fromAlerting": true}`,
+			want: []map[string]string{
+				{"component": "component1", "monitor": "monitor", "status_text": "operational"},
+			},
+		},
+		{
+			name:  "disabled",
+			query: `{"monitors": ["monitor"]}`,
+			want: []map[string]string{
+				{"component": "component1", "monitor": "monitor"},
+			},
+		},
+	} {
+		query := backend.DataQuery{RefID: "A", JSON: []byte(tc.query), TimeRange: timeRange}
+		resp, err := QueryMonitorStatusPageChanges(context.Background(), query, client, nil, log.DefaultLogger)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make([]map[string]string, 0)
+		var gotStatusText []string
+		for _, frame := range resp.Frames {
+			if frame.Meta.PreferredVisualization == data.VisTypeTable {
+				if field, _ := frame.FieldByName("status_text"); field != nil {
+					for i := 0; i < field.Len(); i++ {
+						gotStatusText = append(gotStatusText, field.At(i).(string))
+					}
+				}
+				continue
+			}
+			got = append(got, frame.Fields[len(frame.Fields)-1].Labels)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: labels mismatch (-want +got):\n%s", tc.name, diff)
+		}
+		if diff := cmp.Diff(tc.wantStatusText, gotStatusText); diff != "" {
+			t.Errorf("%s: status_text mismatch (-want +got):\n%s", tc.name, diff)
+		}
+	}
+}
+
+func TestQueryMonitorStatusPageChangesStatusColors(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	ds := Datasource{
+		dsSettings: datasourceSettings{StatusColorUp: "#1f77b4", StatusColorError: "green"},
+		openApiClient: &stubClient{
+			statusPageResponse: internal.BackendWebStatusPageChangeControllerGetResponse{
+				JSON200: &internal.StatusPageChangesResponse{
+					Metadata: &internal.PagingMetadata{},
+					Entries: &[]internal.StatusPageComponentChange{
+						{Component: ptr("component1"), MonitorLogicalName: ptr("monitor"), Status: ptr("up"), Timestamp: ptr("2022-12-07T17:28:06Z")},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  data.ValueMappings
+	}{
+		{
+			query: `{"monitors": ["awslambda"], "queryType": "GetMonitorStatusPageChanges"}`,
+			want: data.ValueMappings{
+				data.ValueMapper{"0": data.ValueMappingResult{Text: "(0) unknown", Color: "gray"}},
+				data.ValueMapper{"1": data.ValueMappingResult{Text: "(1) maintenance", Color: "blue"}},
+				data.ValueMapper{"2": data.ValueMappingResult{Text: "(2) up", Color: "#1f77b4"}},
+				data.ValueMapper{"3": data.ValueMappingResult{Text: "(3) degraded", Color: "yellow"}},
+				data.ValueMapper{"4": data.ValueMappingResult{Text: "(4) error", Color: "green"}},
+			},
+		},
+		{
+			query: `{"monitors": ["awslambda"], "binaryStatus": true, "queryType": "GetMonitorStatusPageChanges"}`,
+			want: data.ValueMappings{
+				data.ValueMapper{"0": data.ValueMappingResult{Text: "(0) down", Color: "green"}},
+				data.ValueMapper{"1": data.ValueMappingResult{Text: "(1) up", Color: "#1f77b4"}},
+			},
+		},
+	} {
+		resp, err := ds.QueryData(
+			context.Background(),
+			&backend.QueryDataRequest{
+				PluginContext: testPluginContext,
+				Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(tt.query), TimeRange: timeRange}},
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, frame := range resp.Responses["A"].Frames {
+			for _, field := range frame.Fields[1:] {
+				if field.Name == "duration" {
+					continue
+				}
+				if diff := cmp.Diff(tt.want, field.Config.Mappings); diff != "" {
+					t.Errorf("%s: %s mappings mismatch (-want +got):\n%s", tt.query, field.Name, diff)
+				}
+			}
+		}
+	}
+}
+
+func TestNewDatasourceSkipTlsVerify(t *testing.T) {
+	defer func(environment string) { internal.Environment = environment }(internal.Environment)
+
+	tests := []struct {
+		name        string
+		environment string
+		jsonData    string
+		want        bool
+	}{
+		{name: "verifies by default", environment: "local", jsonData: `{}`, want: false},
+		{name: "skips verification when opted in locally", environment: "local", jsonData: `{"skipTlsVerify": true}`, want: true},
+		{name: "ignores the flag outside of local", environment: "prod", jsonData: `{"skipTlsVerify": true}`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			internal.Environment = tt.environment
+			settings := backend.DataSourceInstanceSettings{
+				JSONData:                []byte(tt.jsonData),
+				DecryptedSecureJSONData: map[string]string{"apiKey": "test"},
+			}
+
+			instance, err := NewDatasource(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tlsConfig := &tls.Config{}
+			configureTLS(instance.(*Datasource).dsSettings, nil)(httpclient.Options{}, tlsConfig)
+			if tlsConfig.InsecureSkipVerify != tt.want {
+				t.Errorf("InsecureSkipVerify = %v, want %v", tlsConfig.InsecureSkipVerify, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRootCAs(t *testing.T) {
+	caCert, caPEM := generateTestCA(t)
+
+	rootCAs, err := loadRootCAs(string(caPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConfig := &tls.Config{}
+	configureTLS(datasourceSettings{}, rootCAs)(httpclient.Options{}, tlsConfig)
+	if _, err := caCert.Verify(x509.VerifyOptions{Roots: tlsConfig.RootCAs}); err != nil {
+		t.Errorf("expected the CA to be trusted by the configured roots: %v", err)
+	}
+
+	if rootCAs, err := loadRootCAs(""); err != nil || rootCAs != nil {
+		t.Errorf("loadRootCAs(\"\") = %v, %v, want nil, nil", rootCAs, err)
+	}
+
+	if _, err := loadRootCAs("not a certificate"); err == nil {
+		t.Errorf("loadRootCAs() expected an error for an invalid bundle")
+	}
+}
+
+func generateTestCA(t *testing.T) (*x509.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Metrist Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestQueryMonitorErrorsSortOrder(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	page := &internal.MonitorErrorResponse{
+		Entries: &[]internal.MonitorErrorCount{
+			{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("monitor"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+			{Check: ptr("check"), Count: ptr(2), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("monitor"), Timestamp: ptr("2022-12-07T19:28:06Z")},
+		},
+		Metadata: &internal.PagingMetadata{},
+	}
+	ascending := []time.Time{strToTime("2022-12-07T18:28:06Z"), strToTime("2022-12-07T19:28:06Z")}
+	descending := []time.Time{strToTime("2022-12-07T19:28:06Z"), strToTime("2022-12-07T18:28:06Z")}
+
+	tests := []struct {
+		name      string
+		sortOrder string
+		wantTable []time.Time
+	}{
+		{name: "defaults to ascending", sortOrder: "", wantTable: ascending},
+		{name: "sorts ascending", sortOrder: "asc", wantTable: ascending},
+		{name: "sorts descending", sortOrder: "desc", wantTable: descending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := Datasource{openApiClient: &stubClient{errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{JSON200: page}}}
+			query := []byte(`{"monitors": ["monitor"], "queryType": "GetMonitorErrors", "sortOrder": "` + tt.sortOrder + `"}`)
+			resp, err := ds.QueryData(
+				context.Background(),
+				&backend.QueryDataRequest{
+					PluginContext: testPluginContext,
+					Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange}},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, frame := range resp.Responses["A"].Frames {
+				want := ascending
+				if frame.Meta.PreferredVisualization == data.VisTypeTable {
+					want = tt.wantTable
+				}
+				got := []time.Time{frame.Fields[0].At(0).(time.Time), frame.Fields[0].At(1).(time.Time)}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("%s frame order mismatch (-want +got):\n%s", frame.Meta.PreferredVisualization, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryMonitorErrorsSortByValue(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	page := &internal.MonitorErrorResponse{
+		Entries: &[]internal.MonitorErrorCount{
+			{Check: ptr("check"), Count: ptr(2), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("monitor"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+			{Check: ptr("check"), Count: ptr(5), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("monitor"), Timestamp: ptr("2022-12-07T19:28:06Z")},
+			{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("monitor"), Timestamp: ptr("2022-12-07T20:28:06Z")},
+		},
+		Metadata: &internal.PagingMetadata{},
+	}
+
+	tests := []struct {
+		name        string
+		sortByValue string
+		wantTable   []float64
+	}{
+		{name: "keeps the time order when unset", sortByValue: "", wantTable: []float64{2, 5, 1}},
+		{name: "sorts ascending", sortByValue: "asc", wantTable: []float64{1, 2, 5}},
+		{name: "sorts descending", sortByValue: "desc", wantTable: []float64{5, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := Datasource{openApiClient: &stubClient{errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{JSON200: page}}}
+			query := []byte(`{"monitors": ["monitor"], "queryType": "GetMonitorErrors", "sortByValue": "` + tt.sortByValue + `"}`)
+			resp, err := ds.QueryData(
+				context.Background(),
+				&backend.QueryDataRequest{
+					PluginContext: testPluginContext,
+					Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange}},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, frame := range resp.Responses["A"].Frames {
+				want := []float64{2, 5, 1}
+				if frame.Meta.PreferredVisualization == data.VisTypeTable {
+					want = tt.wantTable
+				}
+				got := make([]float64, 0)
+				for _, field := range frame.Fields {
+					if !field.Type().Numeric() {
+						continue
+					}
+					for i := 0; i < field.Len(); i++ {
+						value, _ := field.NullableFloatAt(i)
+						got = append(got, *value)
+					}
+					break
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("%s frame order mismatch (-want +got):\n%s", frame.Meta.PreferredVisualization, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigureHTTPClientOptions(t *testing.T) {
+	opts := httpclient.Options{Timeouts: &httpclient.TimeoutOptions{MaxIdleConns: 100, MaxIdleConnsPerHost: 100}}
+	configureHTTPClientOptions(&opts, datasourceSettings{MaxIdleConns: 5, MaxConnsPerHost: 10})
+	if opts.Timeouts.MaxIdleConns != 5 || opts.Timeouts.MaxIdleConnsPerHost != 5 {
+		t.Errorf("idle connections not applied: %+v", opts.Timeouts)
+	}
+	if opts.Timeouts.MaxConnsPerHost != 10 {
+		t.Errorf("MaxConnsPerHost = %d, want 10", opts.Timeouts.MaxConnsPerHost)
+	}
+
+	opts = httpclient.Options{}
+	configureHTTPClientOptions(&opts, datasourceSettings{})
+	if diff := cmp.Diff(httpclient.DefaultTimeoutOptions, *opts.Timeouts); diff != "" {
+		t.Errorf("expected the default options when unset (-want +got):\n%s", diff)
+	}
+}
+
+func TestConfigureHTTPClientOptionsTimeout(t *testing.T) {
+	opts := httpclient.Options{Timeouts: &httpclient.TimeoutOptions{Timeout: 5 * time.Minute}}
+	configureHTTPClientOptions(&opts, datasourceSettings{})
+	if opts.Timeouts.Timeout != 5*time.Minute {
+		t.Errorf("Timeout = %v, want the one Grafana set, 5m", opts.Timeouts.Timeout)
+	}
+
+	configureHTTPClientOptions(&opts, datasourceSettings{RequestTimeoutSeconds: 12})
+	if opts.Timeouts.Timeout != 12*time.Second {
+		t.Errorf("Timeout = %v, want 12s", opts.Timeouts.Timeout)
+	}
+}
+
+func TestQueryTopErrors(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	page := &internal.MonitorErrorResponse{
+		Entries: &[]internal.MonitorErrorCount{
+			{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon1"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+			{Check: ptr("check"), Count: ptr(5), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon2"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+			{Check: ptr("check"), Count: ptr(3), Instance: ptr("us-west-1"), MonitorLogicalName: ptr("mon1"), Timestamp: ptr("2022-12-07T19:28:06Z")},
+			{Check: ptr("check"), Count: ptr(2), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon3"), Timestamp: ptr("2022-12-07T19:28:06Z")},
+		},
+		Metadata: &internal.PagingMetadata{},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  data.Frames
+	}{
+		{
+			name:  "returns all monitors sorted by total errors",
+			query: `{"monitors": ["mon1", "mon2", "mon3"], "queryType": "GetTopErrors"}`,
+			want: data.Frames{{
+				Name: "top errors",
+				Fields: []*data.Field{
+					data.NewField("monitor", nil, []string{"mon2", "mon1", "mon3"}),
+					data.NewField("count", nil, []int64{5, 4, 2}),
+				},
+				Meta: &data.FrameMeta{PreferredVisualization: data.VisTypeTable},
+			}},
+		},
+		{
+			name:  "truncates to the top N monitors",
+			query: `{"monitors": ["mon1", "mon2", "mon3"], "queryType": "GetTopErrors", "topN": 2}`,
+			want: data.Frames{{
+				Name: "top errors",
+				Fields: []*data.Field{
+					data.NewField("monitor", nil, []string{"mon2", "mon1"}),
+					data.NewField("count", nil, []int64{5, 4}),
+				},
+				Meta: &data.FrameMeta{PreferredVisualization: data.VisTypeTable},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := Datasource{openApiClient: &stubClient{errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{JSON200: page}}}
+			resp, err := ds.QueryData(
+				context.Background(),
+				&backend.QueryDataRequest{
+					PluginContext: testPluginContext,
+					Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(tt.query), TimeRange: timeRange}},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQueryMonitorErrorsSharedLabel(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	page := &internal.MonitorErrorResponse{
+		Entries: &[]internal.MonitorErrorCount{
+			{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("monitor"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+		},
+		Metadata: &internal.PagingMetadata{},
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantShared []string
+	}{
+		{name: "labels shared and owned series when shared data is included", query: `{"monitors": ["monitor"], "includeShared": true, "queryType": "GetMonitorErrors"}`, wantShared: []string{"false", "true"}},
+		{name: "does not label series when shared data is excluded", query: `{"monitors": ["monitor"], "includeShared": false, "queryType": "GetMonitorErrors"}`, wantShared: []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := Datasource{openApiClient: &stubClient{errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{JSON200: page}}}
+			resp, err := ds.QueryData(
+				context.Background(),
+				&backend.QueryDataRequest{
+					PluginContext: testPluginContext,
+					Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(tt.query), TimeRange: timeRange}},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			shared := make([]string, 0)
+			for _, frame := range resp.Responses["A"].Frames {
+				if frame.Meta.PreferredVisualization == data.VisTypeGraph {
+					shared = append(shared, frame.Fields[1].Labels["shared"])
+				}
+			}
+			sort.Strings(shared)
+			if diff := cmp.Diff(tt.wantShared, shared); diff != "" {
+				t.Errorf("shared labels mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQueryMonitorTelemetryMonitorNames(t *testing.T) {
+	var value float32 = 100
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	query := []byte(`{"monitors": ["awslambda"], "queryType": "GetMonitorTelemetry", "fromAlerting": true}`)
+	ds := Datasource{openApiClient: &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			JSON200: &internal.MonitorTelemetryResponse{internal.MonitorTelemetry{
+				Check:              ptr("Check"),
+				Instance:           ptr("us-east-1"),
+				MonitorLogicalName: ptr("awslambda"),
+				Timestamp:          ptr("2022-12-07T18:28:06.485416Z"),
+				Value:              &value,
+			}},
+		},
+		monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+			JSON200: &internal.MonitorListResponse{{LogicalName: ptr("awslambda"), Name: ptr("AWS Lambda")}},
+		},
+	}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := data.Labels{"instance": "us-east-1", "check": "Check", "monitor": "awslambda", "monitor_name": "AWS Lambda"}
+	if diff := cmp.Diff(want, resp.Responses["A"].Frames[0].Fields[1].Labels); diff != "" {
+		t.Errorf("labels mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryMonitorTelemetryMonitorAliases(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	query := []byte(`{"monitors": ["awslambda", "github"], "queryType": "GetMonitorTelemetry", "fromAlerting": true}`)
+	ds := Datasource{
+		dsSettings: datasourceSettings{MonitorAliases: map[string]string{"awslambda": "Lambda"}},
+		openApiClient: &stubClient{
+			telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+				JSON200: &internal.MonitorTelemetryResponse{
+					{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](100)},
+					{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("github"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](100)},
+				},
+			},
+			monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+				JSON200: &internal.MonitorListResponse{{LogicalName: ptr("awslambda"), Name: ptr("AWS Lambda")}, {LogicalName: ptr("github"), Name: ptr("GitHub")}},
+			},
+		},
+	}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := resp.Responses["A"].Frames
+	if got := frames[0].Fields[1].Labels["monitor_name"]; got != "Lambda" {
+		t.Errorf("expected the alias to override the monitor name, got %q", got)
+	}
+	if got := frames[1].Fields[1].Labels["monitor_name"]; got != "GitHub" {
+		t.Errorf("expected the monitor name without an alias, got %q", got)
+	}
+}
+
+type countingMonitorListClient struct {
+	stubClient
+	calls int
+}
+
+func (m *countingMonitorListClient) BackendWebMonitorListControllerGetWithResponse(ctx context.Context,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorListControllerGetResponse, error) {
+	m.calls++
+	return m.stubClient.BackendWebMonitorListControllerGetWithResponse(ctx, reqEditors...)
+}
+
+func TestMonitorNameCache(t *testing.T) {
+	client := &countingMonitorListClient{stubClient: stubClient{monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+		JSON200: &internal.MonitorListResponse{{LogicalName: ptr("awslambda"), Name: ptr("AWS Lambda")}},
+	}}}
+
+	cache := monitorNameCache{}
+	for i := 0; i < 3; i++ {
+		names, err := cache.get(context.Background(), "", client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names["awslambda"] != "AWS Lambda" {
+			t.Errorf("names = %v, want awslambda mapped to AWS Lambda", names)
+		}
+	}
+	if client.calls != 1 {
+		t.Errorf("monitor list fetched %d times, want 1", client.calls)
+	}
+
+	// Every environment has its own monitors, so its names are cached separately
+	if _, err := cache.get(context.Background(), "prod", client); err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != 2 {
+		t.Errorf("monitor list fetched %d times, want 2 once another environment is used", client.calls)
+	}
+}
+
+func TestQueryMonitorOverview(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	query := []byte(`{"monitors": ["awslambda"], "queryType": "GetMonitorOverview"}`)
+	labels := data.Labels{"instance": "us-east-1", "check": "Check", "monitor": "awslambda"}
+	ds := Datasource{openApiClient: &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			JSON200: &internal.MonitorTelemetryResponse{
+				{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:00:10Z"), Value: ptr[float32](100)},
+				{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:00:40Z"), Value: ptr[float32](200)},
+				{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:02:00Z"), Value: ptr[float32](300)},
+			},
+		},
+		errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+			JSON200: &internal.MonitorErrorResponse{
+				Entries: &[]internal.MonitorErrorCount{
+					{Check: ptr("Check"), Count: ptr(2), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:00:30Z")},
+					{Check: ptr("Check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:01:00Z")},
+				},
+				Metadata: &internal.PagingMetadata{},
+			},
+		},
+	}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange, Interval: time.Minute}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := data.Frames{{
+		Name: "overview",
+		Fields: []*data.Field{
+			data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:00:00Z"), strToTime("2022-12-07T18:01:00Z"), strToTime("2022-12-07T18:02:00Z")}),
+			data.NewField("response time (ms)", labels, []*float32{ptr[float32](150), nil, ptr[float32](300)}),
+			data.NewField("count", labels, []*int64{ptr[int64](2), ptr[int64](1), nil}),
+		},
+		Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide, PreferredVisualization: data.VisTypeGraph, Stats: wantFrameStats(2, 6)},
+	}}
+	if diff := cmp.Diff(want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryMonitorTelemetryLastN(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	query := []byte(`{"monitors": ["awslambda"], "queryType": "GetMonitorTelemetry", "lastN": 2}`)
+	telemetry := internal.MonitorTelemetryResponse{}
+	for i, instance := range []string{"us-east-1", "us-west-1"} {
+		for minute := 0; minute < 4; minute++ {
+			telemetry = append(telemetry, internal.MonitorTelemetry{
+				Check:              ptr("Check"),
+				Instance:           ptr(instance),
+				MonitorLogicalName: ptr("awslambda"),
+				Timestamp:          ptr(strToTime("2022-12-07T18:00:00Z").Add(time.Duration(minute) * time.Minute).Format(time.RFC3339)),
+				Value:              ptr(float32(i*10 + minute)),
+			})
+		}
+	}
+	ds := Datasource{openApiClient: &stubClient{telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{JSON200: &telemetry}}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := resp.Responses["A"].Frames
+	if len(frames) != 3 {
+		t.Fatalf("expected 2 graph frames and 1 table frame, got %d", len(frames))
+	}
+	wantValues := [][]float32{{2, 3}, {12, 13}, {12, 13}}
+	for i, frame := range frames {
+		got := []float32{*frame.Fields[1].At(0).(*float32), *frame.Fields[1].At(1).(*float32)}
+		if frame.Rows() != 2 {
+			t.Errorf("frame %d has %d rows, want 2", i, frame.Rows())
+		}
+		if diff := cmp.Diff(wantValues[i], got); diff != "" {
+			t.Errorf("frame %d values mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestQueryMonitorTelemetryWithBands(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	query := []byte(`{"monitors": ["awslambda"], "queryType": "GetMonitorTelemetry", "withBands": true}`)
+	telemetry := internal.MonitorTelemetryResponse{}
+	for i, value := range []float32{5, 15, 10, 30} {
+		telemetry = append(telemetry, internal.MonitorTelemetry{
+			Check:              ptr("Check"),
+			Instance:           ptr("us-east-1"),
+			MonitorLogicalName: ptr("awslambda"),
+			Timestamp:          ptr(strToTime("2022-12-07T18:00:00Z").Add(time.Duration(i) * 20 * time.Second).Format(time.RFC3339)),
+			Value:              ptr(value),
+		})
+	}
+	ds := Datasource{openApiClient: &stubClient{telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{JSON200: &telemetry}}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange, Interval: time.Minute}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := resp.Responses["A"].Frames
+	if len(frames) != 2 {
+		t.Fatalf("expected 1 graph frame and 1 table frame, got %d", len(frames))
+	}
+	graph := frames[0]
+	got := [][]float32{}
+	for _, field := range graph.Fields[1:] {
+		values := make([]float32, field.Len())
+		for i := range values {
+			values[i] = *field.At(i).(*float32)
+		}
+		got = append(got, values)
+	}
+	if diff := cmp.Diff([][]float32{{10, 30}, {5, 30}, {15, 30}}, got); diff != "" {
+		t.Errorf("band values mismatch (-want +got):\n%s", diff)
+	}
+	if frames[1].Rows() != 4 {
+		t.Errorf("table frame has %d rows, want the 4 raw samples", frames[1].Rows())
+	}
+}
+
+// periodClient returns a single telemetry and error entry at the start of each requested range
+type periodClient struct {
+	stubClient
+}
+
+func (m *periodClient) BackendWebMonitorTelemetryControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorTelemetryControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorTelemetryControllerGetResponse, error) {
+	telemetry := internal.MonitorTelemetryResponse{{
+		Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"),
+		Timestamp: ptr(params.From.UTC().Format(time.RFC3339)), Value: ptr(float32(10)),
+	}}
+	return &internal.BackendWebMonitorTelemetryControllerGetResponse{JSON200: &telemetry}, nil
+}
+
+func (m *periodClient) BackendWebMonitorErrorControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorErrorControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorErrorControllerGetResponse, error) {
+	entries := []internal.MonitorErrorCount{{
+		Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"),
+		Timestamp: ptr(params.From.UTC().Format(time.RFC3339)), Count: ptr(1),
+	}}
+	return &internal.BackendWebMonitorErrorControllerGetResponse{JSON200: &internal.MonitorErrorResponse{Entries: &entries, Metadata: &internal.PagingMetadata{}}}, nil
+}
+
+func TestQueryDataCompareOffset(t *testing.T) {
+	from := time.Now().Add(-time.Hour).Truncate(time.Second)
+	timeRange := backend.TimeRange{From: from, To: from.Add(time.Hour)}
+	ds := Datasource{openApiClient: &periodClient{}}
+
+	for _, queryType := range []string{"GetMonitorTelemetry", "GetMonitorErrors"} {
+		query := []byte(`{"monitors": ["awslambda"], "queryType": "` + queryType + `", "fromAlerting": true, "compareOffset": "168h"}`)
+		resp, err := ds.QueryData(
+			context.Background(),
+			&backend.QueryDataRequest{
+				PluginContext: testPluginContext,
+				Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange}},
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frames := resp.Responses["A"].Frames
+		if len(frames) != 2 {
+			t.Fatalf("%s: expected a current and a previous period series, got %d frames", queryType, len(frames))
+		}
+		if period := frames[0].Fields[1].Labels["period"]; period != "" {
+			t.Errorf("%s: expected the current series without a period label, got %q", queryType, period)
+		}
+		if period := frames[1].Fields[1].Labels["period"]; period != "previous" {
+			t.Errorf("%s: expected the previous series labeled period:previous, got %q", queryType, period)
+		}
+		// The previous period was fetched a week back and shifted onto the current range
+		for i, frame := range frames {
+			if got := frame.Fields[0].At(0).(time.Time); !got.Equal(from) {
+				t.Errorf("%s: frame %d starts at %v, want %v", queryType, i, got, from)
+			}
+		}
+	}
+
+	query := []byte(`{"monitors": ["awslambda"], "queryType": "GetMonitorErrors", "compareOffset": "a week"}`)
+	resp, _ := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange}},
+		},
+	)
+	if resp.Responses["A"].Error == nil {
+		t.Errorf("expected an error for an invalid offset")
+	}
+}
+
+func TestQueryDataRateLimited(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	rateLimited := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"30"}}}
+	ds := Datasource{openApiClient: &stubClient{
+		telemetryResponse:  internal.BackendWebMonitorTelemetryControllerGetResponse{HTTPResponse: rateLimited},
+		errorResponse:      internal.BackendWebMonitorErrorControllerGetResponse{HTTPResponse: rateLimited},
+		statusPageResponse: internal.BackendWebStatusPageChangeControllerGetResponse{HTTPResponse: rateLimited},
+	}}
+
+	for _, queryType := range []string{"GetMonitorErrors", "GetMonitorTelemetry", "GetMonitorStatusPageChanges"} {
+		t.Run(queryType, func(t *testing.T) {
+			query := []byte(`{"monitors": ["awslambda"], "queryType": "` + queryType + `"}`)
+			resp, err := ds.QueryData(
+				context.Background(),
+				&backend.QueryDataRequest{
+					PluginContext: testPluginContext,
+					Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange}},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := resp.Responses["A"]
+			if res.Status != backend.StatusTooManyRequests {
+				t.Errorf("status = %v, want %v", res.Status, backend.StatusTooManyRequests)
+			}
+			if res.Error == nil || !strings.Contains(res.Error.Error(), "retry after 30") {
+				t.Errorf("error = %v, want it to include the Retry-After value", res.Error)
+			}
+		})
+	}
+}
+
+// wantFrameStats returns the stats applyFrameStats adds to a graph frame
+func wantFrameStats(series, points int) []data.QueryStat {
+	return []data.QueryStat{
+		{FieldConfig: data.FieldConfig{DisplayName: "Series"}, Value: float64(series)},
+		{FieldConfig: data.FieldConfig{DisplayName: "Total points"}, Value: float64(points)},
+	}
+}
+
+func TestApplyTimeRangeOverride(t *testing.T) {
+	dashboardRange := backend.TimeRange{From: strToTime("2022-12-07T00:00:00Z"), To: strToTime("2022-12-08T00:00:00Z")}
+	tests := []struct {
+		name    string
+		json    string
+		want    backend.TimeRange
+		wantErr bool
+	}{
+		{name: "no override", json: `{}`, want: dashboardRange},
+		{
+			name: "from and to override",
+			json: `{"fromOverride": "2022-11-01T00:00:00Z", "toOverride": "2022-11-02T00:00:00Z"}`,
+			want: backend.TimeRange{From: strToTime("2022-11-01T00:00:00Z"), To: strToTime("2022-11-02T00:00:00Z")},
+		},
+		{
+			name: "from override only",
+			json: `{"fromOverride": "2022-12-07T12:00:00Z"}`,
+			want: backend.TimeRange{From: strToTime("2022-12-07T12:00:00Z"), To: dashboardRange.To},
+		},
+		{name: "invalid timestamp", json: `{"toOverride": "yesterday"}`, wantErr: true},
+		{name: "to before from", json: `{"toOverride": "2022-12-06T00:00:00Z"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := backend.DataQuery{JSON: []byte(tt.json), TimeRange: dashboardRange}
+			err := applyTimeRangeOverride(&q)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyTimeRangeOverride() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !(q.TimeRange.From.Equal(tt.want.From) && q.TimeRange.To.Equal(tt.want.To)) {
+				t.Errorf("time range = %v - %v, want %v - %v", q.TimeRange.From, q.TimeRange.To, tt.want.From, tt.want.To)
+			}
+		})
+	}
+}
+
+func TestQueryDataTimeRangeOverrideLimits(t *testing.T) {
+	ds := Datasource{openApiClient: &stubClient{}}
+	dashboardRange := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantError error
+	}{
+		{
+			name:      "override outside the telemetry window",
+			query:     `{"monitors": ["awslambda"], "queryType": "GetMonitorTelemetry", "fromOverride": "` + time.Now().Add(-100*24*time.Hour).Format(time.RFC3339) + `", "toOverride": "` + time.Now().Add(-95*24*time.Hour).Format(time.RFC3339) + `"}`,
+			wantError: errTelemetryRequestedOutsideBounds,
+		},
+		{
+			name:      "override longer than the time range limit",
+			query:     `{"monitors": ["awslambda"], "queryType": "GetMonitorErrors", "fromOverride": "2020-01-01T00:00:00Z"}`,
+			wantError: errTimerangeLimitExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := ds.QueryData(
+				context.Background(),
+				&backend.QueryDataRequest{
+					PluginContext: testPluginContext,
+					Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(tt.query), TimeRange: dashboardRange}},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := resp.Responses["A"]
+			if res.Error == nil || !strings.Contains(res.Error.Error(), tt.wantError.Error()) {
+				t.Errorf("error = %v, want %v", res.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestEnsureTimeRangeWithinLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration time.Duration
+		limit    time.Duration
+		wantErr  string
+	}{
+		{name: "within the default limit", duration: 30 * 24 * time.Hour, limit: datasourceSettings{}.timeRangeLimit()},
+		{name: "beyond the default limit", duration: 120 * 24 * time.Hour, limit: datasourceSettings{}.timeRangeLimit(), wantErr: "time range limit exceeded: the requested 120 days cannot exceed 90 days"},
+		{name: "beyond a configured retention", duration: 36 * time.Hour, limit: datasourceSettings{RetentionDays: 1}.timeRangeLimit(), wantErr: "time range limit exceeded: the requested 1.5 days cannot exceed 1 day"},
+		{name: "within a configured retention", duration: 60 * 24 * time.Hour, limit: datasourceSettings{RetentionDays: 60}.timeRangeLimit()},
+		{name: "beyond a retention longer than the API keeps", duration: 120 * 24 * time.Hour, limit: datasourceSettings{RetentionDays: 365}.timeRangeLimit(), wantErr: "time range limit exceeded: the requested 120 days cannot exceed 90 days"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ensureTimeRangeWithinLimits(tt.duration, tt.limit)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, errTimerangeLimitExceeded) || err.Error() != tt.wantErr {
+				t.Errorf("error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuerySloBurnRate(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	// 8 good checks and 2 errors in the first hour, 10 good checks in the second
+	telemetry := internal.MonitorTelemetryResponse{}
+	for hour, good := range []int{8, 10} {
+		for minute := 0; minute < good; minute++ {
+			telemetry = append(telemetry, internal.MonitorTelemetry{
+				Check:              ptr("Check"),
+				Instance:           ptr("us-east-1"),
+				MonitorLogicalName: ptr("awslambda"),
+				Timestamp:          ptr(strToTime("2022-12-07T18:00:00Z").Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute).Format(time.RFC3339)),
+				Value:              ptr[float32](100),
+			})
+		}
+	}
+	ds := Datasource{openApiClient: &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{JSON200: &telemetry},
+		errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+			JSON200: &internal.MonitorErrorResponse{
+				Entries: &[]internal.MonitorErrorCount{
+					{Check: ptr("Check"), Count: ptr(2), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:30:00Z")},
+				},
+				Metadata: &internal.PagingMetadata{},
+			},
+		},
+	}}
+
+	tests := []struct {
+		name       string
+		query      string
+		want       []float64
+		wantStatus backend.Status
+	}{
+		{
+			name:  "computes the burn rate per window",
+			query: `{"monitors": ["awslambda"], "queryType": "GetSloBurnRate", "sloTarget": 90, "window": "1h"}`,
+			// 20% errors against a 10% budget burns it twice as fast as allowed
+			want: []float64{2, 0},
+		},
+		{
+			name:       "rejects an invalid target",
+			query:      `{"monitors": ["awslambda"], "queryType": "GetSloBurnRate", "sloTarget": 100}`,
+			wantStatus: backend.StatusInternal,
+		},
+		{
+			name:       "rejects an invalid window",
+			query:      `{"monitors": ["awslambda"], "queryType": "GetSloBurnRate", "sloTarget": 99.9, "window": "soon"}`,
+			wantStatus: backend.StatusInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := ds.QueryData(
+				context.Background(),
+				&backend.QueryDataRequest{
+					PluginContext: testPluginContext,
+					Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(tt.query), TimeRange: timeRange}},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := resp.Responses["A"]
+			if tt.want == nil {
+				if res.Error == nil || res.Status != tt.wantStatus {
+					t.Errorf("status = %v, error = %v, want status %v with an error", res.Status, res.Error, tt.wantStatus)
+				}
+				return
+			}
+			if res.Error != nil {
+				t.Fatal(res.Error)
+			}
+
+			burnRate := res.Frames[0].Fields[1]
+			if burnRate.Len() != len(tt.want) {
+				t.Fatalf("got %d burn rate points, want %d", burnRate.Len(), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := *burnRate.At(i).(*float64)
+				if math.Abs(got-want) > 1e-9 {
+					t.Errorf("burn rate %d = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyDefaultIncludeShared(t *testing.T) {
+	tests := []struct {
+		name                 string
+		json                 string
+		defaultIncludeShared bool
+		want                 bool
+	}{
+		{name: "present true", json: `{"monitors": ["awslambda"], "includeShared": true}`, defaultIncludeShared: false, want: true},
+		{name: "present false overrides the default", json: `{"monitors": ["awslambda"], "includeShared": false}`, defaultIncludeShared: true, want: false},
+		{name: "absent uses the default", json: `{"monitors": ["awslambda"]}`, defaultIncludeShared: true, want: true},
+		{name: "absent without default", json: `{"monitors": ["awslambda"]}`, defaultIncludeShared: false, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := backend.DataQuery{JSON: []byte(tt.json)}
+			if err := applyDefaultIncludeShared(&q, tt.defaultIncludeShared); err != nil {
+				t.Fatal(err)
+			}
+
+			var query monitorTelemetryQuery
+			if err := json.Unmarshal(q.JSON, &query); err != nil {
+				t.Fatal(err)
+			}
+			if got := query.includeShared(); got != tt.want {
+				t.Errorf("includeShared() = %v, want %v", got, tt.want)
+			}
+			if len(query.Monitors) != 1 {
+				t.Errorf("expected the rest of the query to be kept, got %s", q.JSON)
+			}
+		})
+	}
+}
+
+func TestQueryErrorRatio(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	telemetryAt := func(instance string, timestamp string) internal.MonitorTelemetry {
+		return internal.MonitorTelemetry{Check: ptr("Check"), Instance: ptr(instance), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr(timestamp), Value: ptr[float32](100)}
+	}
+	ds := Datasource{openApiClient: &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			JSON200: &internal.MonitorTelemetryResponse{
+				telemetryAt("us-east-1", "2022-12-07T18:00:10Z"),
+				telemetryAt("us-east-1", "2022-12-07T18:00:20Z"),
+				telemetryAt("us-east-1", "2022-12-07T18:00:30Z"),
+				telemetryAt("us-east-1", "2022-12-07T18:01:10Z"),
+				telemetryAt("us-west-1", "2022-12-07T18:00:10Z"),
+			},
+		},
+		errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+			JSON200: &internal.MonitorErrorResponse{
+				Entries: &[]internal.MonitorErrorCount{
+					{Check: ptr("Check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:00:40Z")},
+					{Check: ptr("Check"), Count: ptr(1), Instance: ptr("us-west-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:00:40Z")},
+				},
+				Metadata: &internal.PagingMetadata{},
+			},
+		},
+	}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      []byte(`{"monitors": ["awslambda"], "queryType": "GetErrorRatio"}`),
+				TimeRange: timeRange,
+				Interval:  time.Minute,
+			}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Responses["A"].Error != nil {
+		t.Fatal(resp.Responses["A"].Error)
+	}
+
+	want := data.Frames{{
+		Name: "error ratio",
+		Fields: []*data.Field{
+			data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:00:00Z"), strToTime("2022-12-07T18:01:00Z")}),
+			// 1 error out of 4 runs, then 1 clean run
+			data.NewField("error_ratio", data.Labels{"instance": "us-east-1", "check": "Check", "monitor": "awslambda"}, []*float64{ptr(0.25), ptr(0.0)}),
+			// 1 error out of 2 runs, then no runs at all
+			data.NewField("error_ratio", data.Labels{"instance": "us-west-1", "check": "Check", "monitor": "awslambda"}, []*float64{ptr(0.5), nil}),
+		},
+		Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide, PreferredVisualization: data.VisTypeGraph, Stats: wantFrameStats(2, 4)},
+	}}
+	if diff := cmp.Diff(want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryStatusPageAvailability(t *testing.T) {
+	timeRange := backend.TimeRange{From: strToTime("2022-12-07T00:00:00Z"), To: strToTime("2022-12-07T04:00:00Z")}
+	change := func(component string, status string, timestamp string) internal.StatusPageComponentChange {
+		return internal.StatusPageComponentChange{Component: ptr(component), MonitorLogicalName: ptr("awslambda"), Status: ptr(status), Timestamp: ptr(timestamp)}
+	}
+	client := &stubClient{
+		statusPageResponse: internal.BackendWebStatusPageChangeControllerGetResponse{
+			JSON200: &internal.StatusPageChangesResponse{
+				Metadata: &internal.PagingMetadata{},
+				Entries: &[]internal.StatusPageComponentChange{
+					// Invocations is up the whole time range
+					change("Invocations", "operational", "2022-12-07T00:00:00Z"),
+					// The console is down for half of the time it is not under maintenance
+					change("Console", "major_outage", "2022-12-07T00:00:00Z"),
+					change("Console", "under_maintenance", "2022-12-07T01:00:00Z"),
+					change("Console", "operational", "2022-12-07T03:00:00Z"),
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  float64
+	}{
+		{name: "weighs components equally by default", query: `{"monitors": ["awslambda"]}`, want: 75},
+		{name: "weighs components by componentWeights", query: `{"monitors": ["awslambda"], "componentWeights": {"Invocations": 3}}`, want: 87.5},
+		{name: "leaves out components weighing nothing", query: `{"monitors": ["awslambda"], "componentWeights": {"Console": 0}}`, want: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := QueryStatusPageAvailability(context.Background(), backend.DataQuery{JSON: []byte(tt.query), TimeRange: timeRange}, client, log.DefaultLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := data.NewField("availability", data.Labels{"monitor": "awslambda"}, []float64{tt.want}).SetConfig(&data.FieldConfig{Unit: "percent"})
+			if len(resp.Frames) != 1 || len(resp.Frames[0].Fields) != 1 {
+				t.Fatalf("expected a single availability field, got %v", resp.Frames)
+			}
+			if diff := cmp.Diff(want, resp.Frames[0].Fields[0], data.FrameTestCompareOptions()...); diff != "" {
+				t.Errorf("availability mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// timeRangeStatusPageClient returns the status page changes falling within the requested time range. Requests
+// ending before busyBefore always get a cursor to a next page, as for a monitor with many changes
+type timeRangeStatusPageClient struct {
+	stubClient
+	changes    []internal.StatusPageComponentChange
+	busyBefore time.Time
+}
+
+func (c *timeRangeStatusPageClient) BackendWebStatusPageChangeControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebStatusPageChangeControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebStatusPageChangeControllerGetResponse, error) {
+	entries := make([]internal.StatusPageComponentChange, 0)
+	for _, change := range c.changes {
+		timestamp := strToTime(*change.Timestamp)
+		if !timestamp.Before(params.From) && !timestamp.After(*params.To) {
+			entries = append(entries, change)
+		}
+	}
+	metadata := &internal.PagingMetadata{}
+	if params.To.Before(c.busyBefore) {
+		metadata.CursorAfter = ptr("next")
+	}
+	return &internal.BackendWebStatusPageChangeControllerGetResponse{
+		JSON200: &internal.StatusPageChangesResponse{Entries: &entries, Metadata: metadata},
+	}, nil
+}
+
+func TestQueryStatusPageAvailabilityEarlierChanges(t *testing.T) {
+	timeRange := backend.TimeRange{From: strToTime("2022-12-07T00:00:00Z"), To: strToTime("2022-12-07T04:00:00Z")}
+	change := func(component string, status string, timestamp string) internal.StatusPageComponentChange {
+		return internal.StatusPageComponentChange{Component: ptr(component), MonitorLogicalName: ptr("awslambda"), Status: ptr(status), Timestamp: ptr(timestamp)}
+	}
+	client := &timeRangeStatusPageClient{changes: []internal.StatusPageComponentChange{
+		// The console went down the day before and recovers an hour into the time range
+		change("Console", "major_outage", "2022-12-06T12:00:00Z"),
+		change("Console", "operational", "2022-12-07T01:00:00Z"),
+		// Invocations went down before the time range and did not change within it
+		change("Invocations", "operational", "2022-12-05T00:00:00Z"),
+		change("Invocations", "major_outage", "2022-12-06T00:00:00Z"),
+	}}
+
+	resp, err := QueryStatusPageAvailability(context.Background(), backend.DataQuery{JSON: []byte(`{"monitors": ["awslambda"]}`), TimeRange: timeRange}, client, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The console is up for 3 of the 4 hours and invocations are down throughout
+	want := data.NewField("availability", data.Labels{"monitor": "awslambda"}, []float64{37.5}).SetConfig(&data.FieldConfig{Unit: "percent"})
+	if len(resp.Frames) != 1 || len(resp.Frames[0].Fields) != 1 {
+		t.Fatalf("expected a single availability field, got %v", resp.Frames)
+	}
+	if diff := cmp.Diff(want, resp.Frames[0].Fields[0], data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("availability mismatch (-want +got):\n%s", diff)
+	}
+	if notices := resp.Frames[0].Meta.Notices; len(notices) != 0 {
+		t.Errorf("expected no notices, got %v", notices)
+	}
+
+	// Too many earlier changes to page through leave the starting statuses unknown, which is reported
+	client.busyBefore = timeRange.From
+	resp, err = QueryStatusPageAvailability(context.Background(), backend.DataQuery{JSON: []byte(`{"monitors": ["awslambda"]}`), TimeRange: timeRange}, client, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notices := resp.Frames[0].Meta.Notices
+	if len(notices) != 1 || notices[0].Severity != data.NoticeSeverityWarning || !strings.Contains(notices[0].Text, "before the time range were truncated") {
+		t.Errorf("expected a warning about the truncated earlier changes, got %v", notices)
+	}
+}
+
+func TestQueryInstanceCount(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	telemetryAt := func(monitor string, check string, instance string, timestamp string) internal.MonitorTelemetry {
+		return internal.MonitorTelemetry{Check: ptr(check), Instance: ptr(instance), MonitorLogicalName: ptr(monitor), Timestamp: ptr(timestamp), Value: ptr[float32](100)}
+	}
+	ds := Datasource{openApiClient: &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			JSON200: &internal.MonitorTelemetryResponse{
+				telemetryAt("awslambda", "Ping", "us-east-1", "2022-12-07T18:00:10Z"),
+				telemetryAt("awslambda", "Login", "us-east-1", "2022-12-07T18:00:20Z"),
+				telemetryAt("awslambda", "Ping", "us-west-1", "2022-12-07T18:00:30Z"),
+				telemetryAt("awslambda", "Ping", "us-east-1", "2022-12-07T18:01:10Z"),
+				telemetryAt("azuread", "Ping", "eu-west-1", "2022-12-07T18:02:10Z"),
+			},
+		},
+		errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+			JSON200: &internal.MonitorErrorResponse{
+				Entries: &[]internal.MonitorErrorCount{
+					{Check: ptr("Ping"), Count: ptr(1), Instance: ptr("ca-central-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:00:40Z")},
+				},
+				Metadata: &internal.PagingMetadata{},
+			},
+		},
+	}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      []byte(`{"monitors": ["awslambda", "azuread"], "queryType": "GetInstanceCount"}`),
+				TimeRange: timeRange,
+				Interval:  time.Minute,
+			}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Responses["A"].Error != nil {
+		t.Fatal(resp.Responses["A"].Error)
+	}
+
+	want := data.Frames{{
+		Name: "instance count",
+		Fields: []*data.Field{
+			data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:00:00Z"), strToTime("2022-12-07T18:01:00Z"), strToTime("2022-12-07T18:02:00Z")}),
+			// us-east-1 reported for two checks but is only counted once, the errored instance is counted too
+			data.NewField("instances", data.Labels{"monitor": "awslambda"}, []int64{3, 1, 0}),
+			data.NewField("instances", data.Labels{"monitor": "azuread"}, []int64{0, 0, 1}),
+		},
+		Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide, PreferredVisualization: data.VisTypeGraph, Stats: wantFrameStats(2, 6)},
+	}}
+	if diff := cmp.Diff(want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryErroringCheckCount(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	errorAt := func(monitor string, check string, instance string, timestamp string) internal.MonitorErrorCount {
+		return internal.MonitorErrorCount{Check: ptr(check), Count: ptr(1), Instance: ptr(instance), MonitorLogicalName: ptr(monitor), Timestamp: ptr(timestamp)}
+	}
+	ds := Datasource{openApiClient: &stubClient{
+		errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+			JSON200: &internal.MonitorErrorResponse{
+				Entries: &[]internal.MonitorErrorCount{
+					errorAt("awslambda", "Ping", "us-east-1", "2022-12-07T18:00:10Z"),
+					errorAt("awslambda", "Ping", "us-west-1", "2022-12-07T18:00:20Z"),
+					errorAt("awslambda", "Login", "us-east-1", "2022-12-07T18:00:30Z"),
+					errorAt("awslambda", "Login", "us-east-1", "2022-12-07T18:01:10Z"),
+					errorAt("awslambda", "Login", "us-west-1", "2022-12-07T18:01:40Z"),
+					errorAt("azuread", "Ping", "eu-west-1", "2022-12-07T18:02:10Z"),
+				},
+				Metadata: &internal.PagingMetadata{},
+			},
+		},
+	}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      []byte(`{"monitors": ["awslambda", "azuread"], "queryType": "GetErroringCheckCount"}`),
+				TimeRange: timeRange,
+				Interval:  time.Minute,
+			}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Responses["A"].Error != nil {
+		t.Fatal(resp.Responses["A"].Error)
+	}
+
+	want := data.Frames{{
+		Name: "erroring check count",
+		Fields: []*data.Field{
+			data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:00:00Z"), strToTime("2022-12-07T18:01:00Z"), strToTime("2022-12-07T18:02:00Z")}),
+			// Ping errored on two instances in the first bucket but is only counted once
+			data.NewField("checks", data.Labels{"monitor": "awslambda"}, []int64{2, 1, 0}),
+			data.NewField("checks", data.Labels{"monitor": "azuread"}, []int64{0, 0, 1}),
+		},
+		Meta: &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide, PreferredVisualization: data.VisTypeGraph, Stats: wantFrameStats(2, 6)},
+	}}
+	if diff := cmp.Diff(want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryErroringCheckCountIncompleteErrors(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	client := &stubClient{errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+		JSON200: &internal.MonitorErrorResponse{
+			Entries: &[]internal.MonitorErrorCount{
+				{Check: ptr("Ping"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:00:10Z")},
+				{Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:00:20Z")},
+				{Check: ptr("Login"), Count: ptr(1), Instance: ptr("us-east-1"), Timestamp: ptr("2022-12-07T18:00:30Z")},
+			},
+			Metadata: &internal.PagingMetadata{},
+		},
+	}}
+
+	query := backend.DataQuery{JSON: []byte(`{"monitors": ["awslambda"]}`), TimeRange: timeRange, Interval: time.Minute}
+	resp, err := QueryErroringCheckCount(context.Background(), query, client, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The errors without a check or monitor are dropped rather than counted
+	want := []*data.Field{
+		data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:00:00Z")}),
+		data.NewField("checks", data.Labels{"monitor": "awslambda"}, []int64{1}),
+	}
+	if len(resp.Frames) != 1 {
+		t.Fatalf("expected a single frame, got %d", len(resp.Frames))
+	}
+	if diff := cmp.Diff(want, resp.Frames[0].Fields, data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryInstanceAvailability(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	telemetryAt := func(monitor string, instance string, timestamp string) internal.MonitorTelemetry {
+		return internal.MonitorTelemetry{Check: ptr("Ping"), Instance: ptr(instance), MonitorLogicalName: ptr(monitor), Timestamp: ptr(timestamp), Value: ptr[float32](100)}
+	}
+	ds := Datasource{openApiClient: &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			JSON200: &internal.MonitorTelemetryResponse{
+				telemetryAt("awslambda", "us-east-1", "2022-12-07T18:00:10Z"),
+				telemetryAt("awslambda", "us-east-1", "2022-12-07T18:01:10Z"),
+				telemetryAt("awslambda", "us-east-1", "2022-12-07T18:02:10Z"),
+				telemetryAt("awslambda", "us-west-1", "2022-12-07T18:00:20Z"),
+				telemetryAt("awslambda", "us-west-1", "2022-12-07T18:01:20Z"),
+				telemetryAt("awslambda", "us-west-1", "2022-12-07T18:02:20Z"),
+				telemetryAt("awslambda", "eu-west-1", "2022-12-07T18:02:30Z"),
+				telemetryAt("azuread", "eu-west-1", "2022-12-07T18:00:10Z"),
+			},
+		},
+		errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+			JSON200: &internal.MonitorErrorResponse{
+				Entries: &[]internal.MonitorErrorCount{
+					{Check: ptr("Login"), Count: ptr(1), Instance: ptr("us-west-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:01:40Z")},
+				},
+				Metadata: &internal.PagingMetadata{},
+			},
+		},
+	}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      []byte(`{"monitors": ["awslambda", "azuread"], "queryType": "GetInstanceAvailability"}`),
+				TimeRange: timeRange,
+				Interval:  time.Minute,
+			}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Responses["A"].Error != nil {
+		t.Fatal(resp.Responses["A"].Error)
+	}
+
+	availability := data.NewField("availability", nil, []float64{100.0 / 3, 100, 100.0 * 2 / 3, 100})
+	availability.SetConfig(&data.FieldConfig{Unit: "percent"})
+	want := data.Frames{{
+		Name: "instance availability",
+		Fields: []*data.Field{
+			data.NewField("monitor", nil, []string{"awslambda", "awslambda", "awslambda", "azuread"}),
+			data.NewField("instance", nil, []string{"eu-west-1", "us-east-1", "us-west-1", "eu-west-1"}),
+			// eu-west-1 only reported in one of the three buckets, us-west-1 errored in one of them
+			availability,
+		},
+		Meta: &data.FrameMeta{PreferredVisualization: data.VisTypeTable},
+	}}
+	if diff := cmp.Diff(want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryLastSeen(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	telemetryAt := func(check string, instance string, timestamp string) internal.MonitorTelemetry {
+		return internal.MonitorTelemetry{Check: ptr(check), Instance: ptr(instance), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr(timestamp), Value: ptr[float32](100)}
+	}
+	ds := Datasource{openApiClient: &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			JSON200: &internal.MonitorTelemetryResponse{
+				telemetryAt("Ping", "us-west-1", "2022-12-07T18:00:10Z"),
+				telemetryAt("Ping", "us-east-1", "2022-12-07T18:05:10Z"),
+				telemetryAt("Ping", "us-east-1", "2022-12-07T18:01:10Z"),
+				telemetryAt("Login", "us-east-1", "2022-12-07T18:02:10Z"),
+				telemetryAt("Ping", "us-west-1", "2022-12-07T17:00:10Z"),
+			},
+		},
+	}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      []byte(`{"monitors": ["awslambda"], "queryType": "GetLastSeen"}`),
+				TimeRange: timeRange,
+			}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Responses["A"].Error != nil {
+		t.Fatal(resp.Responses["A"].Error)
+	}
+
+	want := data.Frames{{
+		Name: "last seen",
+		Fields: []*data.Field{
+			data.NewField("monitor", nil, []string{"awslambda", "awslambda", "awslambda"}),
+			data.NewField("check", nil, []string{"Login", "Ping", "Ping"}),
+			data.NewField("instance", nil, []string{"us-east-1", "us-east-1", "us-west-1"}),
+			data.NewField("last_seen", nil, []time.Time{strToTime("2022-12-07T18:02:10Z"), strToTime("2022-12-07T18:05:10Z"), strToTime("2022-12-07T18:00:10Z")}),
+		},
+		Meta: &data.FrameMeta{PreferredVisualization: data.VisTypeTable},
+	}}
+	if diff := cmp.Diff(want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryTelemetryHistogram(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	telemetry := internal.MonitorTelemetryResponse{}
+	for i, sample := range []struct {
+		instance string
+		value    *float32
+	}{
+		{"us-east-1", ptr[float32](5)},
+		{"us-east-1", ptr[float32](10)},
+		{"us-east-1", ptr[float32](99)},
+		{"us-east-1", ptr[float32](250)},
+		{"us-east-1", ptr[float32](500)},
+		{"us-east-1", ptr[float32](900)},
+		{"us-east-1", nil},
+		{"us-west-1", ptr[float32](150)},
+		{"us-west-1", ptr[float32](20)},
+	} {
+		telemetry = append(telemetry, internal.MonitorTelemetry{
+			Check: ptr("Ping"), Instance: ptr(sample.instance), MonitorLogicalName: ptr("awslambda"),
+			Timestamp: ptr(strToTime("2022-12-07T18:00:00Z").Add(time.Duration(i) * time.Minute).Format(time.RFC3339)), Value: sample.value,
+		})
+	}
+	ds := Datasource{openApiClient: &stubClient{telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{JSON200: &telemetry}}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      []byte(`{"monitors": ["awslambda"], "queryType": "GetTelemetryHistogram", "histogramBuckets": [10, 100, 500]}`),
+				TimeRange: timeRange,
+			}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Responses["A"].Error != nil {
+		t.Fatal(resp.Responses["A"].Error)
+	}
+
+	// 5 and 900 fall outside of the buckets, 500 falls in the last bucket which holds its upper bound
+	want := data.Frames{{
+		Name: "histogram",
+		Fields: []*data.Field{
+			data.NewField("xMin", nil, []float64{10, 100}),
+			data.NewField("xMax", nil, []float64{100, 500}),
+			data.NewField("count", data.Labels{"instance": "us-east-1", "check": "Ping", "monitor": "awslambda"}, []int64{2, 2}),
+			data.NewField("count", data.Labels{"instance": "us-west-1", "check": "Ping", "monitor": "awslambda"}, []int64{1, 1}),
+		},
+		Meta: &data.FrameMeta{Notices: []data.Notice{{Severity: data.NoticeSeverityInfo, Text: "2 values outside of the histogram buckets were left out"}}},
+	}}
+	if diff := cmp.Diff(want, withoutExecutedQueryString(resp.Responses["A"].Frames), data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+
+	resp, _ = ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      []byte(`{"monitors": ["awslambda"], "queryType": "GetTelemetryHistogram", "histogramBuckets": [100, 10]}`),
+				TimeRange: timeRange,
+			}},
+		},
+	)
+	if resp.Responses["A"].Error == nil {
+		t.Errorf("expected an error for decreasing bounds")
+	}
+}
+
+func TestQueryDataLimit(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	ds := Datasource{openApiClient: &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			JSON200: &internal.MonitorTelemetryResponse{
+				{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](1)},
+				{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:29:06Z"), Value: ptr[float32](2)},
+				{Check: ptr("Check"), Instance: ptr("us-west-2"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](3)},
+			},
+		},
+	}}
+
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		PluginContext: testPluginContext,
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      []byte(`{"monitors": ["awslambda"], "queryType": "GetMonitorTelemetry", "limit": 3}`),
+			TimeRange: timeRange,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := resp.Responses["A"].Frames
+	total := 0
+	for _, frame := range frames {
+		total += frame.Rows()
+	}
+	if total != 3 {
+		t.Errorf("expected 3 rows across all frames, got %d", total)
+	}
+	if notices := frames[0].Meta.Notices; len(notices) != 1 || notices[0].Severity != data.NoticeSeverityWarning {
+		t.Errorf("expected a truncation warning, got %v", notices)
+	}
+}
+
+func TestQueryDataExclusions(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	client := &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			JSON200: &internal.MonitorTelemetryResponse{
+				{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](1)},
+				{Check: ptr("Other"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](2)},
+				{Check: ptr("Check"), Instance: ptr("us-west-2"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](3)},
+				{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awsec2"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](4)},
+			},
+		},
+		errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+			JSON200: &internal.MonitorErrorResponse{
+				Metadata: &internal.PagingMetadata{},
+				Entries: &[]internal.MonitorErrorCount{
+					{Check: ptr("Check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+					{Check: ptr("Check"), Count: ptr(2), Instance: ptr("us-west-2"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+				},
+			},
+		},
+	}
+	ds := Datasource{openApiClient: client}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantLabels []data.Labels
+	}{
+		{
+			name:  "excludes monitors, checks and instances from telemetry",
+			query: `{"monitors": ["awslambda", "awsec2"], "queryType": "GetMonitorTelemetry", "fromAlerting": true, "excludeMonitors": ["awsec2"], "excludeChecks": ["Other"], "excludeInstances": ["us-west-2"]}`,
+			wantLabels: []data.Labels{
+				{"instance": "us-east-1", "check": "Check", "monitor": "awslambda"},
+			},
+		},
+		{
+			name:  "excludes instances from errors",
+			query: `{"monitors": ["awslambda"], "queryType": "GetMonitorErrors", "fromAlerting": true, "excludeInstances": ["us-east-1"]}`,
+			wantLabels: []data.Labels{
+				{"instance": "us-west-2", "check": "Check", "monitor": "awslambda"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+				PluginContext: testPluginContext,
+				Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(tt.query), TimeRange: timeRange}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			labels := make([]data.Labels, 0)
+			for _, frame := range resp.Responses["A"].Frames {
+				labels = append(labels, frame.Fields[1].Labels)
+			}
+			if diff := cmp.Diff(tt.wantLabels, labels); diff != "" {
+				t.Errorf("series mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQueryMonitorErrorsCumulative(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	counts := []int{1, 0, 2, 4}
+	entries := make([]internal.MonitorErrorCount, len(counts))
+	for i, count := range counts {
+		entries[i] = internal.MonitorErrorCount{
+			Check: ptr("Check"), Count: ptr(count), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"),
+			Timestamp: ptr(strToTime("2022-12-07T18:00:00Z").Add(time.Duration(i) * time.Minute).Format(time.RFC3339)),
+		}
+	}
+	ds := Datasource{openApiClient: &stubClient{errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+		JSON200: &internal.MonitorErrorResponse{Metadata: &internal.PagingMetadata{}, Entries: &entries},
+	}}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(`{"monitors": ["awslambda"], "queryType": "GetMonitorErrors", "cumulative": true}`), TimeRange: timeRange}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := resp.Responses["A"].Frames
+	if len(frames) != 2 {
+		t.Fatalf("expected 1 graph frame and 1 table frame, got %d", len(frames))
+	}
+	valuesOf := func(field *data.Field) []int64 {
+		values := make([]int64, field.Len())
+		for i := range values {
+			values[i] = field.At(i).(int64)
+		}
+		return values
+	}
+	graph := valuesOf(frames[0].Fields[1])
+	if diff := cmp.Diff([]int64{1, 1, 3, 7}, graph); diff != "" {
+		t.Errorf("cumulative counts mismatch (-want +got):\n%s", diff)
+	}
+	for i := 1; i < len(graph); i++ {
+		if graph[i] < graph[i-1] {
+			t.Errorf("expected a monotonic running total, got %v", graph)
+		}
+	}
+	if diff := cmp.Diff([]int64{1, 0, 2, 4}, valuesOf(frames[1].Fields[1])); diff != "" {
+		t.Errorf("expected the table to keep the raw counts (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryDataAlertingReducer(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	ds := Datasource{openApiClient: &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			JSON200: &internal.MonitorTelemetryResponse{
+				{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](4)},
+				{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:29:06Z"), Value: ptr[float32](2)},
+				{Check: ptr("Check"), Instance: ptr("us-west-2"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](3)},
+			},
+		},
+		errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+			JSON200: &internal.MonitorErrorResponse{
+				Metadata: &internal.PagingMetadata{},
+				Entries: &[]internal.MonitorErrorCount{
+					{Check: ptr("Check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+					{Check: ptr("Check"), Count: ptr(5), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:29:06Z")},
+				},
+			},
+		},
+	}}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantValues []float64
+		wantErr    bool
+	}{
+		{name: "reduces telemetry to its latest value", query: `{"monitors": ["awslambda"], "queryType": "GetMonitorTelemetry", "fromAlerting": true, "reducer": "last"}`, wantValues: []float64{2, 3}},
+		{name: "reduces telemetry to its maximum", query: `{"monitors": ["awslambda"], "queryType": "GetMonitorTelemetry", "fromAlerting": true, "reducer": "max"}`, wantValues: []float64{4, 3}},
+		{name: "reduces errors to their sum", query: `{"monitors": ["awslambda"], "queryType": "GetMonitorErrors", "fromAlerting": true, "reducer": "sum"}`, wantValues: []float64{6}},
+		{name: "reduces errors to their mean", query: `{"monitors": ["awslambda"], "queryType": "GetMonitorErrors", "fromAlerting": true, "reducer": "mean"}`, wantValues: []float64{3}},
+		{name: "rejects unknown reducers", query: `{"monitors": ["awslambda"], "queryType": "GetMonitorErrors", "fromAlerting": true, "reducer": "median"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+				PluginContext: testPluginContext,
+				Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(tt.query), TimeRange: timeRange}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := resp.Responses["A"]
+			if (res.Error != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", res.Error, tt.wantErr)
+			}
+
+			values := make([]float64, 0)
+			for _, frame := range res.Frames {
+				if frame.Meta.Type != data.FrameTypeNumericMulti || len(frame.Fields) != 1 || frame.Rows() != 1 {
+					t.Fatalf("expected a single value numeric frame, got %s with %d fields", frame.Meta.Type, len(frame.Fields))
+				}
+				values = append(values, frame.Fields[0].At(0).(float64))
+			}
+			if !tt.wantErr {
+				if diff := cmp.Diff(tt.wantValues, values); diff != "" {
+					t.Errorf("values mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryDataCache(t *testing.T) {
+	now := time.Now()
+	timeRange := backend.TimeRange{From: now.Add(-time.Hour), To: now}
+	query := []byte(`{"monitors": ["awslambda"], "checks": ["Check"], "queryType": "GetMonitorTelemetry"}`)
+
+	for _, tt := range []struct {
+		name         string
+		ttlSeconds   int
+		second       backend.TimeRange
+		wantRequests int
+	}{
+		{name: "serves an identical query from the cache", ttlSeconds: 30, second: timeRange, wantRequests: 1},
+		{name: "fetches again for another time range", ttlSeconds: 30, second: backend.TimeRange{From: now.Add(-2 * time.Hour), To: now}, wantRequests: 2},
+		{name: "fetches every time when disabled", ttlSeconds: 0, second: timeRange, wantRequests: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &recordingTelemetryClient{}
+			ds := Datasource{dsSettings: datasourceSettings{QueryCacheTTLSeconds: tt.ttlSeconds}, openApiClient: client}
+
+			var frames []data.Frames
+			for _, tr := range []backend.TimeRange{timeRange, tt.second} {
+				resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+					PluginContext: testPluginContext,
+					Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: tr}},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				frames = append(frames, resp.Responses["A"].Frames)
+			}
+
+			if len(client.requests) != tt.wantRequests {
+				t.Errorf("expected %d upstream requests, got %d", tt.wantRequests, len(client.requests))
+			}
+			if tt.wantRequests == 1 {
+				if diff := cmp.Diff(frames[0], frames[1], data.FrameTestCompareOptions()...); diff != "" {
+					t.Errorf("cached response mismatch (-first +second):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryCacheBounded(t *testing.T) {
+	var cache queryCache
+	for i := 0; i < queryCacheMaxEntries+10; i++ {
+		cache.set(fmt.Sprint(i), backend.DataResponse{}, time.Minute+time.Duration(i)*time.Second)
+	}
+	if len(cache.entries) != queryCacheMaxEntries {
+		t.Errorf("expected the cache to hold %d entries, got %d", queryCacheMaxEntries, len(cache.entries))
+	}
+	if _, ok := cache.get("0"); ok {
+		t.Error("expected the entry closest to expiring to be evicted")
+	}
+	if _, ok := cache.get(fmt.Sprint(queryCacheMaxEntries + 9)); !ok {
+		t.Error("expected the latest entry to be cached")
+	}
+
+	cache.set("expired", backend.DataResponse{}, -time.Second)
+	if _, ok := cache.get("expired"); ok {
+		t.Error("expected an expired entry not to be served")
+	}
+}
+
+func TestQueryDataCacheDirectives(t *testing.T) {
+	now := time.Now()
+	timeRange := backend.TimeRange{From: now.Add(-time.Hour), To: now}
+	query := []byte(`{"monitors": ["awslambda"], "checks": ["Check"], "queryType": "GetMonitorTelemetry"}`)
+
+	for _, tt := range []struct {
+		name         string
+		headers      map[string]string
+		wantHint     any
+		wantRequests int
+	}{
+		{name: "leaves frames alone without directives", wantHint: nil, wantRequests: 1},
+		{name: "hints the shorter max age", headers: map[string]string{"Cache-Control": "public, max-age=10"}, wantHint: map[string]any{"cacheTtlSeconds": int64(10)}, wantRequests: 1},
+		{name: "hints the configured ttl below the max age", headers: map[string]string{"Cache-Control": "public, max-age=60"}, wantHint: map[string]any{"cacheTtlSeconds": int64(30)}, wantRequests: 1},
+		{name: "accepts the forwarded header name", headers: map[string]string{"http_Cache-Control": "max-age=5"}, wantHint: map[string]any{"cacheTtlSeconds": int64(5)}, wantRequests: 1},
+		{name: "hints the configured ttl", headers: map[string]string{"cache-control": "public"}, wantHint: map[string]any{"cacheTtlSeconds": int64(30)}, wantRequests: 1},
+		{name: "bypasses the cache for no-cache", headers: map[string]string{"Cache-Control": "no-cache"}, wantHint: map[string]any{"cacheTtlSeconds": int64(0)}, wantRequests: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &recordingTelemetryClient{}
+			ds := Datasource{dsSettings: datasourceSettings{QueryCacheTTLSeconds: 30}, openApiClient: client}
+
+			for i := 0; i < 2; i++ {
+				resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+					PluginContext: testPluginContext,
+					Headers:       tt.headers,
+					Queries:       []backend.DataQuery{{RefID: "A", JSON: query, TimeRange: timeRange}},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				frames := resp.Responses["A"].Frames
+				if len(frames) == 0 {
+					t.Fatal("expected frames")
+				}
+				for _, frame := range frames {
+					if diff := cmp.Diff(tt.wantHint, frame.Meta.Custom); diff != "" {
+						t.Errorf("cache hint mismatch (-want +got):\n%s", diff)
+					}
+				}
+			}
+
+			if len(client.requests) != tt.wantRequests {
+				t.Errorf("expected %d upstream requests, got %d", tt.wantRequests, len(client.requests))
+			}
+		})
+	}
+}
+
+func TestApplyCacheHintKeepsCustomMetadata(t *testing.T) {
+	group := map[string]string{"monitor": "awslambda"}
+	frame := data.NewFrame("awslambda")
+	frame.Meta = &data.FrameMeta{Custom: group}
+
+	hinted := applyCacheHint(data.Frames{frame}, 30*time.Second)
+	want := map[string]any{"monitor": "awslambda", "cacheTtlSeconds": int64(30)}
+	if diff := cmp.Diff(want, hinted[0].Meta.Custom); diff != "" {
+		t.Errorf("custom metadata mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"monitor": "awslambda"}, frame.Meta.Custom); diff != "" {
+		t.Errorf("expected the original frame to be left alone (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryDataEnvironment(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	clientWithValue := func(value float32) *stubClient {
+		return &stubClient{
+			telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+				JSON200: &internal.MonitorTelemetryResponse{
+					{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr(value)},
+				},
+			},
+			monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+				JSON200: &internal.MonitorListResponse{{LogicalName: ptr("awslambda"), Name: ptr(fmt.Sprintf("AWS Lambda %v", value))}},
+			},
+		}
+	}
+	ds := Datasource{
+		openApiClient: clientWithValue(1),
+		environmentClients: map[string]internal.ClientWithResponsesInterface{
+			"dev":  clientWithValue(2),
+			"prod": clientWithValue(3),
+		},
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		want      float32
+		wantError bool
+	}{
+		{name: "uses the default endpoint without an environment", query: `{"monitors": ["awslambda"], "queryType": "GetMonitorTelemetry"}`, want: 1},
+		{name: "uses the client of the environment", query: `{"monitors": ["awslambda"], "queryType": "GetMonitorTelemetry", "environment": "prod"}`, want: 3},
+		{name: "rejects an unknown environment", query: `{"monitors": ["awslambda"], "queryType": "GetMonitorTelemetry", "environment": "staging"}`, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := ds.QueryData(
+				context.Background(),
+				&backend.QueryDataRequest{
+					PluginContext: testPluginContext,
+					Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(tt.query), TimeRange: timeRange}},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := resp.Responses["A"]
+			if tt.wantError {
+				if res.Error == nil || !strings.Contains(res.Error.Error(), "staging") {
+					t.Errorf("expected an unknown environment error, got %v", res.Error)
+				}
+				return
+			}
+			if res.Error != nil {
+				t.Fatal(res.Error)
+			}
+			if got := *res.Frames[0].Fields[1].At(0).(*float32); got != tt.want {
+				t.Errorf("expected the value %v of the selected environment, got %v", tt.want, got)
+			}
+			if got, want := res.Frames[0].Fields[1].Labels["monitor_name"], fmt.Sprintf("AWS Lambda %v", tt.want); got != want {
+				t.Errorf("expected the monitor name %q of the selected environment, got %q", want, got)
+			}
+		})
+	}
+}
+
+// panickingTelemetryClient panics when asked for the telemetry of the "boom" monitor
+type panickingTelemetryClient struct {
+	stubClient
+}
+
+func (c *panickingTelemetryClient) BackendWebMonitorTelemetryControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorTelemetryControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorTelemetryControllerGetResponse, error) {
+	if params.M[0] == "boom" {
+		var response *internal.MonitorTelemetryResponse
+		_ = *response
+	}
+	return c.stubClient.BackendWebMonitorTelemetryControllerGetWithResponse(ctx, params, reqEditors...)
+}
+
+func TestQueryDataRecoversFromPanics(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(time.Hour * time.Duration(-100)),
+	}
+	ds := Datasource{openApiClient: &panickingTelemetryClient{stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			JSON200: &internal.MonitorTelemetryResponse{
+				{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](100)},
+			},
+		},
+	}}}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			PluginContext: testPluginContext,
+			Queries: []backend.DataQuery{
+				{RefID: "A", JSON: []byte(`{"monitors": ["boom"], "queryType": "GetMonitorTelemetry"}`), TimeRange: timeRange},
+				{RefID: "B", JSON: []byte(`{"monitors": ["awslambda"], "queryType": "GetMonitorTelemetry"}`), TimeRange: timeRange},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res := resp.Responses["A"]; res.Status != backend.StatusInternal || !strings.Contains(res.Error.Error(), errQueryPanicked.Error()) {
+		t.Errorf("expected an internal error for the panicking query, got status %d and error %v", res.Status, res.Error)
+	}
+	if res := resp.Responses["B"]; res.Error != nil || len(res.Frames) == 0 {
+		t.Errorf("expected the other query to succeed, got error %v and %d frames", res.Error, len(res.Frames))
+	}
+}
+
+func TestQueryDataWithoutMonitors(t *testing.T) {
+	timeRange := backend.TimeRange{
+		To:   time.Now(),
+		From: time.Now().Add(-time.Hour),
+	}
+	// Any upstream data request fails, so the query must not issue one
+	ds := Datasource{openApiClient: &stubClient{err: errors.New("unexpected upstream request")}}
+
+	for _, queryType := range []string{"GetMonitorErrors", "GetMonitorTelemetry", "GetMonitorStatusPageChanges"} {
+		for _, query := range []string{`{"queryType": "` + queryType + `"}`, `{"queryType": "` + queryType + `", "monitors": []}`} {
+			t.Run(query, func(t *testing.T) {
+				resp, err := ds.QueryData(
+					context.Background(),
+					&backend.QueryDataRequest{
+						PluginContext: testPluginContext,
+						Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(query), TimeRange: timeRange}},
+					},
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				res := resp.Responses["A"]
+				if res.Error != nil {
+					t.Fatalf("expected no upstream request, got error %v", res.Error)
+				}
+				if len(res.Frames) != 1 || res.Frames[0].Rows() != 0 || len(res.Frames[0].Meta.Notices) != 1 {
+					t.Fatalf("expected a single empty frame with a notice, got %v", res.Frames)
+				}
+				if notice := res.Frames[0].Meta.Notices[0]; notice.Severity != data.NoticeSeverityInfo {
+					t.Errorf("notice severity = %v, want info", notice.Severity)
+				}
+			})
+		}
+	}
+}
+
+// sequenceVerifyAuthClient answers the verify auth requests with the given status codes in order
+type sequenceVerifyAuthClient struct {
+	stubClient
+	statusCodes []int
+	calls       int
+}
+
+func (c *sequenceVerifyAuthClient) BackendWebVerifyAuthControllerGetWithResponse(ctx context.Context,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebVerifyAuthControllerGetResponse, error) {
+	statusCode := c.statusCodes[c.calls]
+	c.calls++
+	return &internal.BackendWebVerifyAuthControllerGetResponse{
+		HTTPResponse: &http.Response{StatusCode: statusCode, Status: http.StatusText(statusCode)},
+	}, nil
+}
+
+func TestCheckHealthRetry(t *testing.T) {
+	defer func(delay time.Duration) { healthCheckRetryDelay = delay }(healthCheckRetryDelay)
+	healthCheckRetryDelay = time.Millisecond
+
+	tests := []struct {
+		name        string
+		statusCodes []int
+		wantStatus  backend.HealthStatus
+		wantCalls   int
+	}{
+		{name: "retries a transient server error", statusCodes: []int{http.StatusServiceUnavailable, http.StatusOK}, wantStatus: backend.HealthStatusOk, wantCalls: 2},
+		{name: "reports a persistent server error", statusCodes: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}, wantStatus: backend.HealthStatusError, wantCalls: 2},
+		{name: "does not retry unauthorized", statusCodes: []int{http.StatusUnauthorized}, wantStatus: backend.HealthStatusError, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &sequenceVerifyAuthClient{statusCodes: tt.statusCodes}
+			ds := Datasource{openApiClient: client}
+
+			result, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", result.Status, tt.wantStatus)
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("made %d requests, want %d", client.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCheckHealthUnreachable(t *testing.T) {
+	ds := Datasource{openApiClient: &stubClient{err: errors.New("dial tcp: connection refused")}}
+
+	result, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatalf("expected the transport error to be reported as a health result, got %v", err)
+	}
+	if result.Status != backend.HealthStatusError {
+		t.Errorf("status = %v, want %v", result.Status, backend.HealthStatusError)
+	}
+	if want := "Could not reach Metrist API: dial tcp: connection refused"; result.Message != want {
+		t.Errorf("message = %q, want %q", result.Message, want)
+	}
+}
+
+func TestCheckHealthMonitorCount(t *testing.T) {
+	ok := internal.BackendWebVerifyAuthControllerGetResponse{HTTPResponse: &http.Response{StatusCode: http.StatusOK}}
+	tests := []struct {
+		name        string
+		client      *stubClient
+		wantMessage string
+	}{
+		{
+			name: "includes the monitor count",
+			client: &stubClient{verifyAuthResponse: ok, monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+				JSON200: &internal.MonitorListResponse{
+					{LogicalName: ptr("awslambda"), Name: ptr("AWS Lambda")},
+					{LogicalName: ptr("awsec2"), Name: ptr("AWS EC2")},
+				},
+			}},
+			wantMessage: "Data source is working! 2 monitors available",
+		},
+		{
+			name: "stays healthy when the monitor list fails",
+			client: &stubClient{verifyAuthResponse: ok, monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+				HTTPResponse: &http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"},
+			}},
+			wantMessage: "Data source is working!",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := Datasource{openApiClient: tt.client}
+
+			result, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Status != backend.HealthStatusOk {
+				t.Errorf("status = %v, want %v", result.Status, backend.HealthStatusOk)
+			}
+			if result.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", result.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestCheckHealthRequiredMonitors(t *testing.T) {
+	client := &stubClient{
+		verifyAuthResponse: internal.BackendWebVerifyAuthControllerGetResponse{HTTPResponse: &http.Response{StatusCode: http.StatusOK}},
+		monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+			JSON200: &internal.MonitorListResponse{
+				{LogicalName: ptr("awslambda"), Name: ptr("AWS Lambda")},
+				{LogicalName: ptr("awsec2"), Name: ptr("AWS EC2")},
+			},
+		},
+	}
+	tests := []struct {
+		name        string
+		required    []string
+		wantStatus  backend.HealthStatus
+		wantMessage string
+	}{
+		{name: "healthy when every required monitor is accessible", required: []string{"awsec2", "awslambda"}, wantStatus: backend.HealthStatusOk, wantMessage: "Data source is working! 2 monitors available"},
+		{name: "names the inaccessible monitors", required: []string{"azuread", "awslambda", "gcpcompute"}, wantStatus: backend.HealthStatusError, wantMessage: "Warning: the API key can't access the required monitors azuread, gcpcompute"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := Datasource{dsSettings: datasourceSettings{RequiredMonitors: tt.required}, openApiClient: client}
+
+			result, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", result.Status, tt.wantStatus)
+			}
+			if result.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", result.Message, tt.wantMessage)
+			}
+		})
+	}
+}
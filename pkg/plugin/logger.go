@@ -0,0 +1,99 @@
+package plugin
+
+import (
+	"github.com/google/uuid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// contextLogger wraps a log.Logger and prepends a fixed set of key/value pairs to every log line
+type contextLogger struct {
+	logger log.Logger
+	args   []any
+}
+
+// loggerWith returns a logger which always includes the given key/value pairs
+func loggerWith(logger log.Logger, args ...any) log.Logger {
+	if l, ok := logger.(*contextLogger); ok {
+		return &contextLogger{logger: l.logger, args: append(append([]any{}, l.args...), args...)}
+	}
+	return &contextLogger{logger: logger, args: args}
+}
+
+// newRequestLogger returns a logger tagged with a unique request id so that a query and
+// all of its downstream requests can be correlated
+func newRequestLogger(logger log.Logger) log.Logger {
+	return loggerWith(logger, "requestId", uuid.NewString())
+}
+
+func (l *contextLogger) Debug(msg string, args ...any) {
+	l.logger.Debug(msg, l.with(args)...)
+}
+
+func (l *contextLogger) Info(msg string, args ...any) {
+	l.logger.Info(msg, l.with(args)...)
+}
+
+func (l *contextLogger) Warn(msg string, args ...any) {
+	l.logger.Warn(msg, l.with(args)...)
+}
+
+func (l *contextLogger) Error(msg string, args ...any) {
+	l.logger.Error(msg, l.with(args)...)
+}
+
+func (l *contextLogger) Level() log.Level {
+	return l.logger.Level()
+}
+
+func (l *contextLogger) with(args []any) []any {
+	return append(append(make([]any, 0, len(l.args)+len(args)), l.args...), args...)
+}
+
+// logLevels are the log levels of the logLevel setting by name
+var logLevels = map[string]log.Level{
+	"debug": log.Debug,
+	"info":  log.Info,
+	"warn":  log.Warn,
+	"error": log.Error,
+}
+
+// leveledLogger wraps a log.Logger and drops the log lines below level, so a datasource can be quieted
+// independently of the Grafana log level
+type leveledLogger struct {
+	logger log.Logger
+	level  log.Level
+}
+
+// withLevel returns a logger which drops the log lines below level. NoLevel keeps every line
+func withLevel(logger log.Logger, level log.Level) log.Logger {
+	if level == log.NoLevel {
+		return logger
+	}
+	return &leveledLogger{logger: logger, level: level}
+}
+
+func (l *leveledLogger) Debug(msg string, args ...any) {
+	if l.level <= log.Debug {
+		l.logger.Debug(msg, args...)
+	}
+}
+
+func (l *leveledLogger) Info(msg string, args ...any) {
+	if l.level <= log.Info {
+		l.logger.Info(msg, args...)
+	}
+}
+
+func (l *leveledLogger) Warn(msg string, args ...any) {
+	if l.level <= log.Warn {
+		l.logger.Warn(msg, args...)
+	}
+}
+
+func (l *leveledLogger) Error(msg string, args ...any) {
+	l.logger.Error(msg, args...)
+}
+
+func (l *leveledLogger) Level() log.Level {
+	return l.level
+}
@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// recordingLogger records the messages logged through it
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.messages = append(l.messages, msg) }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.messages = append(l.messages, msg) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.messages = append(l.messages, msg) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.messages = append(l.messages, msg) }
+func (l *recordingLogger) Level() log.Level              { return log.Debug }
+
+func TestWithLevel(t *testing.T) {
+	tests := []struct {
+		level log.Level
+		want  []string
+	}{
+		{level: log.NoLevel, want: []string{"debug", "info", "warn", "error"}},
+		{level: log.Debug, want: []string{"debug", "info", "warn", "error"}},
+		{level: log.Info, want: []string{"info", "warn", "error"}},
+		{level: log.Warn, want: []string{"warn", "error"}},
+		{level: log.Error, want: []string{"error"}},
+	}
+
+	for _, tt := range tests {
+		recorder := &recordingLogger{}
+		logger := loggerWith(withLevel(recorder, tt.level), "requestId", "id")
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Warn("warn")
+		logger.Error("error")
+		if diff := cmp.Diff(tt.want, recorder.messages); diff != "" {
+			t.Errorf("level %d: logged messages mismatch (-want +got):\n%s", tt.level, diff)
+		}
+	}
+}
+
+func TestDatasourceSettingsLogLevel(t *testing.T) {
+	for name, want := range map[string]log.Level{"": log.NoLevel, "debug": log.Debug, "Warn": log.Warn, "error": log.Error} {
+		if got, err := (datasourceSettings{LogLevel: name}).logLevel(); err != nil || got != want {
+			t.Errorf("logLevel(%q) = %d, %v, want %d", name, got, err, want)
+		}
+	}
+	if _, err := (datasourceSettings{LogLevel: "verbose"}).logLevel(); err == nil {
+		t.Errorf("expected an error for an unknown log level")
+	}
+}
@@ -3,22 +3,36 @@ package plugin
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"path"
 	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
 	"github.com/Metrist-Software/metrist-grafana-datasource/pkg/internal"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 )
 
 type frameType int64
 
 const (
-	GraphFrameType frameType = 0
-	TableFrameType frameType = 1
+	GraphFrameType   frameType = 0
+	TableFrameType   frameType = 1
+	ReducedFrameType frameType = 2
+	// LongTableFrameType is a table frame with one row per series point, see longTableFrameDefinition
+	LongTableFrameType frameType = 3
 )
 
 const (
@@ -30,50 +44,308 @@ const (
 
 const (
 	maxPageCount = 20
+	defaultTopN  = 10
+	// defaultMaxResults caps the number of error entries accumulated by a single query
+	defaultMaxResults = 100000
+	// defaultMaxSeries caps the number of graph frames built by a single query
+	defaultMaxSeries = 1000
+	// statusPageLookback is how far before a time range the status page changes giving the statuses at its
+	// start are looked up
+	statusPageLookback = durationThreeMonths
+	// maxShards caps the shards option, bounding the concurrent errors requests of a single query
+	maxShards = 8
+	// defaultAuthHeaderName is the header the API key is sent in unless configured otherwise
+	defaultAuthHeaderName = "Authorization"
+	// defaultBucketSize is used to bucket data when the query has no interval
+	defaultBucketSize = time.Minute
+)
+
+// Upstream endpoints, as shown in the executed query string
+const (
+	endpointMonitorErrors            = "/api/v0/monitor-error"
+	endpointMonitorTelemetry         = "/api/v0/monitor-telemetry"
+	endpointMonitorStatusPageChanges = "/api/v0/monitor-status-page-change"
+)
+
+// Default colors of the status page value mappings, see paletteColor
+const (
+	statusColorUnknown     = "gray"
+	statusColorMaintenance = "blue"
+	statusColorUp          = "green"
+	statusColorDegraded    = "yellow"
+	statusColorError       = "red"
+)
+
+// statusPageState describes how a numeric status page status is displayed. Severity orders the statuses
+// when several components are summarized, maintenance being more severe than up
+type statusPageState struct {
+	value    int8
+	text     string
+	color    string
+	severity int
+}
+
+// statusPageStates are the numeric status page statuses by value
+var statusPageStates = []statusPageState{
+	{value: 0, text: "unknown", color: statusColorUnknown, severity: 0},
+	{value: 1, text: "maintenance", color: statusColorMaintenance, severity: 2},
+	{value: 2, text: "up", color: statusColorUp, severity: 1},
+	{value: 3, text: "degraded", color: statusColorDegraded, severity: 3},
+	{value: 4, text: "error", color: statusColorError, severity: 4},
+}
+
+const (
+	sortOrderAsc  = "asc"
+	sortOrderDesc = "desc"
+)
+
+const (
+	tableFormatWide = "wide"
+	tableFormatLong = "long"
 )
 
-func buildFrames(responses []internal.FrameData, frameType frameType, frames []*data.Frame) []*data.Frame {
+// frameOptions holds the query options which affect how frames are built
+type frameOptions struct {
+	// groupBy splits the table frame into one frame per distinct combination of these label values
+	groupBy []string
+	// seriesName prefixes the name of the value field, so the series of several queries can be told apart
+	seriesName string
+	// maxSeries caps the number of graph frames, defaultMaxSeries when unset
+	maxSeries int
+	// aggregateInstances keys graph frames without the instance, merging the values of a timestamp, see
+	// mergeByTimestamp
+	aggregateInstances bool
+	// labels selects which of the series labels graph frames keep and are keyed by, all of them when unset. The
+	// series which collapse together are merged like aggregated instances are, see mergeByTimestamp
+	labels []string
+}
+
+// seriesLabels are the labels identifying the series of the upstream data, which the labels option selects from
+var seriesLabels = []string{"instance", "check", "monitor"}
+
+func buildFrames(responses []internal.FrameData, frameType frameType, frames []*data.Frame, options frameOptions, logger log.Logger) []*data.Frame {
 	frameMap := make(map[string]*data.Frame)
+	fieldLogger := newMissingFieldLogger(logger)
+	maxSeries := options.maxSeries
+	if maxSeries <= 0 {
+		maxSeries = defaultMaxSeries
+	}
+	droppedSeries := make(map[string]bool)
 
-	var frameToAppendTo *data.Frame
 	for _, frameDataItem := range responses {
 		timestamp, err := frameDataItem.GetTimestamp()
 		if err != nil {
-			log.DefaultLogger.Error("error while parsing time %w", err)
+			logger.Error("error while parsing time", "error", err)
+			continue
+		}
+		if !fieldLogger.check(frameDataItem) {
 			continue
 		}
-		frameDefinition := getFrameDefinitionFunction(frameType, frameDataItem)()
-		// For table Wide frames, we always want to append to the one single frame in order
-		if frameType == TableFrameType && frameToAppendTo == nil {
-			frameToAppendTo = &frameDefinition
-			frameMap["fixed-table"] = frameToAppendTo
-		} else if frameType == GraphFrameType {
-			key := frameDataItem.GetKey()
 
-			var ok bool
-			frameToAppendTo, ok = frameMap[key]
-			if !ok {
-				frameToAppendTo = &frameDefinition
-				frameMap[key] = frameToAppendTo
+		var key string
+		var groupValues []string
+		switch frameType {
+		case TableFrameType, LongTableFrameType:
+			// For table Wide frames, we always want to append to the one single frame (per group) in order
+			key = "fixed-table"
+			if len(options.groupBy) > 0 {
+				groupValues = getGroupValues(frameDataItem, options.groupBy)
+				key = strings.Join(groupValues, ":")
+			}
+		case GraphFrameType:
+			key = seriesKey(frameDataItem, options)
+		case ReducedFrameType:
+			key = frameDataItem.GetKey()
+		}
+
+		frameToAppendTo, ok := frameMap[key]
+		if !ok && frameType == GraphFrameType && len(frameMap) >= maxSeries {
+			droppedSeries[key] = true
+			continue
+		}
+		if !ok {
+			frameDefinition := getFrameDefinitionFunction(frameType, frameDataItem)()
+			frameToAppendTo = &frameDefinition
+			if groupValues != nil {
+				applyGroup(frameToAppendTo, options.groupBy, groupValues)
+			}
+			if options.seriesName != "" {
+				valueField := frameToAppendTo.Fields[valueFieldIndex(frameType)]
+				valueField.Name = options.seriesName + " " + valueField.Name
 			}
+			if frameType == GraphFrameType && options.aggregateInstances {
+				delete(frameToAppendTo.Fields[valueFieldIndex(frameType)].Labels, "instance")
+			}
+			if frameType == GraphFrameType && len(options.labels) > 0 {
+				selectLabels(frameToAppendTo.Fields[valueFieldIndex(frameType)].Labels, options.labels)
+			}
+			frameMap[key] = frameToAppendTo
 		}
 
 		vals := getValDefinitionFunction(frameType, frameDataItem)(timestamp)
 		frameToAppendTo.AppendRow(vals...)
 	}
-	for _, frame := range frameMap {
+	// Go maps have no order, so append the frames sorted by key to keep the legend order stable
+	keys := make([]string, 0, len(frameMap))
+	for key := range frameMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		frame := frameMap[key]
+		if frameType == GraphFrameType && (options.aggregateInstances || len(options.labels) > 0) {
+			mergeByTimestamp(frame)
+		}
+		if i == 0 && len(droppedSeries) > 0 {
+			logger.Warn("series limit reached, dropping series", "maxSeries", maxSeries, "dropped", len(droppedSeries))
+			frame.Meta.Notices = append(frame.Meta.Notices, data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     fmt.Sprintf("Only the first %d series are shown, %d more were dropped. Narrow the query to see them", maxSeries, len(droppedSeries)),
+			})
+		}
 		frames = append(frames, frame)
 	}
 
 	return frames
 }
 
+// seriesKey returns the key of the graph frame frameData is appended to, which leaves out the instance when
+// instances are aggregated and the series labels which are not selected
+func seriesKey(frameData internal.FrameData, options frameOptions) string {
+	if !options.aggregateInstances && len(options.labels) == 0 {
+		return frameData.GetKey()
+	}
+	labels := internal.GetLabels(frameData)
+	if options.aggregateInstances {
+		delete(labels, "instance")
+	}
+	if len(options.labels) > 0 {
+		selectLabels(labels, options.labels)
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + labels[name]
+	}
+	return strings.Join(parts, "-")
+}
+
+// mergeByTimestamp collapses the rows of a graph frame sharing a timestamp into a single row, keeping the order
+// in which the timestamps were first seen. Error counts, the int64 values, add up so they are summed. Response
+// times and statuses are averaged. Null values are left out, and a timestamp with only null values stays null
+func mergeByTimestamp(frame *data.Frame) {
+	timeField, valueField := frame.Fields[0], frame.Fields[1]
+	sum := valueField.Type() == data.FieldTypeInt64
+	sums := make(map[int64]float64)
+	counts := make(map[int64]int)
+	seen := make(map[int64]bool)
+	timestamps := make([]time.Time, 0)
+	for i := 0; i < timeField.Len(); i++ {
+		timestamp := timeField.At(i).(time.Time)
+		value, err := valueField.NullableFloatAt(i)
+		if err != nil {
+			return
+		}
+		if !seen[timestamp.UnixNano()] {
+			seen[timestamp.UnixNano()] = true
+			timestamps = append(timestamps, timestamp)
+		}
+		if value != nil {
+			sums[timestamp.UnixNano()] += *value
+			counts[timestamp.UnixNano()]++
+		}
+	}
+	if len(timestamps) == timeField.Len() {
+		return
+	}
+
+	merged := data.NewFieldFromFieldType(valueField.Type(), len(timestamps))
+	merged.Name, merged.Labels, merged.Config = valueField.Name, valueField.Labels, valueField.Config
+	for i, timestamp := range timestamps {
+		if counts[timestamp.UnixNano()] == 0 {
+			continue
+		}
+		value := sums[timestamp.UnixNano()]
+		if !sum {
+			value /= float64(counts[timestamp.UnixNano()])
+		}
+		switch valueField.Type() {
+		case data.FieldTypeNullableFloat32:
+			value := float32(value)
+			merged.Set(i, &value)
+		case data.FieldTypeFloat32:
+			merged.Set(i, float32(value))
+		case data.FieldTypeFloat64:
+			merged.Set(i, value)
+		case data.FieldTypeInt64:
+			merged.Set(i, int64(math.Round(value)))
+		case data.FieldTypeInt8:
+			merged.Set(i, int8(math.Round(value)))
+		default:
+			return
+		}
+	}
+	frame.Fields[0] = data.NewField(timeField.Name, timeField.Labels, timestamps)
+	frame.Fields[1] = merged
+}
+
+// selectLabels drops the series labels which are not selected. Other labels, such as shared, are kept
+func selectLabels(labels data.Labels, selected []string) {
+	for _, label := range seriesLabels {
+		if !slices.Contains(selected, label) {
+			delete(labels, label)
+		}
+	}
+}
+
+func getGroupValues(frameData internal.FrameData, groupBy []string) []string {
+	labels := internal.GetLabels(frameData)
+	values := make([]string, len(groupBy))
+	for i, label := range groupBy {
+		values[i] = labels[label]
+	}
+	return values
+}
+
+// valueFieldIndex returns the index of the value field in the frames of frameType
+func valueFieldIndex(frameType frameType) int {
+	switch frameType {
+	case ReducedFrameType:
+		return 0
+	case LongTableFrameType:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func applyGroup(frame *data.Frame, groupBy []string, groupValues []string) {
+	group := make(map[string]string, len(groupBy))
+	for i, label := range groupBy {
+		group[label] = groupValues[i]
+	}
+	frame.Name = strings.Join(groupValues, ":")
+	frame.Meta.Custom = group
+}
+
 func getFrameDefinitionFunction(frameType frameType, frameData internal.FrameData) func() data.Frame {
 	switch frameType {
 	case GraphFrameType:
 		return frameData.GetGraphFrameDefinition
 	case TableFrameType:
 		return frameData.GetTableFrameDefinition
+	case ReducedFrameType:
+		return func() data.Frame {
+			return reducedFrameDefinition(frameData.GetGraphFrameDefinition())
+		}
+	case LongTableFrameType:
+		return func() data.Frame {
+			return longTableFrameDefinition(frameData.GetGraphFrameDefinition())
+		}
 	}
 
 	return nil
@@ -85,71 +357,426 @@ func getValDefinitionFunction(frameType frameType, frameData internal.FrameData)
 		return frameData.GetGraphVals
 	case TableFrameType:
 		return frameData.GetTableVals
+	case ReducedFrameType:
+		return func(timestamp time.Time) []any {
+			// Drop the timestamp, reduced frames only carry the value
+			return frameData.GetGraphVals(timestamp)[1:]
+		}
+	case LongTableFrameType:
+		return func(timestamp time.Time) []any {
+			return []any{timestamp, frameData.GetKey(), frameData.GetGraphVals(timestamp)[1]}
+		}
 	}
 
 	return nil
 }
 
+// reducedFrameDefinition turns a graph frame definition into a single value per series frame
+// which Grafana alert rules can threshold on directly
+func reducedFrameDefinition(graphFrame data.Frame) data.Frame {
+	return data.Frame{
+		Fields: graphFrame.Fields[1:],
+		Meta: &data.FrameMeta{
+			Type: data.FrameTypeNumericMulti,
+		},
+	}
+}
+
+// longTableFrameDefinition turns a graph frame definition into a long table frame, where every row
+// names its series in a metric column and carries the series value in a single value column
+func longTableFrameDefinition(graphFrame data.Frame) data.Frame {
+	valueField := data.NewFieldFromFieldType(graphFrame.Fields[1].Type(), 0)
+	valueField.Name = "value"
+	return data.Frame{
+		Fields: []*data.Field{
+			data.NewField("time", nil, []time.Time{}),
+			data.NewField("metric", nil, []string{}),
+			valueField,
+		},
+		Meta: &data.FrameMeta{
+			Type:                   data.FrameTypeTimeSeriesLong,
+			PreferredVisualization: data.VisTypeTable,
+		},
+	}
+}
+
+// reducers reduce the values of a series to the single value alert rules threshold on, by name
+var reducers = map[string]func(values []float64) float64{
+	"last": func(values []float64) float64 { return values[len(values)-1] },
+	"min":  func(values []float64) float64 { return foldValues(values, math.Min) },
+	"max":  func(values []float64) float64 { return foldValues(values, math.Max) },
+	"sum":  sumValues,
+	"mean": func(values []float64) float64 { return sumValues(values) / float64(len(values)) },
+}
+
+func foldValues(values []float64, f func(a, b float64) float64) float64 {
+	result := values[0]
+	for _, value := range values[1:] {
+		result = f(result, value)
+	}
+	return result
+}
+
+func sumValues(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum
+}
+
+// alertingReducer returns the reducer alerting query is reduced with, or nil when its series are returned whole
+func alertingReducer(query monitorTelemetryQuery) (func(values []float64) float64, error) {
+	if !query.FromAlerting || query.Reducer == "" {
+		return nil, nil
+	}
+	reducer, ok := reducers[query.Reducer]
+	if !ok {
+		return nil, fmt.Errorf("unsupported reducer %q", query.Reducer)
+	}
+	return reducer, nil
+}
+
+// compareOffset returns the offset the previous period of query is shifted back by, zero when it has none
+func compareOffset(query monitorTelemetryQuery) (time.Duration, error) {
+	if query.CompareOffset == "" {
+		return 0, nil
+	}
+	offset, err := time.ParseDuration(query.CompareOffset)
+	if err != nil || offset <= 0 {
+		return 0, fmt.Errorf("invalid compare offset %q", query.CompareOffset)
+	}
+	return offset, nil
+}
+
+// previousPeriod returns tr shifted back by offset
+func previousPeriod(tr backend.TimeRange, offset time.Duration) backend.TimeRange {
+	return backend.TimeRange{From: tr.From.Add(-offset), To: tr.To.Add(-offset)}
+}
+
+// previousPeriodFrames builds the graph frames of the previous period, labeled period:previous. Their times are
+// shifted forward by offset so the series overlay the current period
+func previousPeriodFrames(previous []internal.FrameData, offset time.Duration, options frameOptions, logger log.Logger) []*data.Frame {
+	labeled := make([]internal.FrameData, len(previous))
+	for i, frameData := range previous {
+		labeled[i] = internal.WithLabels(frameData, map[string]string{"period": "previous"})
+	}
+
+	frames := buildFrames(labeled, GraphFrameType, nil, options, logger)
+	for _, frame := range frames {
+		timeField := frame.Fields[0]
+		for i := 0; i < timeField.Len(); i++ {
+			timeField.Set(i, timeField.At(i).(time.Time).Add(offset))
+		}
+	}
+	return frames
+}
+
+// reduceFrames turns graph frames into single value per series frames holding the reduction of their values.
+// Null values are left out, and so are the series without any other value
+func reduceFrames(frames []*data.Frame, reducer func(values []float64) float64) []*data.Frame {
+	reduced := make([]*data.Frame, 0, len(frames))
+	for _, frame := range frames {
+		valueField := frame.Fields[1]
+		values := make([]float64, 0, valueField.Len())
+		for i := 0; i < valueField.Len(); i++ {
+			if _, ok := valueField.ConcreteAt(i); !ok {
+				continue
+			}
+			if value, err := valueField.FloatAt(i); err == nil {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		field := data.NewField(valueField.Name, valueField.Labels, []float64{reducer(values)})
+		field.Config = valueField.Config
+		meta := *frame.Meta
+		meta.Type, meta.PreferredVisualization = data.FrameTypeNumericMulti, ""
+		reduced = append(reduced, &data.Frame{Name: frame.Name, Fields: []*data.Field{field}, Meta: &meta})
+	}
+	return reduced
+}
+
+// latestOnly keeps only the most recent item of each series
+func latestOnly(responses []internal.FrameData, logger log.Logger) []internal.FrameData {
+	latest := make(map[string]internal.FrameData)
+	latestTimestamps := make(map[string]time.Time)
+	keys := make([]string, 0)
+	for _, frameDataItem := range responses {
+		timestamp, err := frameDataItem.GetTimestamp()
+		if err != nil {
+			logger.Error("error while parsing time", "error", err)
+			continue
+		}
+
+		key := frameDataItem.GetKey()
+		current, ok := latestTimestamps[key]
+		if !ok {
+			keys = append(keys, key)
+		}
+		if !ok || !timestamp.Before(current) {
+			latest[key] = frameDataItem
+			latestTimestamps[key] = timestamp
+		}
+	}
+
+	result := make([]internal.FrameData, len(keys))
+	for i, key := range keys {
+		result[i] = latest[key]
+	}
+	return result
+}
+
 // QueryMonitorErrors queries `/monitor-telemetry`
-func QueryMonitorErrors(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface) (backend.DataResponse, error) {
+func QueryMonitorErrors(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
 	var monitorTelemetryQuery monitorTelemetryQuery
 	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
 		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
 	}
 
-	responses, err := fetchAllMonitorErrors(ctx, client, monitorTelemetryQuery, query.TimeRange)
+	reducer, err := alertingReducer(monitorTelemetryQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	offset, err := compareOffset(monitorTelemetryQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	if ok, err := expandInstancePatterns(ctx, newInstanceLister(client, monitorTelemetryQuery), &monitorTelemetryQuery); err != nil || !ok {
+		return backend.DataResponse{}, err
+	}
+
+	responses, notices, err := fetchAllMonitorErrors(ctx, client, monitorTelemetryQuery, query.TimeRange, logger)
 	if err != nil {
 		return backend.DataResponse{}, err
 	}
 
+	responses = withMinCount(responses, monitorTelemetryQuery.MinCount)
 	if len(responses) == 0 {
 		return backend.DataResponse{}, nil
 	}
 
-	// Have to coerce these into actual internal.FrameData as you can't pass responses to []any
-	coercedCounts := make([]internal.FrameData, len(responses))
-	for i := range responses {
-		coercedCounts[i] = &responses[i]
+	coercedCounts := coerceErrorCounts(responses, monitorTelemetryQuery.includeShared())
+
+	if monitorTelemetryQuery.Heatmap {
+		loc, err := bucketLocation(monitorTelemetryQuery)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+		}
+		frames := data.Frames{buildErrorHeatmapFrame(responses, bucketSizeFor(query), loc, logger)}
+		applyFrameStats(frames)
+		applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorErrors))
+		applyNotices(frames, notices)
+		return backend.DataResponse{Frames: frames}, nil
 	}
 
 	frames := make([]*data.Frame, 0)
-	frames = buildFrames(coercedCounts, GraphFrameType, frames)
+	frames = buildFrames(coercedCounts, GraphFrameType, frames, monitorTelemetryQuery.frameOptions(), logger)
+	if offset > 0 {
+		previous, previousNotices, err := fetchAllMonitorErrors(ctx, client, monitorTelemetryQuery, previousPeriod(query.TimeRange, offset), logger)
+		if err != nil {
+			return backend.DataResponse{}, err
+		}
+		notices = append(notices, previousNotices...)
+		previous = withMinCount(previous, monitorTelemetryQuery.MinCount)
+		frames = append(frames, previousPeriodFrames(coerceErrorCounts(previous, monitorTelemetryQuery.includeShared()), offset, monitorTelemetryQuery.frameOptions(), logger)...)
+	}
+	if monitorTelemetryQuery.Cumulative {
+		for _, frame := range frames {
+			applyCumulative(frame)
+		}
+	}
 	if !monitorTelemetryQuery.FromAlerting {
-		frames = buildFrames(coercedCounts, TableFrameType, frames)
+		frames = buildFrames(coercedCounts, monitorTelemetryQuery.tableFrameType(), frames, monitorTelemetryQuery.frameOptions(), logger)
 	}
+	applyQueryOptions(frames, monitorTelemetryQuery)
+	if reducer != nil {
+		frames = reduceFrames(frames, reducer)
+	}
+	applyFrameStats(frames)
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorErrors))
+	applyNotices(frames, notices)
 	return backend.DataResponse{Frames: frames}, nil
 }
 
-func fetchAllMonitorErrors(ctx context.Context, client internal.ClientWithResponsesInterface, query monitorTelemetryQuery, tr backend.TimeRange) ([]internal.MonitorErrorCount, error) {
-	onlyShared := true
+// applyCumulative turns the counts of an errors graph frame into their running total, e.g. 1, 0, 2 into 1, 1, 3.
+// The rows of graph frames are in time order, so the total accumulates over the time range
+func applyCumulative(frame *data.Frame) {
+	valueField := frame.Fields[1]
+	var total int64
+	for i := 0; i < valueField.Len(); i++ {
+		count, ok := valueField.At(i).(int64)
+		if !ok {
+			return
+		}
+		total += count
+		valueField.Set(i, total)
+	}
+}
 
-	params := []internal.BackendWebMonitorErrorControllerGetParams{{
-		From: tr.From,
-		To:   tr.To,
-		M:    query.Monitors,
-		C:    query.Checks,
-		I:    query.Instances,
-	}}
-
-	if query.IncludeShared {
-		params = append(params, internal.BackendWebMonitorErrorControllerGetParams{
-			From:       tr.From,
-			To:         tr.To,
-			M:          query.Monitors,
-			OnlyShared: &onlyShared,
-			C:          query.Checks,
-			I:          query.Instances,
-		})
+// coerceErrorCounts returns the error counts as internal.FrameData, as you can't pass them to []any. With
+// includeShared, each count is labeled with whether it is shared data
+func coerceErrorCounts(errorCounts []monitorErrorCount, includeShared bool) []internal.FrameData {
+	coerced := make([]internal.FrameData, len(errorCounts))
+	for i := range errorCounts {
+		coerced[i] = &errorCounts[i].MonitorErrorCount
+		if includeShared {
+			coerced[i] = internal.WithLabels(coerced[i], map[string]string{"shared": strconv.FormatBool(errorCounts[i].shared)})
+		}
 	}
+	return coerced
+}
 
-	g, ctx := errgroup.WithContext(ctx)
-	result := make([][]internal.MonitorErrorCount, len(params))
-	// Runs 2 go routines if shared is included
+// withMinCount drops the error counts below minCount, so buckets with only a few errors are hidden
+func withMinCount(errorCounts []monitorErrorCount, minCount int) []monitorErrorCount {
+	if minCount <= 1 {
+		return errorCounts
+	}
+	kept := make([]monitorErrorCount, 0, len(errorCounts))
+	for _, errorCount := range errorCounts {
+		if *errorCount.Count >= minCount {
+			kept = append(kept, errorCount)
+		}
+	}
+	return kept
+}
+
+// buildErrorHeatmapFrame pivots error counts into a time x instance matrix, with one count field per instance
+func buildErrorHeatmapFrame(errorCounts []monitorErrorCount, bucketSize time.Duration, loc *time.Location, logger log.Logger) *data.Frame {
+	counts := make(map[string]map[time.Time]int64)
+	buckets := make(map[time.Time]bool)
+	for _, errorCount := range errorCounts {
+		timestamp, err := errorCount.GetTimestamp()
+		if err != nil {
+			logger.Error("error while parsing time", "error", err)
+			continue
+		}
+
+		bucket := bucketStart(timestamp, bucketSize, loc)
+		buckets[bucket] = true
+		instance := *errorCount.Instance
+		if _, ok := counts[instance]; !ok {
+			counts[instance] = make(map[time.Time]int64)
+		}
+		counts[instance][bucket] += int64(*errorCount.Count)
+	}
+
+	sortedBuckets := make([]time.Time, 0, len(buckets))
+	for bucket := range buckets {
+		sortedBuckets = append(sortedBuckets, bucket)
+	}
+	sort.Slice(sortedBuckets, func(i, j int) bool { return sortedBuckets[i].Before(sortedBuckets[j]) })
+
+	instances := make([]string, 0, len(counts))
+	for instance := range counts {
+		instances = append(instances, instance)
+	}
+	sort.Strings(instances)
+
+	frame := data.NewFrame("errors heatmap", data.NewField("time", nil, sortedBuckets))
+	frame.Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide}
+	for _, instance := range instances {
+		values := make([]int64, len(sortedBuckets))
+		for i, bucket := range sortedBuckets {
+			values[i] = counts[instance][bucket]
+		}
+		frame.Fields = append(frame.Fields, data.NewField(instance, nil, values))
+	}
+	return frame
+}
+
+// bucketSizeFor returns the size of the time buckets used when aggregating data for query
+func bucketSizeFor(query backend.DataQuery) time.Duration {
+	if query.Interval <= 0 {
+		return defaultBucketSize
+	}
+	return query.Interval
+}
+
+// bucketLocation returns the time zone to align buckets in, UTC unless the query sets an IANA timezone
+func bucketLocation(query monitorTelemetryQuery) (*time.Location, error) {
+	if query.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(query.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+	return loc, nil
+}
+
+// bucketStart returns the start of the bucket timestamp falls in. Buckets are aligned on the wall clock of
+// loc, so daily buckets start at midnight in that time zone rather than at midnight UTC
+func bucketStart(timestamp time.Time, bucketSize time.Duration, loc *time.Location) time.Time {
+	_, offset := timestamp.In(loc).Zone()
+	shift := time.Duration(offset) * time.Second
+	return timestamp.Add(shift).Truncate(bucketSize).Add(-shift).In(loc)
+}
+
+// monitorErrorCount is a MonitorErrorCount along with whether it was returned by the shared data request
+type monitorErrorCount struct {
+	internal.MonitorErrorCount
+	shared bool
+}
+
+// fetchAllMonitorErrors pages through the errors of query concurrently. It keeps the earliest errors up to the
+// query max results. The owned and shared requests fail independently, so when only one of them
+// fails the other's errors are still returned. Both cases are reported through the returned notices
+func fetchAllMonitorErrors(ctx context.Context, client internal.ClientWithResponsesInterface, query monitorTelemetryQuery, tr backend.TimeRange, logger log.Logger) ([]monitorErrorCount, []data.Notice, error) {
+	onlyShared, ownedOnly := true, false
+	maxResults := query.maxResults()
+
+	// The owned requests explicitly exclude shared data rather than relying on the upstream default
+	params := make([]internal.BackendWebMonitorErrorControllerGetParams, 0)
+	for _, scope := range checkScopes(query) {
+		for _, shard := range shardTimeRange(tr, query.Shards) {
+			params = append(params, internal.BackendWebMonitorErrorControllerGetParams{
+				From:       shard.From,
+				To:         shard.To,
+				M:          scope.monitors,
+				OnlyShared: &ownedOnly,
+				C:          scope.checks,
+				I:          query.Instances,
+			})
+		}
+	}
+
+	ownedCount := len(params)
+	if query.includeShared() {
+		for _, scope := range checkScopes(query) {
+			for _, shard := range shardTimeRange(tr, query.Shards) {
+				params = append(params, internal.BackendWebMonitorErrorControllerGetParams{
+					From:       shard.From,
+					To:         shard.To,
+					M:          scope.monitors,
+					OnlyShared: &onlyShared,
+					C:          scope.checks,
+					I:          query.Instances,
+				})
+			}
+		}
+	}
+
+	var truncatedFlag atomic.Bool
+	// The owned and shared requests each get their own group, so a failure of one does not cancel the other
+	owned, ownedCtx := errgroup.WithContext(ctx)
+	shared, sharedCtx := errgroup.WithContext(ctx)
+	result := make([][]monitorErrorCount, len(params))
+	// Runs one go routine per time range shard, twice as many if shared is included
 	// Each goroutine will page through the result
 	for i, param := range params {
 		param := param // https://golang.org/doc/faq#closures_and_goroutines
 		i := i
-		g.Go(func() error {
+		g, ctx := owned, ownedCtx
+		if i >= ownedCount {
+			g, ctx = shared, sharedCtx
+		}
+		goRecovering(g, func() error {
 			currentParam := internal.BackendWebMonitorErrorControllerGetParams{
 				From:       param.From,
 				To:         param.To,
@@ -160,18 +787,35 @@ func fetchAllMonitorErrors(ctx context.Context, client internal.ClientWithRespon
 			}
 
 			for pageCount := 0; pageCount < maxPageCount; pageCount++ {
+				// Each request pages up to the max results on its own, the earliest of them are kept once merged
+				if int64(len(result[i])) >= maxResults {
+					truncatedFlag.Store(true)
+					break
+				}
+
 				resp, err := client.BackendWebMonitorErrorControllerGetWithResponse(ctx, &currentParam)
 				if err != nil {
 					return err
 				}
 
+				if err := checkRateLimited(resp.HTTPResponse); err != nil {
+					return err
+				}
+
 				response := resp.JSON200
 				if response == nil {
-					log.DefaultLogger.Warn("non 200 status code encountered. status %v, body %v", resp.HTTPResponse.Status, resp.Body)
+					logger.Warn("non 200 status code encountered", "status", resp.Status(), "body", string(resp.Body))
 					return nil
 				}
 
-				result[i] = append(result[i], *response.Entries...)
+				entries := withoutExcluded(completeOnly(*response.Entries, logger), query)
+				for _, entry := range entries {
+					result[i] = append(result[i], monitorErrorCount{MonitorErrorCount: entry, shared: param.OnlyShared != nil && *param.OnlyShared})
+				}
+				if len(*response.Entries) == 0 && response.Metadata.CursorAfter != nil {
+					logger.Debug("empty page returned with a cursor, stopping paging", "cursor", *response.Metadata.CursorAfter)
+					break
+				}
 				if currentParam.CursorAfter = response.Metadata.CursorAfter; currentParam.CursorAfter == nil {
 					break
 				}
@@ -180,79 +824,102 @@ func fetchAllMonitorErrors(ctx context.Context, client internal.ClientWithRespon
 		})
 	}
 
-	if err := g.Wait(); err != nil {
-		return nil, err
+	ownedErr := owned.Wait()
+	sharedErr := shared.Wait()
+	if ownedErr != nil && (sharedErr != nil || ownedCount == len(params)) {
+		return nil, nil, ownedErr
 	}
-
-	monitorErrors := make([]internal.MonitorErrorCount, 0)
-	for _, v := range result {
-		if len(v) == 0 {
-			continue
+	// A panic is a bug rather than an upstream failure, so it is never hidden behind a partial result
+	for _, err := range []error{ownedErr, sharedErr} {
+		if errors.Is(err, errQueryPanicked) {
+			return nil, nil, err
 		}
-		monitorErrors = append(monitorErrors, v...)
 	}
-	sort.SliceStable(monitorErrors, func(i, j int) bool {
-		return strToTime(*monitorErrors[i].Timestamp).Before(strToTime(*monitorErrors[j].Timestamp))
-	})
-	return monitorErrors, nil
-}
 
-// QueryMonitorTelemetry queries `/monitor-telemetry`
-func QueryMonitorTelemetry(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface) (backend.DataResponse, error) {
-	if err := ensureTelemetryRequestWithinLast90Days(query.TimeRange.From); err != nil {
-		log.DefaultLogger.Error("telemetry requested for greater than 90 days error: %w", err)
-		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	var notices []data.Notice
+	if ownedErr != nil || sharedErr != nil {
+		failed, from, to, err := "owned", 0, ownedCount, ownedErr
+		if sharedErr != nil {
+			failed, from, to, err = "shared", ownedCount, len(params), sharedErr
+		}
+		logger.Warn("error request failed, returning partial results", "request", failed, "error", err)
+		// Whatever pages the failed request did return are dropped rather than shown as a misleading partial series
+		for i := from; i < to; i++ {
+			result[i] = nil
+		}
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("The %s errors could not be fetched, only partial results are shown: %v", failed, err),
+		})
 	}
 
-	var monitorTelemetryQuery monitorTelemetryQuery
-	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
-		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	// The pages of every goroutine come in time order, so merging them is enough to order the result. The
+	// truncation happens once merged, so the same errors are kept whichever request finished first
+	monitorErrors := mergeSorted(result, errorCountBefore)
+	if int64(len(monitorErrors)) > maxResults {
+		monitorErrors = monitorErrors[:maxResults]
+		truncatedFlag.Store(true)
 	}
 
-	params := internal.BackendWebMonitorTelemetryControllerGetParams{
-		From:          query.TimeRange.From,
-		To:            query.TimeRange.To,
-		M:             monitorTelemetryQuery.Monitors,
-		IncludeShared: &monitorTelemetryQuery.IncludeShared,
-		C:             nilIfEmpty(monitorTelemetryQuery.Checks),
-		I:             nilIfEmpty(monitorTelemetryQuery.Instances),
+	if truncatedFlag.Load() {
+		logger.Warn("error results truncated", "maxResults", maxResults)
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("Results were truncated at %d errors, narrow the query to see all of them", maxResults),
+		})
 	}
+	return monitorErrors, notices, nil
+}
 
-	resp, err := client.BackendWebMonitorTelemetryControllerGetWithResponse(ctx, &params)
+// goRecovering runs f in g, turning a panic into an error, as QueryData can only recover the panics of its
+// own goroutine
+func goRecovering(g *errgroup.Group, f func() error) {
+	g.Go(func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%w: %v", errQueryPanicked, r)
+			}
+		}()
+		return f()
+	})
+}
 
-	if err != nil {
-		return backend.DataResponse{}, err
-	}
+func errorCountBefore(a, b monitorErrorCount) bool {
+	return strToTime(*a.Timestamp).Before(strToTime(*b.Timestamp))
+}
 
-	if len(*resp.JSON200) == 0 {
-		return backend.DataResponse{}, nil
+// shardTimeRange splits tr into n, at most maxShards, contiguous sub-ranges of equal length, so they can be
+// paged concurrently. The upstream ranges are inclusive, so every shard but the first starts a nanosecond after
+// its boundary to keep the errors at that instant from being counted twice
+func shardTimeRange(tr backend.TimeRange, n int) []backend.TimeRange {
+	if n <= 1 {
+		return []backend.TimeRange{tr}
 	}
-
-	responses := *resp.JSON200
-
-	// Have to coerce these into actual internal.FrameData as you can't pass responses to []any
-	coercedTelemetry := make([]internal.FrameData, len(responses))
-	for i := range responses {
-		coercedTelemetry[i] = &responses[i]
+	if n > maxShards {
+		n = maxShards
 	}
 
-	frames := make([]*data.Frame, 0)
-	frames = buildFrames(coercedTelemetry, GraphFrameType, frames)
-	if !monitorTelemetryQuery.FromAlerting {
-		frames = buildFrames(coercedTelemetry, TableFrameType, frames)
+	size := tr.Duration() / time.Duration(n)
+	shards := make([]backend.TimeRange, n)
+	for i := range shards {
+		shards[i] = backend.TimeRange{From: tr.From.Add(time.Duration(i) * size), To: tr.From.Add(time.Duration(i+1) * size)}
+		if i > 0 {
+			shards[i].From = shards[i].From.Add(time.Nanosecond)
+		}
 	}
-	return backend.DataResponse{Frames: frames}, nil
+	// Avoid losing the remainder of the division in the last shard
+	shards[n-1].To = tr.To
+	return shards
 }
 
-// QueryMonitorStatusPageChanges queries `/status-page-changes`
-func QueryMonitorStatusPageChanges(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface) (backend.DataResponse, error) {
+// QueryTopErrors returns the monitors with the most errors over the time range
+func QueryTopErrors(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
 	var monitorTelemetryQuery monitorTelemetryQuery
-
 	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
 		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
 	}
 
-	responses, err := fetchAllStatusPageMonitor(ctx, client, monitorTelemetryQuery, query.TimeRange)
+	responses, notices, err := fetchAllMonitorErrors(ctx, client, monitorTelemetryQuery, query.TimeRange, logger)
 	if err != nil {
 		return backend.DataResponse{}, err
 	}
@@ -261,68 +928,1934 @@ func QueryMonitorStatusPageChanges(ctx context.Context, query backend.DataQuery,
 		return backend.DataResponse{}, nil
 	}
 
-	// Have to coerce these into actual internal.FrameData as you can't pass responses to []any
-	coercedStatusPageChanges := make([]internal.FrameData, len(responses))
-	for i := range responses {
-		coercedStatusPageChanges[i] = &responses[i]
+	totals := make(map[string]int64)
+	for _, errorCount := range responses {
+		totals[*errorCount.MonitorLogicalName] += int64(*errorCount.Count)
+	}
+
+	monitors := make([]string, 0, len(totals))
+	for monitor := range totals {
+		monitors = append(monitors, monitor)
+	}
+	sort.Slice(monitors, func(i, j int) bool {
+		if totals[monitors[i]] == totals[monitors[j]] {
+			return monitors[i] < monitors[j]
+		}
+		return totals[monitors[i]] > totals[monitors[j]]
+	})
+
+	topN := monitorTelemetryQuery.TopN
+	if topN <= 0 {
+		topN = defaultTopN
+	}
+	if len(monitors) > topN {
+		monitors = monitors[:topN]
+	}
+
+	frame := data.NewFrame("top errors",
+		data.NewField("monitor", nil, []string{}),
+		data.NewField("count", nil, []int64{}),
+	)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	for _, monitor := range monitors {
+		frame.AppendRow(monitor, totals[monitor])
+	}
+
+	frames := data.Frames{frame}
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorErrors))
+	applyNotices(frames, notices)
+	return backend.DataResponse{Frames: frames}, nil
+}
+
+// QueryMonitorTelemetry queries `/monitor-telemetry`
+func QueryMonitorTelemetry(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
+	if err := ensureTelemetryRequestWithinLast90Days(query.TimeRange.From); err != nil {
+		logger.Error("telemetry requested for greater than 90 days", "error", err)
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	var monitorTelemetryQuery monitorTelemetryQuery
+	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	}
+
+	reducer, err := alertingReducer(monitorTelemetryQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	offset, err := compareOffset(monitorTelemetryQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+	if err := ensureTelemetryRequestWithinLast90Days(query.TimeRange.From.Add(-offset)); err != nil {
+		logger.Error("previous period telemetry requested for greater than 90 days", "error", err)
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	instances := newInstanceLister(client, monitorTelemetryQuery)
+	if ok, err := expandInstancePatterns(ctx, instances, &monitorTelemetryQuery); err != nil || !ok {
+		return backend.DataResponse{}, err
+	}
+
+	notices, ok := scopeInstances(ctx, instances, &monitorTelemetryQuery, logger)
+	if !ok {
+		return noticesResponse(notices), nil
+	}
+
+	responses, err := fetchMonitorTelemetry(ctx, client, monitorTelemetryQuery, query.TimeRange, logger)
+	if err != nil {
+		return backend.DataResponse{}, err
+	}
+
+	if len(responses) == 0 {
+		return noticesResponse(notices), nil
+	}
+
+	// Have to coerce these into actual internal.FrameData as you can't pass responses to []any
+	coercedTelemetry := make([]internal.FrameData, len(responses))
+	for i := range responses {
+		coercedTelemetry[i] = &responses[i]
+	}
+
+	frames := make([]*data.Frame, 0)
+	frames = buildFrames(coercedTelemetry, GraphFrameType, frames, monitorTelemetryQuery.frameOptions(), logger)
+	if offset > 0 {
+		previous, err := fetchMonitorTelemetry(ctx, client, monitorTelemetryQuery, previousPeriod(query.TimeRange, offset), logger)
+		if err != nil {
+			return backend.DataResponse{}, err
+		}
+		coercedPrevious := make([]internal.FrameData, len(previous))
+		for i := range previous {
+			coercedPrevious[i] = &previous[i]
+		}
+		frames = append(frames, previousPeriodFrames(coercedPrevious, offset, monitorTelemetryQuery.frameOptions(), logger)...)
+	}
+	if monitorTelemetryQuery.WithBands {
+		loc, err := bucketLocation(monitorTelemetryQuery)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+		}
+		for _, frame := range frames {
+			applyBands(frame, bucketSizeFor(query), loc)
+		}
+	}
+	if monitorTelemetryQuery.FillMissing {
+		for _, frame := range frames {
+			fillMissingBuckets(frame, query.Interval)
+		}
+	}
+	if !monitorTelemetryQuery.FromAlerting {
+		frames = buildFrames(coercedTelemetry, monitorTelemetryQuery.tableFrameType(), frames, monitorTelemetryQuery.frameOptions(), logger)
+	}
+	applyQueryOptions(frames, monitorTelemetryQuery)
+	if reducer != nil {
+		frames = reduceFrames(frames, reducer)
+	}
+	applyFrameStats(frames)
+	applyNotices(frames, notices)
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorTelemetry))
+	return backend.DataResponse{Frames: frames}, nil
+}
+
+// fillMissingBuckets inserts null values into a graph frame wherever consecutive points are at least
+// two intervals apart, so Grafana renders a gap instead of connecting the points across an outage
+func fillMissingBuckets(frame *data.Frame, interval time.Duration) {
+	if interval <= 0 || frame.Rows() < 2 {
+		return
+	}
+
+	filledFields := make([]*data.Field, len(frame.Fields))
+	for i, field := range frame.Fields {
+		fieldType := field.Type()
+		if i > 0 {
+			fieldType = fieldType.NullableType()
+		}
+		filledFields[i] = newFieldLike(field, fieldType, 0)
+	}
+
+	var previous time.Time
+	for row := 0; row < frame.Rows(); row++ {
+		timestamp := frame.Fields[0].At(row).(time.Time)
+		if row > 0 {
+			for missing := previous.Add(interval); timestamp.Sub(missing) >= interval; missing = missing.Add(interval) {
+				filledFields[0].Append(missing)
+				for _, field := range filledFields[1:] {
+					field.Extend(1)
+				}
+			}
+		}
+
+		filledFields[0].Append(timestamp)
+		for i, field := range filledFields[1:] {
+			field.Extend(1)
+			if val, ok := frame.Fields[i+1].ConcreteAt(row); ok {
+				field.SetConcrete(field.Len()-1, val)
+			}
+		}
+		previous = timestamp
+	}
+
+	frame.Fields = filledFields
+}
+
+// applyBands aggregates the rows of a telemetry graph frame into buckets of bucketSize, each holding the mean
+// value of the bucket along with min and max fields Grafana can render as a band around it. Null values are
+// left out, and a bucket with only null values stays null
+func applyBands(frame *data.Frame, bucketSize time.Duration, loc *time.Location) {
+	timeField, valueField := frame.Fields[0], frame.Fields[1]
+	buckets := make([]time.Time, 0)
+	seen := make(map[int64]bool)
+	sums := make(map[int64]float64)
+	counts := make(map[int64]int)
+	mins := make(map[int64]float32)
+	maxs := make(map[int64]float32)
+	for i := 0; i < timeField.Len(); i++ {
+		bucket := bucketStart(timeField.At(i).(time.Time), bucketSize, loc)
+		value, ok := valueField.At(i).(*float32)
+		if !ok {
+			return
+		}
+		key := bucket.UnixNano()
+		if !seen[key] {
+			seen[key] = true
+			buckets = append(buckets, bucket)
+		}
+		if value == nil {
+			continue
+		}
+		if counts[key] == 0 || *value < mins[key] {
+			mins[key] = *value
+		}
+		if counts[key] == 0 || *value > maxs[key] {
+			maxs[key] = *value
+		}
+		sums[key] += float64(*value)
+		counts[key]++
+	}
+
+	means := make([]*float32, len(buckets))
+	minValues := make([]*float32, len(buckets))
+	maxValues := make([]*float32, len(buckets))
+	for i, bucket := range buckets {
+		key := bucket.UnixNano()
+		if counts[key] == 0 {
+			continue
+		}
+		mean, low, high := float32(sums[key]/float64(counts[key])), mins[key], maxs[key]
+		means[i], minValues[i], maxValues[i] = &mean, &low, &high
+	}
+
+	mean := data.NewField(valueField.Name, valueField.Labels, means)
+	mean.Config = valueField.Config
+	frame.Fields = []*data.Field{
+		data.NewField(timeField.Name, timeField.Labels, buckets),
+		mean,
+		data.NewField("min", valueField.Labels.Copy(), minValues),
+		data.NewField("max", valueField.Labels.Copy(), maxValues),
+	}
+}
+
+// fetchMonitorTelemetry requests the telemetry of every check scope concurrently. Unlike the errors endpoint,
+// the telemetry endpoint returns its whole result in a single response, so there is no cursor to page through
+func fetchMonitorTelemetry(ctx context.Context, client internal.ClientWithResponsesInterface, query monitorTelemetryQuery, tr backend.TimeRange, logger log.Logger) ([]internal.MonitorTelemetry, error) {
+	scopes := checkScopes(query)
+	includeShared := query.includeShared()
+
+	g, ctx := errgroup.WithContext(ctx)
+	result := make([][]internal.MonitorTelemetry, len(scopes))
+	for i, scope := range scopes {
+		scope := scope // https://golang.org/doc/faq#closures_and_goroutines
+		i := i
+		goRecovering(g, func() error {
+			params := internal.BackendWebMonitorTelemetryControllerGetParams{
+				From:          tr.From,
+				To:            tr.To,
+				M:             scope.monitors,
+				IncludeShared: &includeShared,
+				C:             nilIfEmpty(scope.checks),
+				I:             nilIfEmpty(query.Instances),
+			}
+
+			resp, err := client.BackendWebMonitorTelemetryControllerGetWithResponse(ctx, &params)
+			if err != nil {
+				return err
+			}
+
+			if err := checkRateLimited(resp.HTTPResponse); err != nil {
+				return err
+			}
+
+			if resp.JSON200 == nil {
+				logger.Warn("non 200 status code encountered", "status", resp.Status(), "body", string(resp.Body))
+				return nil
+			}
+
+			result[i] = withoutExcluded(completeOnly(*resp.JSON200, logger), query)
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	telemetry := make([]internal.MonitorTelemetry, 0)
+	for _, v := range result {
+		telemetry = append(telemetry, v...)
+	}
+
+	if len(scopes) > 1 {
+		sort.SliceStable(telemetry, func(i, j int) bool {
+			return strToTime(*telemetry[i].Timestamp).Before(strToTime(*telemetry[j].Timestamp))
+		})
+	}
+	return telemetry, nil
+}
+
+// completeOnly drops the items the API returned with missing fields, so they can be dereferenced safely
+func completeOnly[T any, P interface {
+	*T
+	internal.FrameData
+}](items []T, logger log.Logger) []T {
+	complete := make([]T, 0, len(items))
+	fieldLogger := newMissingFieldLogger(logger)
+	for i := range items {
+		if fieldLogger.check(P(&items[i])) {
+			complete = append(complete, items[i])
+		}
+	}
+	return complete
+}
+
+// withoutExcluded drops the items of the monitors, checks and instances excluded by query
+func withoutExcluded[T any, P interface {
+	*T
+	internal.FrameData
+}](items []T, query monitorTelemetryQuery) []T {
+	if len(query.ExcludeMonitors) == 0 && len(query.ExcludeChecks) == 0 && len(query.ExcludeInstances) == 0 {
+		return items
+	}
+	kept := make([]T, 0, len(items))
+	for i := range items {
+		if !query.excludes(P(&items[i])) {
+			kept = append(kept, items[i])
+		}
+	}
+	return kept
+}
+
+// missingFieldLogger logs every field the API left out once, rather than once per item
+type missingFieldLogger struct {
+	logger log.Logger
+	logged map[string]bool
+}
+
+func newMissingFieldLogger(logger log.Logger) *missingFieldLogger {
+	return &missingFieldLogger{logger: logger, logged: make(map[string]bool)}
+}
+
+// check returns whether frameData is complete enough to be used, logging its missing fields
+func (m *missingFieldLogger) check(frameData internal.FrameData) bool {
+	for _, field := range internal.DefaultedFields(frameData) {
+		m.logOnce("missing field defaulted to its zero value", field)
+	}
+	missing := internal.MissingFields(frameData)
+	for _, field := range missing {
+		m.logOnce("skipping items with a missing field", field)
+	}
+	return len(missing) == 0
+}
+
+func (m *missingFieldLogger) logOnce(msg string, field string) {
+	if key := msg + field; !m.logged[key] {
+		m.logged[key] = true
+		m.logger.Warn(msg, "field", field)
+	}
+}
+
+// checkScope is a set of monitors which are queried with the same checks filter
+type checkScope struct {
+	monitors []string
+	checks   *[]string
+}
+
+// checkScopes splits the monitors of query by their checks filter. A check can be qualified with the
+// monitor logical names it belongs to as `monitor:check` or `mon_one,mon_two:check`, the option values of
+// the Checks resource, in which case it only filters those monitors, while plain checks filter every monitor.
+// Monitors left without any selected check are not queried, rather than fetching all of their checks.
+// Monitors ending up with the same filter are queried together
+func checkScopes(query monitorTelemetryQuery) []checkScope {
+	if query.Checks == nil || !slices.ContainsFunc(*query.Checks, isQualifiedCheck) {
+		return []checkScope{{monitors: query.Monitors, checks: query.Checks}}
+	}
+
+	plain := make([]string, 0)
+	qualified := make(map[string][]string)
+	for _, check := range *query.Checks {
+		if monitors, name, ok := strings.Cut(check, ":"); ok {
+			for _, monitor := range strings.Split(monitors, ",") {
+				qualified[monitor] = append(qualified[monitor], name)
+			}
+		} else {
+			plain = append(plain, check)
+		}
+	}
+
+	monitors := query.Monitors
+	if len(monitors) == 0 {
+		monitors = maps.Keys(qualified)
+		sort.Strings(monitors)
+	}
+
+	scopes := make([]checkScope, 0)
+	scopeIndex := make(map[string]int)
+	for _, monitor := range monitors {
+		checks := append(append([]string{}, qualified[monitor]...), plain...)
+		if len(checks) == 0 {
+			continue
+		}
+		key := strings.Join(checks, ",")
+		if i, ok := scopeIndex[key]; ok {
+			scopes[i].monitors = append(scopes[i].monitors, monitor)
+			continue
+		}
+		scopeIndex[key] = len(scopes)
+		scopes = append(scopes, checkScope{monitors: []string{monitor}, checks: &checks})
+	}
+	return scopes
+}
+
+func isQualifiedCheck(check string) bool {
+	return strings.Contains(check, ":")
+}
+
+// QueryMonitorOverview returns telemetry and errors of each series joined in a single wide frame aligned on time
+func QueryMonitorOverview(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
+	if err := ensureTelemetryRequestWithinLast90Days(query.TimeRange.From); err != nil {
+		logger.Error("telemetry requested for greater than 90 days", "error", err)
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	var monitorTelemetryQuery monitorTelemetryQuery
+	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	}
+
+	loc, err := bucketLocation(monitorTelemetryQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	overview, notices, err := fetchOverview(ctx, client, monitorTelemetryQuery, query.TimeRange, newOverviewBuilder(bucketSizeFor(query), loc), logger)
+	if err != nil || overview == nil {
+		return backend.DataResponse{}, err
+	}
+
+	frames := data.Frames{overview.frame()}
+	applyFrameStats(frames)
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorTelemetry, endpointMonitorErrors))
+	applyNotices(frames, notices)
+	return backend.DataResponse{Frames: frames}, nil
+}
+
+// fetchOverview fetches both the telemetry and the errors of query into builder. It returns nil when there is no
+// data. The returned notices report errors which were only partially fetched
+func fetchOverview(ctx context.Context, client internal.ClientWithResponsesInterface, query monitorTelemetryQuery, tr backend.TimeRange, builder *overviewBuilder, logger log.Logger) (*overviewBuilder, []data.Notice, error) {
+	telemetry, err := fetchMonitorTelemetry(ctx, client, query, tr, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	errorCounts, notices, err := fetchAllMonitorErrors(ctx, client, query, tr, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(telemetry) == 0 && len(errorCounts) == 0 {
+		return nil, nil, nil
+	}
+
+	for i := range telemetry {
+		builder.addTelemetry(&telemetry[i], logger)
+	}
+	for i := range errorCounts {
+		builder.addErrorCount(&errorCounts[i].MonitorErrorCount, logger)
+	}
+	return builder, notices, nil
+}
+
+// overviewSeries holds the bucketed telemetry and errors of a single monitor/check/instance
+type overviewSeries struct {
+	labels         map[string]string
+	telemetrySum   map[time.Time]float32
+	telemetryCount map[time.Time]int
+	errorCount     map[time.Time]int64
+}
+
+// overviewBuilder aligns telemetry and errors on common time buckets
+type overviewBuilder struct {
+	bucketSize time.Duration
+	loc        *time.Location
+	buckets    map[time.Time]bool
+	series     map[string]*overviewSeries
+}
+
+func newOverviewBuilder(bucketSize time.Duration, loc *time.Location) *overviewBuilder {
+	return &overviewBuilder{
+		bucketSize: bucketSize,
+		loc:        loc,
+		buckets:    make(map[time.Time]bool),
+		series:     make(map[string]*overviewSeries),
+	}
+}
+
+func (o *overviewBuilder) seriesFor(frameData internal.FrameData, timestamp time.Time) (*overviewSeries, time.Time) {
+	bucket := bucketStart(timestamp, o.bucketSize, o.loc)
+	o.buckets[bucket] = true
+
+	key := frameData.GetKey()
+	series, ok := o.series[key]
+	if !ok {
+		series = &overviewSeries{
+			labels:         internal.GetLabels(frameData),
+			telemetrySum:   make(map[time.Time]float32),
+			telemetryCount: make(map[time.Time]int),
+			errorCount:     make(map[time.Time]int64),
+		}
+		o.series[key] = series
+	}
+	return series, bucket
+}
+
+func (o *overviewBuilder) addTelemetry(te *internal.MonitorTelemetry, logger log.Logger) {
+	timestamp, err := te.GetTimestamp()
+	if err != nil {
+		logger.Error("error while parsing time", "error", err)
+		return
+	}
+
+	series, bucket := o.seriesFor(te, timestamp)
+	// A sample without a value leaves the bucket average to the other samples
+	if te.Value == nil {
+		return
+	}
+	series.telemetrySum[bucket] += *te.Value
+	series.telemetryCount[bucket]++
+}
+
+func (o *overviewBuilder) addErrorCount(errorCount *internal.MonitorErrorCount, logger log.Logger) {
+	timestamp, err := errorCount.GetTimestamp()
+	if err != nil {
+		logger.Error("error while parsing time", "error", err)
+		return
+	}
+
+	series, bucket := o.seriesFor(errorCount, timestamp)
+	series.errorCount[bucket] += int64(*errorCount.Count)
+}
+
+// sortedBuckets returns the time buckets in ascending order
+func (o *overviewBuilder) sortedBuckets() []time.Time {
+	buckets := make([]time.Time, 0, len(o.buckets))
+	for bucket := range o.buckets {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Before(buckets[j]) })
+	return buckets
+}
+
+// sortedKeys returns the series keys in order, to keep the field order stable
+func (o *overviewBuilder) sortedKeys() []string {
+	keys := make([]string, 0, len(o.series))
+	for key := range o.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// wideFrame returns an empty wide graph frame with the time field set to buckets
+func wideFrame(name string, buckets []time.Time) *data.Frame {
+	frame := data.NewFrame(name, data.NewField("time", nil, buckets))
+	frame.Meta = &data.FrameMeta{
+		Type:                   data.FrameTypeTimeSeriesWide,
+		PreferredVisualization: data.VisTypeGraph,
+	}
+	return frame
+}
+
+// frame builds the wide frame, with a nullable response time and count field per series
+func (o *overviewBuilder) frame() *data.Frame {
+	buckets := o.sortedBuckets()
+	frame := wideFrame("overview", buckets)
+	for _, key := range o.sortedKeys() {
+		series := o.series[key]
+		responseTimes := make([]*float32, len(buckets))
+		counts := make([]*int64, len(buckets))
+		for i, bucket := range buckets {
+			if n := series.telemetryCount[bucket]; n > 0 {
+				average := series.telemetrySum[bucket] / float32(n)
+				responseTimes[i] = &average
+			}
+			if count, ok := series.errorCount[bucket]; ok {
+				counts[i] = &count
+			}
+		}
+		frame.Fields = append(frame.Fields,
+			data.NewField("response time (ms)", series.labels, responseTimes),
+			data.NewField("count", series.labels, counts),
+		)
+	}
+	return frame
+}
+
+// errorRatioFrame builds a wide frame with a nullable ratio field per series. Every telemetry point is
+// counted as a good check and every error as a bad one, and the ratio of a bucket is its fraction of bad
+// checks divided by scale
+func (o *overviewBuilder) errorRatioFrame(name string, fieldName string, scale float64) *data.Frame {
+	buckets := o.sortedBuckets()
+	frame := wideFrame(name, buckets)
+	for _, key := range o.sortedKeys() {
+		series := o.series[key]
+		ratios := make([]*float64, len(buckets))
+		for i, bucket := range buckets {
+			bad := float64(series.errorCount[bucket])
+			total := float64(series.telemetryCount[bucket]) + bad
+			if total == 0 {
+				continue
+			}
+			ratio := bad / total / scale
+			ratios[i] = &ratio
+		}
+		frame.Fields = append(frame.Fields, data.NewField(fieldName, series.labels, ratios))
+	}
+	return frame
+}
+
+// instanceCountFrame builds a wide frame with a count field per monitor, holding the number of distinct
+// instances which reported telemetry or errors in each bucket
+func (o *overviewBuilder) instanceCountFrame() *data.Frame {
+	buckets := o.sortedBuckets()
+	monitors := make([]string, 0)
+	instances := make(map[string]map[time.Time]map[string]bool)
+	for _, key := range o.sortedKeys() {
+		series := o.series[key]
+		monitor := series.labels["monitor"]
+		if _, ok := instances[monitor]; !ok {
+			monitors = append(monitors, monitor)
+			instances[monitor] = make(map[time.Time]map[string]bool)
+		}
+		for _, bucket := range buckets {
+			if series.telemetryCount[bucket] == 0 && series.errorCount[bucket] == 0 {
+				continue
+			}
+			if instances[monitor][bucket] == nil {
+				instances[monitor][bucket] = make(map[string]bool)
+			}
+			instances[monitor][bucket][series.labels["instance"]] = true
+		}
+	}
+
+	sort.Strings(monitors)
+	frame := wideFrame("instance count", buckets)
+	for _, monitor := range monitors {
+		counts := make([]int64, len(buckets))
+		for i, bucket := range buckets {
+			counts[i] = int64(len(instances[monitor][bucket]))
+		}
+		frame.Fields = append(frame.Fields, data.NewField("instances", data.Labels{"monitor": monitor}, counts))
+	}
+	return frame
+}
+
+// burnRateFrame builds the burn rate frame. The burn rate of a bucket is its error rate divided by the
+// error budget, so 1 means the budget is consumed exactly at the rate the SLO allows
+func (o *overviewBuilder) burnRateFrame(errorBudget float64) *data.Frame {
+	return o.errorRatioFrame("slo burn rate", "burn rate", errorBudget)
+}
+
+// QuerySloBurnRate computes the error budget burn rate of the queried monitors against an SLO target
+func QuerySloBurnRate(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
+	if err := ensureTelemetryRequestWithinLast90Days(query.TimeRange.From); err != nil {
+		logger.Error("telemetry requested for greater than 90 days", "error", err)
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	var monitorTelemetryQuery monitorTelemetryQuery
+	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	}
+
+	if monitorTelemetryQuery.SloTarget <= 0 || monitorTelemetryQuery.SloTarget >= 100 {
+		return backend.ErrDataResponse(backend.StatusBadRequest, errInvalidSloTarget.Error()), errInvalidSloTarget
+	}
+
+	window := bucketSizeFor(query)
+	if monitorTelemetryQuery.Window != "" {
+		var err error
+		if window, err = time.ParseDuration(monitorTelemetryQuery.Window); err != nil || window <= 0 {
+			return backend.ErrDataResponse(backend.StatusBadRequest, "invalid window: "+monitorTelemetryQuery.Window), fmt.Errorf("invalid window %q", monitorTelemetryQuery.Window)
+		}
+	}
+
+	loc, err := bucketLocation(monitorTelemetryQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	builder, notices, err := fetchOverview(ctx, client, monitorTelemetryQuery, query.TimeRange, newOverviewBuilder(window, loc), logger)
+	if err != nil || builder == nil {
+		return backend.DataResponse{}, err
+	}
+
+	frames := data.Frames{builder.burnRateFrame(1 - monitorTelemetryQuery.SloTarget/100)}
+	applyFrameStats(frames)
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorTelemetry, endpointMonitorErrors))
+	applyNotices(frames, notices)
+	return backend.DataResponse{Frames: frames}, nil
+}
+
+// QueryErrorRatio returns the fraction of checks which errored per check and instance over time
+func QueryErrorRatio(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
+	if err := ensureTelemetryRequestWithinLast90Days(query.TimeRange.From); err != nil {
+		logger.Error("telemetry requested for greater than 90 days", "error", err)
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	var monitorTelemetryQuery monitorTelemetryQuery
+	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	}
+
+	loc, err := bucketLocation(monitorTelemetryQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	builder, notices, err := fetchOverview(ctx, client, monitorTelemetryQuery, query.TimeRange, newOverviewBuilder(bucketSizeFor(query), loc), logger)
+	if err != nil || builder == nil {
+		return backend.DataResponse{}, err
+	}
+
+	frames := data.Frames{builder.errorRatioFrame("error ratio", "error_ratio", 1)}
+	applyFrameStats(frames)
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorTelemetry, endpointMonitorErrors))
+	applyNotices(frames, notices)
+	return backend.DataResponse{Frames: frames}, nil
+}
+
+// QueryInstanceCount returns the number of distinct instances which reported per monitor over time
+func QueryInstanceCount(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
+	if err := ensureTelemetryRequestWithinLast90Days(query.TimeRange.From); err != nil {
+		logger.Error("telemetry requested for greater than 90 days", "error", err)
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	var monitorTelemetryQuery monitorTelemetryQuery
+	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	}
+
+	loc, err := bucketLocation(monitorTelemetryQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	builder, notices, err := fetchOverview(ctx, client, monitorTelemetryQuery, query.TimeRange, newOverviewBuilder(bucketSizeFor(query), loc), logger)
+	if err != nil || builder == nil {
+		return backend.DataResponse{}, err
+	}
+
+	frames := data.Frames{builder.instanceCountFrame()}
+	applyFrameStats(frames)
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorTelemetry, endpointMonitorErrors))
+	applyNotices(frames, notices)
+	return backend.DataResponse{Frames: frames}, nil
+}
+
+// erroringCheckCountFrame builds a wide frame with, per monitor, the number of distinct checks which had at
+// least one error in each bucket
+func erroringCheckCountFrame(errorCounts []monitorErrorCount, bucketSize time.Duration, loc *time.Location, logger log.Logger) *data.Frame {
+	buckets := make(map[time.Time]bool)
+	monitors := make([]string, 0)
+	checks := make(map[string]map[time.Time]map[string]bool)
+	for _, errorCount := range errorCounts {
+		timestamp, err := errorCount.GetTimestamp()
+		if err != nil {
+			logger.Error("error while parsing time", "error", err)
+			continue
+		}
+
+		// fetchAllMonitorErrors only keeps complete error counts, so the monitor and check are set
+		monitor := *errorCount.MonitorLogicalName
+		if _, ok := checks[monitor]; !ok {
+			monitors = append(monitors, monitor)
+			checks[monitor] = make(map[time.Time]map[string]bool)
+		}
+		bucket := bucketStart(timestamp, bucketSize, loc)
+		buckets[bucket] = true
+		if checks[monitor][bucket] == nil {
+			checks[monitor][bucket] = make(map[string]bool)
+		}
+		checks[monitor][bucket][*errorCount.Check] = true
+	}
+
+	sortedBuckets := make([]time.Time, 0, len(buckets))
+	for bucket := range buckets {
+		sortedBuckets = append(sortedBuckets, bucket)
+	}
+	sort.Slice(sortedBuckets, func(i, j int) bool { return sortedBuckets[i].Before(sortedBuckets[j]) })
+
+	sort.Strings(monitors)
+	frame := wideFrame("erroring check count", sortedBuckets)
+	for _, monitor := range monitors {
+		counts := make([]int64, len(sortedBuckets))
+		for i, bucket := range sortedBuckets {
+			counts[i] = int64(len(checks[monitor][bucket]))
+		}
+		frame.Fields = append(frame.Fields, data.NewField("checks", data.Labels{"monitor": monitor}, counts))
+	}
+	return frame
+}
+
+// QueryErroringCheckCount returns the number of distinct checks which errored per monitor over time, to show
+// how broad an outage is rather than how many errors it produced
+func QueryErroringCheckCount(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
+	var monitorTelemetryQuery monitorTelemetryQuery
+	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	}
+
+	loc, err := bucketLocation(monitorTelemetryQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	responses, notices, err := fetchAllMonitorErrors(ctx, client, monitorTelemetryQuery, query.TimeRange, logger)
+	if err != nil {
+		return backend.DataResponse{}, err
+	}
+
+	if len(responses) == 0 {
+		return backend.DataResponse{}, nil
+	}
+
+	frames := data.Frames{erroringCheckCountFrame(responses, bucketSizeFor(query), loc, logger)}
+	applyFrameStats(frames)
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorErrors))
+	applyNotices(frames, notices)
+	return backend.DataResponse{Frames: frames}, nil
+}
+
+// instanceAvailabilityFrame builds a table with the availability in percent of every monitor instance. An
+// instance is available in a bucket when it reported telemetry and no errors there, out of the buckets in
+// which any instance of its monitor reported
+func (o *overviewBuilder) instanceAvailabilityFrame() *data.Frame {
+	type instanceKey struct{ monitor, instance string }
+	reported := make(map[string]map[time.Time]bool)
+	healthy := make(map[instanceKey]map[time.Time]bool)
+	failed := make(map[instanceKey]map[time.Time]bool)
+	for _, key := range o.sortedKeys() {
+		series := o.series[key]
+		monitor := series.labels["monitor"]
+		instance := instanceKey{monitor: monitor, instance: series.labels["instance"]}
+		if reported[monitor] == nil {
+			reported[monitor] = make(map[time.Time]bool)
+		}
+		if healthy[instance] == nil {
+			healthy[instance] = make(map[time.Time]bool)
+			failed[instance] = make(map[time.Time]bool)
+		}
+		for bucket, count := range series.telemetryCount {
+			reported[monitor][bucket] = true
+			if count > 0 {
+				healthy[instance][bucket] = true
+			}
+		}
+		for bucket, count := range series.errorCount {
+			reported[monitor][bucket] = true
+			if count > 0 {
+				failed[instance][bucket] = true
+			}
+		}
+	}
+
+	instances := make([]instanceKey, 0, len(healthy))
+	for instance := range healthy {
+		instances = append(instances, instance)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].monitor == instances[j].monitor {
+			return instances[i].instance < instances[j].instance
+		}
+		return instances[i].monitor < instances[j].monitor
+	})
+
+	availabilityField := data.NewField("availability", nil, []float64{})
+	availabilityField.SetConfig(&data.FieldConfig{Unit: "percent"})
+	frame := data.NewFrame("instance availability",
+		data.NewField("monitor", nil, []string{}),
+		data.NewField("instance", nil, []string{}),
+		availabilityField,
+	)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	for _, instance := range instances {
+		available := 0
+		for bucket := range healthy[instance] {
+			if !failed[instance][bucket] {
+				available++
+			}
+		}
+		frame.AppendRow(instance.monitor, instance.instance, 100*float64(available)/float64(len(reported[instance.monitor])))
+	}
+	return frame
+}
+
+// QueryInstanceAvailability returns a table with the availability of every monitor instance over the time range,
+// so a single failing region stands out
+func QueryInstanceAvailability(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
+	if err := ensureTelemetryRequestWithinLast90Days(query.TimeRange.From); err != nil {
+		logger.Error("telemetry requested for greater than 90 days", "error", err)
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	var monitorTelemetryQuery monitorTelemetryQuery
+	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	}
+
+	loc, err := bucketLocation(monitorTelemetryQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	builder, notices, err := fetchOverview(ctx, client, monitorTelemetryQuery, query.TimeRange, newOverviewBuilder(bucketSizeFor(query), loc), logger)
+	if err != nil || builder == nil {
+		return backend.DataResponse{}, err
+	}
+
+	frames := data.Frames{builder.instanceAvailabilityFrame()}
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorTelemetry, endpointMonitorErrors))
+	applyNotices(frames, notices)
+	return backend.DataResponse{Frames: frames}, nil
+}
+
+// QueryLastSeen returns a table with the timestamp of the most recent telemetry of every monitor, check and
+// instance, so instances which stopped reporting stand out
+func QueryLastSeen(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
+	if err := ensureTelemetryRequestWithinLast90Days(query.TimeRange.From); err != nil {
+		logger.Error("telemetry requested for greater than 90 days", "error", err)
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	var monitorTelemetryQuery monitorTelemetryQuery
+	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	}
+
+	responses, err := fetchMonitorTelemetry(ctx, client, monitorTelemetryQuery, query.TimeRange, logger)
+	if err != nil {
+		return backend.DataResponse{}, err
+	}
+
+	if len(responses) == 0 {
+		return backend.DataResponse{}, nil
+	}
+
+	// Have to coerce these into actual internal.FrameData as you can't pass responses to []any
+	coercedTelemetry := make([]internal.FrameData, len(responses))
+	for i := range responses {
+		coercedTelemetry[i] = &responses[i]
+	}
+
+	frame := data.NewFrame("last seen",
+		data.NewField("monitor", nil, []string{}),
+		data.NewField("check", nil, []string{}),
+		data.NewField("instance", nil, []string{}),
+		data.NewField("last_seen", nil, []time.Time{}),
+	)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	for _, frameDataItem := range latestOnly(coercedTelemetry, logger) {
+		labels := internal.GetLabels(frameDataItem)
+		timestamp, _ := frameDataItem.GetTimestamp()
+		frame.AppendRow(labels["monitor"], labels["check"], labels["instance"], timestamp)
+	}
+	sortFrameRows(frame, func(i, j int) bool {
+		for _, field := range frame.Fields[:3] {
+			if a, b := field.At(i).(string), field.At(j).(string); a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+
+	frames := data.Frames{frame}
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorTelemetry))
+	return backend.DataResponse{Frames: frames}, nil
+}
+
+// defaultHistogramBuckets are the bounds, in ms, of the telemetry histogram bins when the query sets none
+var defaultHistogramBuckets = []float64{0, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// QueryTelemetryHistogram bins the telemetry values of every monitor, check and instance into the value ranges
+// between the histogramBuckets bounds. The frame holds the bounds of each bin along with a count field per series
+func QueryTelemetryHistogram(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
+	if err := ensureTelemetryRequestWithinLast90Days(query.TimeRange.From); err != nil {
+		logger.Error("telemetry requested for greater than 90 days", "error", err)
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error()), err
+	}
+
+	var monitorTelemetryQuery monitorTelemetryQuery
+	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	}
+
+	bounds := monitorTelemetryQuery.HistogramBuckets
+	if len(bounds) == 0 {
+		bounds = defaultHistogramBuckets
+	}
+	if !increasingBounds(bounds) {
+		return backend.ErrDataResponse(backend.StatusBadRequest, errInvalidHistogramBuckets.Error()), errInvalidHistogramBuckets
+	}
+
+	responses, err := fetchMonitorTelemetry(ctx, client, monitorTelemetryQuery, query.TimeRange, logger)
+	if err != nil {
+		return backend.DataResponse{}, err
+	}
+
+	if len(responses) == 0 {
+		return backend.DataResponse{}, nil
+	}
+
+	frame, outside := buildHistogramFrame(responses, bounds)
+	frames := data.Frames{frame}
+	if outside > 0 {
+		applyNotices(frames, []data.Notice{{
+			Severity: data.NoticeSeverityInfo,
+			Text:     fmt.Sprintf("%d values outside of the histogram buckets were left out", outside),
+		}})
+	}
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorTelemetry))
+	return backend.DataResponse{Frames: frames}, nil
+}
+
+// increasingBounds returns whether bounds holds at least two bounds, each larger than the one before
+func increasingBounds(bounds []float64) bool {
+	if len(bounds) < 2 {
+		return false
+	}
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// buildHistogramFrame counts the telemetry values of each series per bin, returning the frame along with the
+// number of values outside of every bin. Bins hold their lower bound, and the last bin its upper bound too.
+// Samples without a value are left out
+func buildHistogramFrame(telemetry []internal.MonitorTelemetry, bounds []float64) (*data.Frame, int) {
+	bins := len(bounds) - 1
+	counts := make(map[string][]int64)
+	labels := make(map[string]data.Labels)
+	keys := make([]string, 0)
+	outside := 0
+	for i := range telemetry {
+		te := &telemetry[i]
+		if te.Value == nil {
+			continue
+		}
+		value := float64(*te.Value)
+		// The index of the last bound at or below value
+		bin := sort.Search(len(bounds), func(i int) bool { return bounds[i] > value }) - 1
+		if bin == bins && value == bounds[bins] {
+			bin--
+		}
+		if bin < 0 || bin >= bins {
+			outside++
+			continue
+		}
+
+		key := te.GetKey()
+		if _, ok := counts[key]; !ok {
+			counts[key] = make([]int64, bins)
+			labels[key] = internal.GetLabels(te)
+			keys = append(keys, key)
+		}
+		counts[key][bin]++
+	}
+
+	frame := data.NewFrame("histogram",
+		data.NewField("xMin", nil, slices.Clone(bounds[:bins])),
+		data.NewField("xMax", nil, slices.Clone(bounds[1:])),
+	)
+	frame.Meta = &data.FrameMeta{}
+	for _, key := range keys {
+		frame.Fields = append(frame.Fields, data.NewField("count", labels[key], counts[key]))
+	}
+	return frame, outside
+}
+
+// QueryStatusPageAvailability returns the availability in percent of every queried monitor over the time range,
+// averaging the availability of its status page components weighted by the componentWeights option
+func QueryStatusPageAvailability(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, logger log.Logger) (backend.DataResponse, error) {
+	var monitorTelemetryQuery monitorTelemetryQuery
+	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	}
+
+	responses, truncated, err := fetchAllStatusPageMonitor(ctx, client, monitorTelemetryQuery, query.TimeRange, logger)
+	if err != nil {
+		return backend.DataResponse{}, err
+	}
+	notices := make([]data.Notice, 0)
+	if truncated {
+		notices = append(notices, statusPageTruncatedNotice())
+	}
+
+	// Status pages only record changes, so the earlier changes are needed for the statuses at the start of
+	// the time range. Components which did not change within it are only known from these
+	lookback := backend.TimeRange{From: query.TimeRange.From.Add(-statusPageLookback), To: query.TimeRange.From.Add(-time.Nanosecond)}
+	earlier, truncated, err := fetchAllStatusPageMonitor(ctx, client, monitorTelemetryQuery, lookback, logger)
+	if err != nil {
+		return backend.DataResponse{}, err
+	}
+	if truncated {
+		// The latest change before the time range, which gives the starting status, is the one left out
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     "The status page changes before the time range were truncated, so the availability may start from outdated statuses",
+		})
+	}
+	responses = append(earlier, responses...)
+
+	availability := weightedAvailability(componentUptimes(responses, query.TimeRange, logger), monitorTelemetryQuery.ComponentWeights)
+	if len(availability) == 0 {
+		return backend.DataResponse{}, nil
+	}
+
+	monitors := make([]string, 0, len(availability))
+	for monitor := range availability {
+		monitors = append(monitors, monitor)
+	}
+	sort.Strings(monitors)
+
+	frame := data.NewFrame("availability")
+	frame.Meta = &data.FrameMeta{Type: data.FrameTypeNumericWide}
+	for _, monitor := range monitors {
+		field := data.NewField("availability", data.Labels{"monitor": monitor}, []float64{availability[monitor]})
+		field.SetConfig(&data.FieldConfig{Unit: "percent"})
+		frame.Fields = append(frame.Fields, field)
+	}
+
+	frames := data.Frames{frame}
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorStatusPageChanges))
+	applyNotices(frames, notices)
+	return backend.DataResponse{Frames: frames}, nil
+}
+
+// statusPageTruncatedNotice warns that the latest status page changes of the time range were left out
+func statusPageTruncatedNotice() data.Notice {
+	return data.Notice{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("Only the first %d pages of status page changes were fetched, narrow the query to see all of them", maxPageCount),
+	}
+}
+
+// componentUptime is how long a status page component was up, and how long its status was known
+type componentUptime struct {
+	up    time.Duration
+	known time.Duration
+}
+
+// componentUptimes derives the uptime of every status page component within tr, by monitor and component. Each
+// status lasts until the next change of its component or the end of tr, so the latest change before tr gives the
+// status at its start. The time before the first change and the time under maintenance are not known
+func componentUptimes(changes []internal.StatusPageComponentChange, tr backend.TimeRange, logger log.Logger) map[string]map[string]componentUptime {
+	type timedChange struct {
+		timestamp time.Time
+		status    int8
+	}
+	byComponent := make(map[[2]string][]timedChange)
+	for i := range changes {
+		timestamp, err := changes[i].GetTimestamp()
+		if err != nil {
+			logger.Error("error while parsing time", "error", err)
+			continue
+		}
+		labels := internal.GetLabels(&changes[i])
+		key := [2]string{labels["monitor"], labels["component"]}
+		byComponent[key] = append(byComponent[key], timedChange{timestamp: timestamp, status: internal.StatusPageStatus(&changes[i])})
+	}
+
+	uptimes := make(map[string]map[string]componentUptime)
+	for key, timeline := range byComponent {
+		sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].timestamp.Before(timeline[j].timestamp) })
+
+		var uptime componentUptime
+		for i, change := range timeline {
+			start, end := change.timestamp, tr.To
+			if i+1 < len(timeline) {
+				end = timeline[i+1].timestamp
+			}
+			if start.Before(tr.From) {
+				start = tr.From
+			}
+			if end.After(tr.To) {
+				end = tr.To
+			}
+			if !end.After(start) || change.status == 0 || change.status == internal.StatusPageStatusMaintenance {
+				continue
+			}
+			uptime.known += end.Sub(start)
+			if change.status == internal.StatusPageStatusUp {
+				uptime.up += end.Sub(start)
+			}
+		}
+
+		if uptimes[key[0]] == nil {
+			uptimes[key[0]] = make(map[string]componentUptime)
+		}
+		uptimes[key[0]][key[1]] = uptime
+	}
+	return uptimes
+}
+
+// weightedAvailability averages the availability of the components of each monitor in percent, weighing them
+// by weights. Components without a weight weigh 1, those weighing 0 or less and those without a known status
+// are left out. Monitors without any component left are left out
+func weightedAvailability(uptimes map[string]map[string]componentUptime, weights map[string]float64) map[string]float64 {
+	availability := make(map[string]float64)
+	for monitor, components := range uptimes {
+		var weighted, totalWeight float64
+		for component, uptime := range components {
+			weight, ok := weights[component]
+			if !ok {
+				weight = 1
+			}
+			if weight <= 0 || uptime.known == 0 {
+				continue
+			}
+			weighted += weight * float64(uptime.up) / float64(uptime.known)
+			totalWeight += weight
+		}
+		if totalWeight > 0 {
+			availability[monitor] = 100 * weighted / totalWeight
+		}
+	}
+	return availability
+}
+
+// QueryMonitorStatusPageChanges queries `/status-page-changes`. The value mappings use the status colors of
+// palette, see datasourceSettings.statusPalette
+func QueryMonitorStatusPageChanges(ctx context.Context, query backend.DataQuery, client internal.ClientWithResponsesInterface, palette map[string]string, logger log.Logger) (backend.DataResponse, error) {
+	var monitorTelemetryQuery monitorTelemetryQuery
+
+	if err := json.Unmarshal(query.JSON, &monitorTelemetryQuery); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "json unmarshal: "+err.Error()), err
+	}
+
+	responses, truncated, err := fetchAllStatusPageMonitor(ctx, client, monitorTelemetryQuery, query.TimeRange, logger)
+	if err != nil {
+		return backend.DataResponse{}, err
+	}
+
+	if len(responses) == 0 {
+		return backend.DataResponse{}, nil
+	}
+
+	if len(monitorTelemetryQuery.ComponentGroups) > 0 {
+		responses = rollUpComponentGroups(responses, monitorTelemetryQuery.ComponentGroups)
+	}
+
+	// Have to coerce these into actual internal.FrameData as you can't pass responses to []any
+	coercedStatusPageChanges := make([]internal.FrameData, len(responses))
+	for i := range responses {
+		coercedStatusPageChanges[i] = &responses[i]
 	}
 
 	frames := make([]*data.Frame, 0)
-	frames = buildFrames(coercedStatusPageChanges, GraphFrameType, frames)
-	if !monitorTelemetryQuery.FromAlerting {
-		frames = buildFrames(coercedStatusPageChanges, TableFrameType, frames)
+	if monitorTelemetryQuery.FromAlerting {
+		// Alert rules threshold on the latest status of each component
+		latest := latestOnly(coercedStatusPageChanges, logger)
+		if monitorTelemetryQuery.StatusText {
+			latest = withStatusText(latest)
+		}
+		frames = buildFrames(latest, ReducedFrameType, frames, monitorTelemetryQuery.frameOptions(), logger)
+	} else {
+		tableData := coercedStatusPageChanges
+		if monitorTelemetryQuery.StatusText {
+			tableData = withStatusTextColumn(coercedStatusPageChanges)
+		}
+		frames = buildFrames(coercedStatusPageChanges, GraphFrameType, frames, monitorTelemetryQuery.frameOptions(), logger)
+		frames = buildFrames(tableData, monitorTelemetryQuery.tableFrameType(), frames, monitorTelemetryQuery.frameOptions(), logger)
+	}
+	applyQueryOptions(frames, monitorTelemetryQuery)
+	applyFrameStats(frames)
+	applyExecutedQueryString(frames, executedQueryString(monitorTelemetryQuery, query.TimeRange, endpointMonitorStatusPageChanges))
+
+	mappings := make(data.ValueMappings, 0, len(statusPageStates))
+	for _, state := range statusPageStates {
+		mappings = append(mappings, data.ValueMapper{
+			strconv.Itoa(int(state.value)): data.ValueMappingResult{Text: fmt.Sprintf("(%d) %s", state.value, state.text), Color: paletteColor(palette, state.color)},
+		})
+	}
+	if monitorTelemetryQuery.BinaryStatus {
+		applyBinaryStatus(frames)
+		mappings = data.ValueMappings{
+			data.ValueMapper{"0": data.ValueMappingResult{Text: "(0) down", Color: paletteColor(palette, statusColorError)}},
+			data.ValueMapper{"1": data.ValueMappingResult{Text: "(1) up", Color: paletteColor(palette, statusColorUp)}},
+		}
 	}
 
 	for _, frame := range frames {
-		for idx, field := range frame.Fields {
-			if idx == 0 {
+		for _, field := range frame.Fields {
+			if field.Type() == data.FieldTypeTime {
 				continue
 			}
-			field.SetConfig(&data.FieldConfig{
-				Mappings: data.ValueMappings{
-					data.ValueMapper{"0": data.ValueMappingResult{Text: "(0) unknown", Color: "gray"}},
-					data.ValueMapper{"1": data.ValueMappingResult{Text: "(1) maintenance", Color: "blue"}},
-					data.ValueMapper{"2": data.ValueMappingResult{Text: "(2) up", Color: "green"}},
-					data.ValueMapper{"3": data.ValueMappingResult{Text: "(3) degraded", Color: "yellow"}},
-					data.ValueMapper{"4": data.ValueMappingResult{Text: "(4) error", Color: "red"}},
-				},
-			})
+			field.SetConfig(&data.FieldConfig{Mappings: mappings})
 		}
 	}
+	applyStatusDurations(frames)
+	if truncated {
+		applyNotices(frames, []data.Notice{statusPageTruncatedNotice()})
+	}
 
 	return backend.DataResponse{Frames: frames}, nil
 }
 
-func fetchAllStatusPageMonitor(ctx context.Context, client internal.ClientWithResponsesInterface, query monitorTelemetryQuery, tr backend.TimeRange) ([]internal.StatusPageComponentChange, error) {
+// rollUpComponentGroups replaces the changes of the grouped components of each monitor by the changes of their
+// group, a component named after the group whose status is the worst status of its components at that time.
+// A group change is only recorded when its worst status changes
+func rollUpComponentGroups(changes []internal.StatusPageComponentChange, groups map[string]string) []internal.StatusPageComponentChange {
+	sorted := make([]internal.StatusPageComponentChange, len(changes))
+	copy(sorted, changes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strToTime(*sorted[i].Timestamp).Before(strToTime(*sorted[j].Timestamp))
+	})
+
+	// Statuses of the components of each group, and the status last recorded for the group, by monitor and group
+	componentStatuses := make(map[string]map[string]string)
+	groupStatuses := make(map[string]string)
+	rolledUp := make([]internal.StatusPageComponentChange, 0, len(sorted))
+	for _, change := range sorted {
+		group, ok := groups[*change.Component]
+		if !ok {
+			rolledUp = append(rolledUp, change)
+			continue
+		}
+
+		key := fmt.Sprintf("%s-%s", *change.MonitorLogicalName, group)
+		if _, ok := componentStatuses[key]; !ok {
+			componentStatuses[key] = make(map[string]string)
+		}
+		componentStatuses[key][*change.Component] = *change.Status
+
+		worst := ""
+		for _, status := range componentStatuses[key] {
+			// Statuses of the same severity are told apart by name, so the recorded wording does not depend on map order
+			if worst == "" || statusSeverity(status) > statusSeverity(worst) || (statusSeverity(status) == statusSeverity(worst) && status < worst) {
+				worst = status
+			}
+		}
+		if recorded, ok := groupStatuses[key]; ok && statusSeverity(recorded) == statusSeverity(worst) {
+			continue
+		}
+		groupStatuses[key] = worst
+
+		groupChange := change
+		groupChange.Component, groupChange.Status, groupChange.Id = &group, &worst, nil
+		rolledUp = append(rolledUp, groupChange)
+	}
+	return rolledUp
+}
+
+// statusSeverity returns the severity of an upstream status page status
+func statusSeverity(status string) int {
+	value := internal.StatusPageStatus(&internal.StatusPageComponentChange{Status: &status})
+	for _, state := range statusPageStates {
+		if state.value == value {
+			return state.severity
+		}
+	}
+	return 0
+}
+
+// applyStatusDurations adds a duration column to the status page table frames, holding how many seconds each
+// status lasted until the next change of its component. The latest status of a component is still ongoing,
+// so its duration is left empty
+func applyStatusDurations(frames []*data.Frame) {
+	for _, frame := range frames {
+		if frame.Meta == nil || frame.Meta.PreferredVisualization != data.VisTypeTable {
+			continue
+		}
+		timeField, timeIndex := frame.FieldByName("time")
+		if timeIndex < 0 {
+			continue
+		}
+		// Wide tables identify components by their component and monitor columns, long tables by their metric
+		keyFields := make([]*data.Field, 0)
+		for _, name := range []string{"component", "monitor", "metric"} {
+			if field, i := frame.FieldByName(name); i >= 0 {
+				keyFields = append(keyFields, field)
+			}
+		}
+		if len(keyFields) == 0 {
+			continue
+		}
+
+		rowsByKey := make(map[string][]int)
+		for row := 0; row < frame.Rows(); row++ {
+			parts := make([]string, len(keyFields))
+			for i, field := range keyFields {
+				parts[i] = fmt.Sprint(field.At(row))
+			}
+			key := strings.Join(parts, "-")
+			rowsByKey[key] = append(rowsByKey[key], row)
+		}
+
+		durations := make([]*float64, frame.Rows())
+		for _, rows := range rowsByKey {
+			sort.SliceStable(rows, func(i, j int) bool {
+				return timeField.At(rows[i]).(time.Time).Before(timeField.At(rows[j]).(time.Time))
+			})
+			for i, row := range rows[:len(rows)-1] {
+				duration := timeField.At(rows[i+1]).(time.Time).Sub(timeField.At(row).(time.Time)).Seconds()
+				durations[row] = &duration
+			}
+		}
+
+		field := data.NewField("duration", nil, durations)
+		field.SetConfig(&data.FieldConfig{Unit: "s"})
+		frame.Fields = append(frame.Fields, field)
+	}
+}
+
+// withStatusText labels each status page change with its upstream status wording. The label is part of the
+// series key, so it is only used on the latest change of each component where it cannot split a series
+func withStatusText(statusPageChanges []internal.FrameData) []internal.FrameData {
+	labeled := make([]internal.FrameData, len(statusPageChanges))
+	for i, frameDataItem := range statusPageChanges {
+		labeled[i] = frameDataItem
+		if change, ok := frameDataItem.(*internal.StatusPageComponentChange); ok && change.Status != nil {
+			labeled[i] = internal.WithLabels(change, map[string]string{"status_text": *change.Status})
+		}
+	}
+	return labeled
+}
+
+// withStatusTextColumn adds the upstream status wording of each status page change as a status_text column of
+// the table frame, keeping a single graph series per component
+func withStatusTextColumn(statusPageChanges []internal.FrameData) []internal.FrameData {
+	withColumn := make([]internal.FrameData, len(statusPageChanges))
+	for i, frameDataItem := range statusPageChanges {
+		var statusText string
+		if change, ok := frameDataItem.(*internal.StatusPageComponentChange); ok && change.Status != nil {
+			statusText = *change.Status
+		}
+		// Every row needs the column, otherwise it would not fit the table frame
+		withColumn[i] = internal.WithTableColumn(frameDataItem, "status_text", statusText)
+	}
+	return withColumn
+}
+
+// applyBinaryStatus replaces the status values of the frames with 1 when up and 0 for any other status
+func applyBinaryStatus(frames []*data.Frame) {
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			if field.Type() != data.FieldTypeInt8 {
+				continue
+			}
+			for i := 0; i < field.Len(); i++ {
+				if field.At(i).(int8) == internal.StatusPageStatusUp {
+					field.Set(i, int8(1))
+				} else {
+					field.Set(i, int8(0))
+				}
+			}
+		}
+	}
+}
+
+func fetchAllStatusPageMonitor(ctx context.Context, client internal.ClientWithResponsesInterface, query monitorTelemetryQuery, tr backend.TimeRange, logger log.Logger) ([]internal.StatusPageComponentChange, bool, error) {
 	monitorStatuses := make([]internal.StatusPageComponentChange, 0)
 	params := internal.BackendWebStatusPageChangeControllerGetParams{
 		From: tr.From,
 		To:   &tr.To,
 		M:    query.Monitors,
 	}
-	for pageCount := 0; pageCount < maxPageCount; pageCount++ {
+	truncated := false
+	for pageCount := 0; ; pageCount++ {
+		// Pages come oldest first, so the latest changes are the ones left out
+		if pageCount == maxPageCount {
+			logger.Warn("status page changes truncated", "maxPageCount", maxPageCount)
+			truncated = true
+			break
+		}
+
 		resp, err := client.BackendWebStatusPageChangeControllerGetWithResponse(ctx, &params)
 		if err != nil {
-			return nil, err
+			return nil, false, err
+		}
+
+		if err := checkRateLimited(resp.HTTPResponse); err != nil {
+			return nil, false, err
 		}
 
 		response := resp.JSON200
-		monitorStatuses = append(monitorStatuses, *response.Entries...)
+		if response == nil {
+			logger.Warn("non 200 status code encountered", "status", resp.Status(), "body", string(resp.Body))
+			break
+		}
+		for _, change := range withoutExcluded(completeOnly(*response.Entries, logger), query) {
+			// The API cannot filter on a component, so the other components are dropped as pages come in
+			if query.Component == "" || *change.Component == query.Component {
+				monitorStatuses = append(monitorStatuses, change)
+			}
+		}
 
+		if len(*response.Entries) == 0 && response.Metadata.CursorAfter != nil {
+			logger.Debug("empty page returned with a cursor, stopping paging", "cursor", *response.Metadata.CursorAfter)
+			break
+		}
 		if params.CursorAfter = response.Metadata.CursorAfter; params.CursorAfter == nil {
 			break
 		}
 	}
-	return monitorStatuses, nil
+	return monitorStatuses, truncated, nil
+}
+
+// applyMonitorNames adds a monitor_name label with the monitor display name to every labelled field
+func applyMonitorNames(frames []*data.Frame, names map[string]string) {
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			if field.Labels == nil {
+				continue
+			}
+			if name, ok := names[field.Labels["monitor"]]; ok {
+				field.Labels["monitor_name"] = name
+			}
+		}
+	}
+}
+
+// paletteColor returns the color palette configures in place of the default status color, the default color
+// itself when it isn't configured
+func paletteColor(palette map[string]string, color string) string {
+	if configured, ok := palette[color]; ok {
+		return configured
+	}
+	return color
+}
+
+// applyQueryOptions applies the query options which transform the frames once they are built
+func applyQueryOptions(frames []*data.Frame, query monitorTelemetryQuery) {
+	keepLastRows(frames, query.LastN)
+	sortTableFrames(frames, query.SortOrder)
+	sortTableFramesByValue(frames, query.SortByValue)
+}
+
+// executedQueryString summarises the upstream requests made for query, for Grafana's query inspector
+func executedQueryString(query monitorTelemetryQuery, tr backend.TimeRange, endpoints ...string) string {
+	parts := []string{
+		"GET " + strings.Join(endpoints, ", "),
+		"monitors=" + strings.Join(query.Monitors, ","),
+	}
+	if query.Checks != nil && len(*query.Checks) > 0 {
+		parts = append(parts, "checks="+strings.Join(*query.Checks, ","))
+	}
+	if query.Instances != nil && len(*query.Instances) > 0 {
+		parts = append(parts, "instances="+strings.Join(*query.Instances, ","))
+	}
+	if query.Component != "" {
+		parts = append(parts, "component="+query.Component)
+	}
+	parts = append(parts,
+		"from="+tr.From.UTC().Format(time.RFC3339),
+		"to="+tr.To.UTC().Format(time.RFC3339),
+		"includeShared="+strconv.FormatBool(query.includeShared()),
+	)
+	return strings.Join(parts, " ")
+}
+
+func applyExecutedQueryString(frames []*data.Frame, executedQueryString string) {
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		frame.Meta.ExecutedQueryString = executedQueryString
+	}
+}
+
+// applyNotices attaches notices to every frame
+func applyNotices(frames []*data.Frame, notices []data.Notice) {
+	if len(notices) == 0 {
+		return
+	}
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		frame.Meta.Notices = append(frame.Meta.Notices, notices...)
+	}
+}
+
+// applyRowLimit keeps the first limit rows across frames, in frame order, dropping the frames left without rows.
+// When rows were dropped the first frame gets a notice saying so
+func applyRowLimit(frames data.Frames, limit int) data.Frames {
+	remaining := limit
+	for i, frame := range frames {
+		rows := frame.Rows()
+		if rows <= remaining {
+			remaining -= rows
+			continue
+		}
+
+		for _, field := range frame.Fields {
+			for field.Len() > remaining {
+				field.Delete(field.Len() - 1)
+			}
+		}
+		kept := frames[:i+1]
+		if remaining == 0 && i > 0 {
+			kept = frames[:i]
+		}
+		applyNotices(kept[:1], []data.Notice{{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("Only the first %d rows are shown, narrow the query or raise its limit to see the rest", limit),
+		}})
+		return kept
+	}
+	return frames
+}
+
+// applyFrameStats adds the series count and total number of points to the meta of every graph frame,
+// giving a quick view of the query cardinality in the query inspector
+func applyFrameStats(frames []*data.Frame) {
+	for _, frame := range frames {
+		if frame.Meta == nil || frame.Meta.PreferredVisualization == data.VisTypeTable {
+			continue
+		}
+
+		series := 0
+		for _, field := range frame.Fields {
+			if field.Type() != data.FieldTypeTime {
+				series++
+			}
+		}
+		frame.Meta.Stats = append(frame.Meta.Stats,
+			data.QueryStat{FieldConfig: data.FieldConfig{DisplayName: "Series"}, Value: float64(series)},
+			data.QueryStat{FieldConfig: data.FieldConfig{DisplayName: "Total points"}, Value: float64(series * frame.Rows())},
+		)
+	}
+}
+
+// keepLastRows trims every frame to its final n rows
+func keepLastRows(frames []*data.Frame, n int) {
+	if n <= 0 {
+		return
+	}
+
+	for _, frame := range frames {
+		rows := frame.Rows()
+		if rows <= n {
+			continue
+		}
+		for i, field := range frame.Fields {
+			trimmed := newFieldLike(field, field.Type(), n)
+			for row := 0; row < n; row++ {
+				trimmed.Set(row, field.CopyAt(rows-n+row))
+			}
+			frame.Fields[i] = trimmed
+		}
+	}
+}
+
+// sortTableFrames orders the rows of table frames by time in the requested order. Graph frames are left ascending
+func sortTableFrames(frames []*data.Frame, sortOrder string) {
+	if sortOrder == "" {
+		return
+	}
+
+	for _, frame := range frames {
+		if frame.Meta == nil || frame.Meta.PreferredVisualization != data.VisTypeTable {
+			continue
+		}
+
+		timeField := frame.Fields[0]
+		sortFrameRows(frame, func(i, j int) bool {
+			if sortOrder == sortOrderDesc {
+				return timeField.At(i).(time.Time).After(timeField.At(j).(time.Time))
+			}
+			return timeField.At(i).(time.Time).Before(timeField.At(j).(time.Time))
+		})
+	}
+}
+
+// sortTableFramesByValue orders the rows of table frames by their first numeric field in the requested order, so
+// the worst offenders come first. Rows without a value are kept last. Graph frames are left sorted by time
+func sortTableFramesByValue(frames []*data.Frame, sortOrder string) {
+	if sortOrder == "" {
+		return
+	}
+
+	for _, frame := range frames {
+		if frame.Meta == nil || frame.Meta.PreferredVisualization != data.VisTypeTable {
+			continue
+		}
+
+		var valueField *data.Field
+		for _, field := range frame.Fields {
+			if field.Type().Numeric() {
+				valueField = field
+				break
+			}
+		}
+		if valueField == nil {
+			continue
+		}
+
+		sortFrameRows(frame, func(i, j int) bool {
+			a, _ := valueField.NullableFloatAt(i)
+			b, _ := valueField.NullableFloatAt(j)
+			if a == nil || b == nil {
+				return a != nil
+			}
+			if sortOrder == sortOrderDesc {
+				return *a > *b
+			}
+			return *a < *b
+		})
+	}
+}
+
+// sortFrameRows reorders all the rows of a frame, less receives row indexes of the unsorted frame
+func sortFrameRows(frame *data.Frame, less func(i, j int) bool) {
+	rows := make([]int, frame.Rows())
+	for i := range rows {
+		rows[i] = i
+	}
+	sort.SliceStable(rows, func(a, b int) bool {
+		return less(rows[a], rows[b])
+	})
+
+	for i, field := range frame.Fields {
+		sorted := newFieldLike(field, field.Type(), len(rows))
+		for n, row := range rows {
+			sorted.Set(n, field.CopyAt(row))
+		}
+		frame.Fields[i] = sorted
+	}
+}
+
+// newFieldLike creates an empty field with the same name, labels and config as field
+func newFieldLike(field *data.Field, fieldType data.FieldType, length int) *data.Field {
+	newField := data.NewFieldFromFieldType(fieldType, length)
+	newField.Name = field.Name
+	newField.Labels = field.Labels
+	newField.Config = field.Config
+	return newField
+}
+
+// checkRateLimited returns an errRateLimited error, including the Retry-After value, if Metrist throttled the request
+func checkRateLimited(resp *http.Response) error {
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		return fmt.Errorf("%w, retry after %s", errRateLimited, retryAfter)
+	}
+	return errRateLimited
+}
+
+// instanceLister fetches the instance list of the queried monitors at most once, so the instance helpers of a
+// query share a single request
+type instanceLister struct {
+	client  internal.ClientWithResponsesInterface
+	query   monitorTelemetryQuery
+	fetched bool
+	list    internal.MonitorInstancesResponse
+	err     error
+}
+
+func newInstanceLister(client internal.ClientWithResponsesInterface, query monitorTelemetryQuery) *instanceLister {
+	return &instanceLister{client: client, query: query}
+}
+
+// get returns the instance list, fetching it on the first call
+func (l *instanceLister) get(ctx context.Context) (internal.MonitorInstancesResponse, error) {
+	if !l.fetched {
+		l.list, l.err = fetchInstanceList(ctx, l.client, l.query)
+		l.fetched = true
+	}
+	return l.list, l.err
+}
+
+// expandInstancePatterns replaces instance glob patterns such as `us-*` with the matching instances of the
+// queried monitors. The instance list is only fetched when a pattern is present.
+// It returns false when patterns were given but nothing matched, in which case there is no data to query
+func expandInstancePatterns(ctx context.Context, instances *instanceLister, query *monitorTelemetryQuery) (bool, error) {
+	if query.Instances == nil || !slices.ContainsFunc(*query.Instances, isInstancePattern) {
+		return true, nil
+	}
+
+	instanceList, err := instances.get(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	available := make([]string, 0)
+	for _, item := range instanceList {
+		if item.Instances == nil {
+			continue
+		}
+		available = append(available, *item.Instances...)
+	}
+	available = uniqStrings(available)
+	sort.Strings(available)
+
+	expanded := make([]string, 0)
+	for _, instance := range *query.Instances {
+		if !isInstancePattern(instance) {
+			expanded = append(expanded, instance)
+			continue
+		}
+		for _, candidate := range available {
+			if matched, _ := path.Match(instance, candidate); matched {
+				expanded = append(expanded, candidate)
+			}
+		}
+	}
+	expanded = uniqStrings(expanded)
+	sort.Strings(expanded)
+
+	query.Instances = &expanded
+	return len(expanded) > 0, nil
+}
+
+// fetchInstanceList returns the instances of each of the queried monitors
+func fetchInstanceList(ctx context.Context, client internal.ClientWithResponsesInterface, query monitorTelemetryQuery) (internal.MonitorInstancesResponse, error) {
+	includeShared := query.includeShared()
+	params := internal.BackendWebMonitorInstanceControllerGetParams{M: query.Monitors, IncludeShared: &includeShared}
+	resp, err := client.BackendWebMonitorInstanceControllerGetWithResponse(ctx, &params)
+	if err != nil {
+		return nil, err
+	}
+	if resp.JSON200 == nil {
+		return nil, fmt.Errorf("%w: instance list returned %s", errRemoteResponse, resp.Status())
+	}
+	return *resp.JSON200, nil
+}
+
+// scopeInstances leaves the instances which none of the queried monitors run in out of a query selecting both
+// checks and instances, returning a warning naming them. The monitors, checks and instances are sent as flat
+// lists, so such an instance would otherwise silently match nothing. The check is advisory, when the instance
+// list can't be fetched the query is left as is. It returns false when no selected instance is left
+func scopeInstances(ctx context.Context, instances *instanceLister, query *monitorTelemetryQuery, logger log.Logger) ([]data.Notice, bool) {
+	if query.Checks == nil || len(*query.Checks) == 0 || query.Instances == nil || len(*query.Instances) == 0 {
+		return nil, true
+	}
+
+	instanceList, err := instances.get(ctx)
+	if err != nil {
+		logger.Warn("could not fetch the instance list to scope instances", "error", err)
+		return nil, true
+	}
+
+	available := make(map[string]bool)
+	for _, item := range instanceList {
+		if item.Instances == nil {
+			continue
+		}
+		for _, instance := range *item.Instances {
+			available[instance] = true
+		}
+	}
+
+	matched, unmatched := make([]string, 0), make([]string, 0)
+	for _, instance := range *query.Instances {
+		if available[instance] {
+			matched = append(matched, instance)
+		} else {
+			unmatched = append(unmatched, instance)
+		}
+	}
+	if len(unmatched) == 0 {
+		return nil, true
+	}
+
+	logger.Warn("instances do not belong to any selected monitor", "instances", unmatched, "monitors", query.Monitors)
+	query.Instances = &matched
+	return []data.Notice{{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("Instances %s do not belong to any of the selected monitors and were left out", strings.Join(unmatched, ", ")),
+	}}, len(matched) > 0
+}
+
+// noticesResponse is an empty response carrying notices, or no frames at all without notices
+func noticesResponse(notices []data.Notice) backend.DataResponse {
+	if len(notices) == 0 {
+		return backend.DataResponse{}
+	}
+	frame := data.NewFrame("")
+	frame.Meta = &data.FrameMeta{Notices: notices}
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+func isInstancePattern(instance string) bool {
+	return strings.ContainsAny(instance, "*?[")
 }
 
-func withAPIKey(apiKey string) internal.RequestEditorFn {
+// withAPIKey sends apiKey in the header headerName, prefixed by scheme when one is given
+func withAPIKey(apiKey string, headerName string, scheme string) internal.RequestEditorFn {
+	value := apiKey
+	if scheme != "" {
+		value = scheme + " " + apiKey
+	}
 	return func(ctx context.Context, req *http.Request) error {
-		req.Header.Add("Authorization", apiKey)
+		req.Header.Add(headerName, value)
 		return nil
 	}
 }
 
+// withRateLimit blocks each request until the limiter allows it, or the request context is done
+func withRateLimit(limiter *rate.Limiter) internal.RequestEditorFn {
+	return func(ctx context.Context, req *http.Request) error {
+		return limiter.Wait(ctx)
+	}
+}
+
+// pageSizeClient requests pages of pageSize entries from the paginated endpoints
+type pageSizeClient struct {
+	internal.ClientWithResponsesInterface
+	pageSize int
+}
+
+// withPageSize returns client requesting pages of pageSize entries, or client as is when pageSize is unset
+func withPageSize(client internal.ClientWithResponsesInterface, pageSize int) internal.ClientWithResponsesInterface {
+	if pageSize <= 0 {
+		return client
+	}
+	return &pageSizeClient{ClientWithResponsesInterface: client, pageSize: pageSize}
+}
+
+func (c *pageSizeClient) BackendWebMonitorErrorControllerGetWithResponse(ctx context.Context, params *internal.BackendWebMonitorErrorControllerGetParams, reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorErrorControllerGetResponse, error) {
+	if params.Limit == nil {
+		params.Limit = &c.pageSize
+	}
+	return c.ClientWithResponsesInterface.BackendWebMonitorErrorControllerGetWithResponse(ctx, params, reqEditors...)
+}
+
+func (c *pageSizeClient) BackendWebStatusPageChangeControllerGetWithResponse(ctx context.Context, params *internal.BackendWebStatusPageChangeControllerGetParams, reqEditors ...internal.RequestEditorFn) (*internal.BackendWebStatusPageChangeControllerGetResponse, error) {
+	if params.Limit == nil {
+		params.Limit = &c.pageSize
+	}
+	return c.ClientWithResponsesInterface.BackendWebStatusPageChangeControllerGetWithResponse(ctx, params, reqEditors...)
+}
+
 func nilIfEmpty(slice *[]string) *[]string {
 	if slice == nil || len(*slice) == 0 {
 		return nil
@@ -332,7 +2865,7 @@ func nilIfEmpty(slice *[]string) *[]string {
 }
 
 func ensureTelemetryRequestWithinLast90Days(fromDate time.Time) error {
-	currentTime := time.Now().In(fromDate.Location())
+	currentTime := now().In(fromDate.Location())
 	threeMonthsAgo := currentTime.Add(-durationThreeMonths)
 
 	if time.Time.Before(fromDate, threeMonthsAgo) {
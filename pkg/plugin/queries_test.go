@@ -1,8 +1,24 @@
 package plugin
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/Metrist-Software/metrist-grafana-datasource/pkg/internal"
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/time/rate"
 )
 
 func TestEnsureTelemetryRequestWithinLast90Days(t *testing.T) {
@@ -14,3 +30,1514 @@ func TestEnsureTelemetryRequestWithinLast90Days(t *testing.T) {
 		t.Errorf("TestEnsureTelemetryRequestWithinLast90Days() did not return an error when it was expected")
 	}
 }
+
+func TestEnsureTelemetryRequestWithinLast90DaysBoundary(t *testing.T) {
+	defer func(clock func() time.Time) { now = clock }(now)
+	fixedNow := time.Date(2023, 3, 31, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixedNow }
+
+	tests := []struct {
+		name    string
+		from    time.Time
+		wantErr bool
+	}{
+		{name: "exactly at the limit", from: fixedNow.Add(-durationThreeMonths)},
+		{name: "just inside the limit", from: fixedNow.Add(-durationThreeMonths + time.Second)},
+		{name: "just outside the limit", from: fixedNow.Add(-durationThreeMonths - time.Second), wantErr: true},
+		{name: "just outside the limit in another time zone", from: fixedNow.Add(-durationThreeMonths - time.Second).In(time.FixedZone("UTC+5", 5*60*60)), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ensureTelemetryRequestWithinLast90Days(tt.from); (err != nil) != tt.wantErr {
+				t.Errorf("ensureTelemetryRequestWithinLast90Days() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildFramesGroupBy(t *testing.T) {
+	errorCounts := []internal.MonitorErrorCount{
+		{Check: ptr("check1"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon1"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+		{Check: ptr("check2"), Count: ptr(2), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon1"), Timestamp: ptr("2022-12-07T18:29:06Z")},
+		{Check: ptr("check1"), Count: ptr(3), Instance: ptr("us-west-1"), MonitorLogicalName: ptr("mon2"), Timestamp: ptr("2022-12-07T18:30:06Z")},
+	}
+	frameData := make([]internal.FrameData, len(errorCounts))
+	for i := range errorCounts {
+		frameData[i] = &errorCounts[i]
+	}
+
+	tests := []struct {
+		name      string
+		groupBy   []string
+		wantNames []string
+		wantRows  []int
+	}{
+		{name: "returns a single frame when not grouped", groupBy: nil, wantNames: []string{""}, wantRows: []int{3}},
+		{name: "returns a frame per monitor", groupBy: []string{"monitor"}, wantNames: []string{"mon1", "mon2"}, wantRows: []int{2, 1}},
+		{name: "returns a frame per monitor and check", groupBy: []string{"monitor", "check"}, wantNames: []string{"mon1:check1", "mon1:check2", "mon2:check1"}, wantRows: []int{1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames := buildFrames(frameData, TableFrameType, nil, frameOptions{groupBy: tt.groupBy}, log.DefaultLogger)
+			sort.Slice(frames, func(i, j int) bool { return frames[i].Name < frames[j].Name })
+
+			names := make([]string, len(frames))
+			rows := make([]int, len(frames))
+			for i, frame := range frames {
+				names[i] = frame.Name
+				rows[i] = frame.Rows()
+				if len(tt.groupBy) > 0 {
+					group := frame.Meta.Custom.(map[string]string)
+					if len(group) != len(tt.groupBy) {
+						t.Errorf("frame %s has group %v, want keys %v", frame.Name, group, tt.groupBy)
+					}
+				}
+			}
+			if diff := cmp.Diff(tt.wantNames, names); diff != "" {
+				t.Errorf("frame names mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantRows, rows); diff != "" {
+				t.Errorf("frame rows mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFillMissingBuckets(t *testing.T) {
+	start := strToTime("2022-12-07T18:00:00Z")
+	labels := data.Labels{"instance": "us-east-1", "check": "Check", "monitor": "awslambda"}
+	frame := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{start, start.Add(time.Minute), start.Add(4 * time.Minute), start.Add(5*time.Minute + 30*time.Second)}),
+		data.NewField("response time (ms)", labels, []float32{1, 2, 3, 4}),
+	)
+
+	fillMissingBuckets(frame, time.Minute)
+
+	want := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{start, start.Add(time.Minute), start.Add(2 * time.Minute), start.Add(3 * time.Minute), start.Add(4 * time.Minute), start.Add(5*time.Minute + 30*time.Second)}),
+		data.NewField("response time (ms)", labels, []*float32{ptr[float32](1), ptr[float32](2), nil, nil, ptr[float32](3), ptr[float32](4)}),
+	)
+	if diff := cmp.Diff(want, frame, data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFillMissingBucketsWithoutInterval(t *testing.T) {
+	start := strToTime("2022-12-07T18:00:00Z")
+	frame := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{start, start.Add(time.Hour)}),
+		data.NewField("response time (ms)", nil, []float32{1, 2}),
+	)
+
+	fillMissingBuckets(frame, 0)
+
+	if frame.Rows() != 2 || frame.Fields[1].Nullable() {
+		t.Errorf("expected the frame to be left untouched without an interval")
+	}
+}
+
+func TestApplyBands(t *testing.T) {
+	start := strToTime("2022-12-07T18:00:00Z")
+	labels := data.Labels{"instance": "us-east-1", "check": "Check", "monitor": "awslambda"}
+	frame := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{start, start.Add(10 * time.Second), start.Add(20 * time.Second), start.Add(40 * time.Second), start.Add(time.Minute), start.Add(2*time.Minute + 10*time.Second), start.Add(3 * time.Minute)}),
+		data.NewField("response time (ms)", labels, []*float32{ptr[float32](10), nil, ptr[float32](40), ptr[float32](25), ptr[float32](7), ptr[float32](3), nil}),
+	)
+
+	applyBands(frame, time.Minute, time.UTC)
+
+	// Null samples are left out of their bucket, and a bucket of only nulls stays null
+	want := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{start, start.Add(time.Minute), start.Add(2 * time.Minute), start.Add(3 * time.Minute)}),
+		data.NewField("response time (ms)", labels, []*float32{ptr[float32](25), ptr[float32](7), ptr[float32](3), nil}),
+		data.NewField("min", labels, []*float32{ptr[float32](10), ptr[float32](7), ptr[float32](3), nil}),
+		data.NewField("max", labels, []*float32{ptr[float32](40), ptr[float32](7), ptr[float32](3), nil}),
+	)
+	if diff := cmp.Diff(want, frame, data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRollUpComponentGroups(t *testing.T) {
+	change := func(component, status string, minute int) internal.StatusPageComponentChange {
+		return internal.StatusPageComponentChange{
+			Component:          ptr(component),
+			MonitorLogicalName: ptr("mon"),
+			Status:             ptr(status),
+			Timestamp:          ptr(strToTime("2022-12-07T18:00:00Z").Add(time.Duration(minute) * time.Minute).Format(time.RFC3339)),
+		}
+	}
+	changes := []internal.StatusPageComponentChange{
+		change("REST", "operational", 0),
+		change("GraphQL", "operational", 1),
+		change("Dashboard", "major_outage", 1),
+		change("GraphQL", "degraded", 2),
+		change("REST", "major_outage", 3),
+		change("REST", "operational", 4),
+		change("GraphQL", "operational", 5),
+	}
+
+	rolledUp := rollUpComponentGroups(changes, map[string]string{"REST": "API", "GraphQL": "API"})
+
+	type row struct{ component, status, timestamp string }
+	got := make([]row, len(rolledUp))
+	for i, change := range rolledUp {
+		got[i] = row{*change.Component, *change.Status, *change.Timestamp}
+	}
+	// The group takes the worst status of its components, and is only recorded when that status changes
+	want := []row{
+		{"API", "operational", "2022-12-07T18:00:00Z"},
+		{"Dashboard", "major_outage", "2022-12-07T18:01:00Z"},
+		{"API", "degraded", "2022-12-07T18:02:00Z"},
+		{"API", "major_outage", "2022-12-07T18:03:00Z"},
+		{"API", "degraded", "2022-12-07T18:04:00Z"},
+		{"API", "operational", "2022-12-07T18:05:00Z"},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(row{})); diff != "" {
+		t.Errorf("rolled up changes mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestApplyStatusDurations(t *testing.T) {
+	start := strToTime("2022-12-07T18:00:00Z")
+	frame := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{start.Add(10 * time.Minute), start, start.Add(5 * time.Minute), start.Add(time.Minute), start.Add(20 * time.Minute)}),
+		data.NewField("status", nil, []int8{2, 4, 3, 2, 4}),
+		data.NewField("component", nil, []string{"api", "api", "api", "web", "web"}),
+		data.NewField("monitor", nil, []string{"mon", "mon", "mon", "mon", "mon"}),
+	)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+
+	applyStatusDurations([]*data.Frame{frame})
+
+	durationField, i := frame.FieldByName("duration")
+	if i < 0 {
+		t.Fatal("expected a duration field")
+	}
+	got := make([]*float64, durationField.Len())
+	for row := range got {
+		got[row] = durationField.At(row).(*float64)
+	}
+	// The api changes at 0, 5 and 10 minutes, the web component at 1 and 20 minutes
+	want := []*float64{nil, ptr(300.0), ptr(300.0), ptr(1140.0), nil}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("durations mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildFramesOrdering(t *testing.T) {
+	errorCounts := []internal.MonitorErrorCount{
+		{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-west-2"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+		{Check: ptr("check"), Count: ptr(1), Instance: ptr("eu-west-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+		{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+		{Check: ptr("check"), Count: ptr(1), Instance: ptr("ap-south-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+	}
+	frameData := make([]internal.FrameData, len(errorCounts))
+	for i := range errorCounts {
+		frameData[i] = &errorCounts[i]
+	}
+
+	want := []string{"ap-south-1", "eu-west-1", "us-east-1", "us-west-2"}
+	for run := 0; run < 20; run++ {
+		frames := buildFrames(frameData, GraphFrameType, nil, frameOptions{}, log.DefaultLogger)
+		got := make([]string, len(frames))
+		for i, frame := range frames {
+			got[i] = frame.Fields[1].Labels["instance"]
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("frame order mismatch on run %d (-want +got):\n%s", run, diff)
+		}
+	}
+}
+
+func TestBuildErrorHeatmapFrame(t *testing.T) {
+	errorCounts := []monitorErrorCount{
+		{MonitorErrorCount: internal.MonitorErrorCount{Check: ptr("check1"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:00:10Z")}},
+		{MonitorErrorCount: internal.MonitorErrorCount{Check: ptr("check2"), Count: ptr(2), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:00:50Z")}},
+		{MonitorErrorCount: internal.MonitorErrorCount{Check: ptr("check1"), Count: ptr(4), Instance: ptr("eu-west-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:01:10Z")}},
+		{MonitorErrorCount: internal.MonitorErrorCount{Check: ptr("check1"), Count: ptr(5), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:02:00Z")}},
+	}
+
+	frame := buildErrorHeatmapFrame(errorCounts, time.Minute, time.UTC, log.DefaultLogger)
+
+	want := data.NewFrame("errors heatmap",
+		data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:00:00Z"), strToTime("2022-12-07T18:01:00Z"), strToTime("2022-12-07T18:02:00Z")}),
+		data.NewField("eu-west-1", nil, []int64{0, 4, 0}),
+		data.NewField("us-east-1", nil, []int64{3, 0, 5}),
+	)
+	want.Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide}
+	if diff := cmp.Diff(want, frame, data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	editor := withRateLimit(rate.NewLimiter(rate.Limit(20), 1))
+	req, _ := http.NewRequest(http.MethodGet, "https://app.metrist.io", nil)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := editor(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests at 20/s took %v, expected them to be spaced by the limiter", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := editor(ctx, req); err == nil {
+		t.Errorf("expected an error when the context is done")
+	}
+}
+
+func TestWithAPIKey(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		settings   datasourceSettings
+		wantHeader string
+		wantValue  string
+	}{
+		{name: "default", settings: datasourceSettings{}, wantHeader: "Authorization", wantValue: "secret"},
+		{name: "bearer", settings: datasourceSettings{AuthScheme: "Bearer"}, wantHeader: "Authorization", wantValue: "Bearer secret"},
+		{name: "custom header", settings: datasourceSettings{AuthHeaderName: "X-Api-Key"}, wantHeader: "X-Api-Key", wantValue: "secret"},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "https://app.metrist.io", nil)
+		if err := withAPIKey("secret", tc.settings.authHeaderName(), tc.settings.AuthScheme)(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get(tc.wantHeader); got != tc.wantValue {
+			t.Errorf("%s: expected %s header %q, got %q", tc.name, tc.wantHeader, tc.wantValue, got)
+		}
+		if tc.wantHeader != "Authorization" && req.Header.Get("Authorization") != "" {
+			t.Errorf("%s: expected no Authorization header with a custom header name", tc.name)
+		}
+	}
+}
+
+func TestWithPageSize(t *testing.T) {
+	limits := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits <- r.URL.Query().Get("limit")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"entries": [], "metadata": {}}`))
+	}))
+	defer server.Close()
+
+	client, err := internal.NewClientWithResponses(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	if _, _, err := fetchAllMonitorErrors(context.Background(), withPageSize(client, 500), monitorTelemetryQuery{Monitors: []string{"mon"}}, tr, log.DefaultLogger); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-limits; got != "500" {
+		t.Errorf("errors request sent limit %q, want 500", got)
+	}
+
+	if _, _, err := fetchAllStatusPageMonitor(context.Background(), withPageSize(client, 500), monitorTelemetryQuery{Monitors: []string{"mon"}}, tr, log.DefaultLogger); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-limits; got != "500" {
+		t.Errorf("status page request sent limit %q, want 500", got)
+	}
+
+	if withPageSize(client, 0) != internal.ClientWithResponsesInterface(client) {
+		t.Errorf("expected the client to be left as is without a page size")
+	}
+}
+
+func TestSharedExcludedExplicitly(t *testing.T) {
+	requests := make(chan url.Values, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, endpointMonitorTelemetry) {
+			_, _ = w.Write([]byte(`[]`))
+		} else {
+			_, _ = w.Write([]byte(`{"entries": [], "metadata": {}}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := internal.NewClientWithResponses(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+	query := monitorTelemetryQuery{Monitors: []string{"mon"}, IncludeShared: ptr(false)}
+
+	if _, _, err := fetchAllMonitorErrors(context.Background(), client, query, tr, log.DefaultLogger); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-requests; !got.Has("only_shared") || got.Get("only_shared") != "false" {
+		t.Errorf("errors request sent only_shared %q, want false", got.Get("only_shared"))
+	}
+
+	if _, err := fetchMonitorTelemetry(context.Background(), client, query, tr, log.DefaultLogger); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-requests; !got.Has("include_shared") || got.Get("include_shared") != "false" {
+		t.Errorf("telemetry request sent include_shared %q, want false", got.Get("include_shared"))
+	}
+}
+
+func TestBuildFramesSeriesName(t *testing.T) {
+	errorCounts := []internal.MonitorErrorCount{
+		{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+	}
+	frameData := []internal.FrameData{&errorCounts[0]}
+
+	for _, tc := range []struct {
+		name       string
+		query      monitorTelemetryQuery
+		frameType  frameType
+		wantFields []string
+	}{
+		{name: "graph", query: monitorTelemetryQuery{SeriesName: "prod errors"}, frameType: GraphFrameType, wantFields: []string{"time", "prod errors count"}},
+		{name: "wide table", query: monitorTelemetryQuery{SeriesName: "prod errors"}, frameType: TableFrameType, wantFields: []string{"time", "prod errors count", "instance", "check", "monitor", "error_message"}},
+		{name: "long table", query: monitorTelemetryQuery{SeriesName: "prod errors"}, frameType: LongTableFrameType, wantFields: []string{"time", "metric", "prod errors value"}},
+		{name: "reduced", query: monitorTelemetryQuery{SeriesName: "prod errors"}, frameType: ReducedFrameType, wantFields: []string{"prod errors count"}},
+		{name: "unset", query: monitorTelemetryQuery{}, frameType: GraphFrameType, wantFields: []string{"time", "count"}},
+	} {
+		frame := buildFrames(frameData, tc.frameType, nil, tc.query.frameOptions(), log.DefaultLogger)[0]
+		gotFields := make([]string, len(frame.Fields))
+		for i, field := range frame.Fields {
+			gotFields[i] = field.Name
+		}
+		if diff := cmp.Diff(tc.wantFields, gotFields); diff != "" {
+			t.Errorf("%s: field mismatch (-want +got):\n%s", tc.name, diff)
+		}
+	}
+}
+
+func TestBuildFramesMaxSeries(t *testing.T) {
+	errorCounts := []internal.MonitorErrorCount{
+		{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+		{Check: ptr("check"), Count: ptr(2), Instance: ptr("us-west-2"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+		{Check: ptr("check"), Count: ptr(3), Instance: ptr("eu-west-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+		{Check: ptr("check"), Count: ptr(4), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:29:06Z")},
+	}
+	frameData := make([]internal.FrameData, len(errorCounts))
+	for i := range errorCounts {
+		frameData[i] = &errorCounts[i]
+	}
+
+	frames := buildFrames(frameData, GraphFrameType, nil, monitorTelemetryQuery{MaxSeries: 2}.frameOptions(), log.DefaultLogger)
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+	if frames[0].Fields[1].Len() != 2 {
+		t.Errorf("expected existing series to keep growing past the cap, got %d rows", frames[0].Fields[1].Len())
+	}
+	if len(frames[0].Meta.Notices) != 1 || frames[0].Meta.Notices[0].Severity != data.NoticeSeverityWarning {
+		t.Errorf("expected a single warning notice, got %v", frames[0].Meta.Notices)
+	}
+	if len(frames[1].Meta.Notices) != 0 {
+		t.Errorf("expected the notice only on the first frame, got %v", frames[1].Meta.Notices)
+	}
+
+	frames = buildFrames(frameData, GraphFrameType, nil, monitorTelemetryQuery{}.frameOptions(), log.DefaultLogger)
+	if len(frames) != 3 {
+		t.Fatalf("expected 3 frames without a cap, got %d", len(frames))
+	}
+	for _, frame := range frames {
+		if len(frame.Meta.Notices) != 0 {
+			t.Errorf("expected no notices without a cap, got %v", frame.Meta.Notices)
+		}
+	}
+}
+
+func TestBuildFramesAggregateInstances(t *testing.T) {
+	telemetry := []internal.MonitorTelemetry{
+		{Check: ptr("check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr(float32(10))},
+		{Check: ptr("check"), Instance: ptr("us-west-2"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr(float32(30))},
+		{Check: ptr("check"), Instance: ptr("eu-west-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr(float32(50))},
+		{Check: ptr("check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:29:06Z"), Value: ptr(float32(20))},
+		{Check: ptr("other"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr(float32(5))},
+	}
+	frameData := make([]internal.FrameData, len(telemetry))
+	for i := range telemetry {
+		frameData[i] = &telemetry[i]
+	}
+
+	frames := buildFrames(frameData, GraphFrameType, nil, monitorTelemetryQuery{AggregateInstances: true}.frameOptions(), log.DefaultLogger)
+	if len(frames) != 2 {
+		t.Fatalf("expected a series per check, got %d frames", len(frames))
+	}
+
+	want := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:28:06Z"), strToTime("2022-12-07T18:29:06Z")}),
+		data.NewField("response time (ms)", data.Labels{"check": "check", "monitor": "mon"}, []*float32{ptr[float32](30), ptr[float32](20)}),
+	)
+	if diff := cmp.Diff(want.Fields, frames[0].Fields, data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("aggregated series mismatch (-want +got):\n%s", diff)
+	}
+	if got := frames[1].Fields[1].Labels; got["check"] != "other" || got["instance"] != "" {
+		t.Errorf("expected the other check without an instance label, got %v", got)
+	}
+
+	frames = buildFrames(frameData, GraphFrameType, nil, monitorTelemetryQuery{}.frameOptions(), log.DefaultLogger)
+	if len(frames) != 4 {
+		t.Errorf("expected a series per instance without aggregation, got %d frames", len(frames))
+	}
+}
+
+func TestQueryMonitorErrorsAggregateInstances(t *testing.T) {
+	client := &stubClient{errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+		JSON200: &internal.MonitorErrorResponse{
+			Entries: &[]internal.MonitorErrorCount{
+				{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+				{Check: ptr("check"), Count: ptr(2), Instance: ptr("us-west-2"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+				{Check: ptr("check"), Count: ptr(4), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:29:06Z")},
+			},
+			Metadata: &internal.PagingMetadata{},
+		},
+	}}
+	timeRange := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	query := backend.DataQuery{JSON: []byte(`{"monitors": ["mon"], "aggregateInstances": true}`), TimeRange: timeRange}
+	resp, err := QueryMonitorErrors(context.Background(), query, client, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graphs := make([]*data.Frame, 0)
+	for _, frame := range resp.Frames {
+		if frame.Meta.PreferredVisualization == data.VisTypeGraph {
+			graphs = append(graphs, frame)
+		}
+	}
+	if len(graphs) != 1 {
+		t.Fatalf("expected the instances to be merged into a single series, got %d", len(graphs))
+	}
+	// Error counts add up rather than being averaged
+	got := make([]int64, graphs[0].Rows())
+	for i := range got {
+		got[i] = graphs[0].Fields[1].At(i).(int64)
+	}
+	if diff := cmp.Diff([]int64{3, 4}, got); diff != "" {
+		t.Errorf("merged counts mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryMonitorErrorsLabelsMergeSeries(t *testing.T) {
+	client := &stubClient{errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+		JSON200: &internal.MonitorErrorResponse{
+			Entries: &[]internal.MonitorErrorCount{
+				{Check: ptr("ping"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+				{Check: ptr("login"), Count: ptr(2), Instance: ptr("us-west-2"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+				{Check: ptr("ping"), Count: ptr(2), Instance: ptr("us-west-2"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+			},
+			Metadata: &internal.PagingMetadata{},
+		},
+	}}
+	timeRange := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	query := backend.DataQuery{JSON: []byte(`{"monitors": ["mon"], "labels": ["monitor"]}`), TimeRange: timeRange}
+	resp, err := QueryMonitorErrors(context.Background(), query, client, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graphs := make([]*data.Frame, 0)
+	for _, frame := range resp.Frames {
+		if frame.Meta.PreferredVisualization == data.VisTypeGraph {
+			graphs = append(graphs, frame)
+		}
+	}
+	if len(graphs) != 1 {
+		t.Fatalf("expected the checks and instances to be merged into a single series, got %d", len(graphs))
+	}
+	if graphs[0].Rows() != 1 {
+		t.Fatalf("expected a single row, got %d", graphs[0].Rows())
+	}
+	if got := graphs[0].Fields[1].At(0).(int64); got != 5 {
+		t.Errorf("expected the merged series to count all 5 errors, got %d", got)
+	}
+}
+
+func TestBuildFramesLabels(t *testing.T) {
+	telemetry := []internal.MonitorTelemetry{
+		{Check: ptr("check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr(float32(10))},
+	}
+	frameData := []internal.FrameData{&telemetry[0]}
+
+	for _, tc := range []struct {
+		name   string
+		labels []string
+		want   data.Labels
+	}{
+		{name: "check only", labels: []string{"check"}, want: data.Labels{"check": "check"}},
+		{name: "instance and monitor", labels: []string{"instance", "monitor"}, want: data.Labels{"instance": "us-east-1", "monitor": "mon"}},
+		{name: "unset", want: data.Labels{"check": "check", "instance": "us-east-1", "monitor": "mon"}},
+	} {
+		frames := buildFrames(frameData, GraphFrameType, nil, monitorTelemetryQuery{Labels: tc.labels}.frameOptions(), log.DefaultLogger)
+		if diff := cmp.Diff(tc.want, frames[0].Fields[1].Labels); diff != "" {
+			t.Errorf("%s: labels mismatch (-want +got):\n%s", tc.name, diff)
+		}
+	}
+
+	frames := buildFrames(frameData, TableFrameType, nil, monitorTelemetryQuery{Labels: []string{"check"}}.frameOptions(), log.DefaultLogger)
+	if len(frames[0].Fields) != 5 {
+		t.Errorf("expected table frames to keep all their columns, got %d fields", len(frames[0].Fields))
+	}
+}
+
+func TestBuildFramesLabelsMergeSeries(t *testing.T) {
+	telemetry := []internal.MonitorTelemetry{
+		{Check: ptr("ping"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr(float32(10))},
+		{Check: ptr("login"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr(float32(30))},
+		{Check: ptr("ping"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:29:06Z"), Value: ptr(float32(20))},
+	}
+	frameData := make([]internal.FrameData, len(telemetry))
+	for i := range telemetry {
+		frameData[i] = &telemetry[i]
+	}
+
+	frames := buildFrames(frameData, GraphFrameType, nil, monitorTelemetryQuery{Labels: []string{"monitor"}}.frameOptions(), log.DefaultLogger)
+	if len(frames) != 1 {
+		t.Fatalf("expected the checks to be merged into a single series, got %d", len(frames))
+	}
+	want := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{strToTime("2022-12-07T18:28:06Z"), strToTime("2022-12-07T18:29:06Z")}),
+		data.NewField("response time (ms)", data.Labels{"monitor": "mon"}, []*float32{ptr(float32(20)), ptr(float32(20))}),
+	)
+	if diff := cmp.Diff(want.Fields, frames[0].Fields, data.FrameTestCompareOptions()...); diff != "" {
+		t.Errorf("merged series mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildFramesTableFormat(t *testing.T) {
+	telemetry := []internal.MonitorTelemetry{
+		{Check: ptr("check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr(float32(10))},
+		{Check: ptr("check"), Instance: ptr("us-west-2"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:29:06Z"), Value: ptr(float32(20))},
+	}
+	frameData := make([]internal.FrameData, len(telemetry))
+	for i := range telemetry {
+		frameData[i] = &telemetry[i]
+	}
+
+	for _, tc := range []struct {
+		format     string
+		wantFields []string
+	}{
+		{format: tableFormatWide, wantFields: []string{"time", "response time (ms)", "instance", "check", "monitor"}},
+		{format: tableFormatLong, wantFields: []string{"time", "metric", "value"}},
+	} {
+		query := monitorTelemetryQuery{TableFormat: tc.format}
+		frames := buildFrames(frameData, query.tableFrameType(), nil, query.frameOptions(), log.DefaultLogger)
+		if len(frames) != 1 {
+			t.Fatalf("%s: expected a single table frame, got %d", tc.format, len(frames))
+		}
+
+		frame := frames[0]
+		gotFields := make([]string, len(frame.Fields))
+		for i, field := range frame.Fields {
+			gotFields[i] = field.Name
+		}
+		if diff := cmp.Diff(tc.wantFields, gotFields); diff != "" {
+			t.Errorf("%s: field mismatch (-want +got):\n%s", tc.format, diff)
+		}
+		if frame.Rows() != 2 {
+			t.Errorf("%s: expected 2 rows, got %d", tc.format, frame.Rows())
+		}
+	}
+
+	query := monitorTelemetryQuery{TableFormat: tableFormatLong}
+	frame := buildFrames(frameData, query.tableFrameType(), nil, query.frameOptions(), log.DefaultLogger)[0]
+	if got := frame.Fields[1].At(1); got != "us-west-2-check-mon" {
+		t.Errorf("expected the metric column to name the series, got %v", got)
+	}
+	if got := frame.Fields[2].At(1).(*float32); got == nil || *got != 20 {
+		t.Errorf("expected the value column to carry the series value, got %v", got)
+	}
+	if frame.Meta.Type != data.FrameTypeTimeSeriesLong {
+		t.Errorf("expected a long frame, got %s", frame.Meta.Type)
+	}
+}
+
+func TestExpandInstancePatterns(t *testing.T) {
+	client := &stubClient{instancesResponse: internal.BackendWebMonitorInstanceControllerGetResponse{
+		JSON200: &internal.MonitorInstancesResponse{
+			{Instances: &[]string{"us-east-1", "us-west-2", "eu-west-1"}, MonitorLogicalName: ptr("mon_one")},
+			{Instances: &[]string{"us-east-1", "ap-south-1"}, MonitorLogicalName: ptr("mon_two")},
+			{MonitorLogicalName: ptr("mon_three")},
+		},
+	}}
+
+	tests := []struct {
+		name      string
+		instances *[]string
+		want      *[]string
+		wantOk    bool
+	}{
+		{name: "no instances", instances: nil, want: nil, wantOk: true},
+		{name: "no patterns", instances: &[]string{"us-east-1"}, want: &[]string{"us-east-1"}, wantOk: true},
+		{name: "glob pattern", instances: &[]string{"us-*"}, want: &[]string{"us-east-1", "us-west-2"}, wantOk: true},
+		{name: "pattern and plain instance", instances: &[]string{"*-west-*", "ap-south-1"}, want: &[]string{"ap-south-1", "eu-west-1", "us-west-2"}, wantOk: true},
+		{name: "no match", instances: &[]string{"sa-*"}, want: &[]string{}, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := monitorTelemetryQuery{Monitors: []string{"mon_one", "mon_two", "mon_three"}, Instances: tt.instances}
+			ok, err := expandInstancePatterns(context.Background(), newInstanceLister(client, query), &query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOk {
+				t.Errorf("expandInstancePatterns() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if diff := cmp.Diff(tt.want, query.Instances); diff != "" {
+				t.Errorf("instances mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQueryMonitorErrorsInstancePatternNoMatch(t *testing.T) {
+	client := &stubClient{
+		instancesResponse: internal.BackendWebMonitorInstanceControllerGetResponse{
+			JSON200: &internal.MonitorInstancesResponse{
+				{Instances: &[]string{"us-east-1"}, MonitorLogicalName: ptr("mon_one")},
+			},
+		},
+		errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+			JSON200: &internal.MonitorErrorResponse{
+				Entries:  &[]internal.MonitorErrorCount{{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon_one"), Timestamp: ptr("2022-12-07T18:28:06Z")}},
+				Metadata: &internal.PagingMetadata{},
+			},
+		},
+	}
+
+	query := backend.DataQuery{JSON: []byte(`{"monitors": ["mon_one"], "instances": ["eu-*"]}`)}
+	resp, err := QueryMonitorErrors(context.Background(), query, client, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Frames) != 0 {
+		t.Errorf("expected no frames when no instance matches the pattern, got %d", len(resp.Frames))
+	}
+}
+
+type countingInstancesClient struct {
+	recordingTelemetryClient
+	calls int
+}
+
+func (c *countingInstancesClient) BackendWebMonitorInstanceControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorInstanceControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorInstanceControllerGetResponse, error) {
+	c.calls++
+	return c.recordingTelemetryClient.BackendWebMonitorInstanceControllerGetWithResponse(ctx, params, reqEditors...)
+}
+
+func TestQueryMonitorTelemetryScopesInstances(t *testing.T) {
+	instances := internal.BackendWebMonitorInstanceControllerGetResponse{
+		JSON200: &internal.MonitorInstancesResponse{
+			{Instances: &[]string{"us-east-1", "us-west-2"}, MonitorLogicalName: ptr("mon_one")},
+		},
+	}
+	timeRange := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	tests := []struct {
+		name         string
+		query        string
+		wantRequests int
+		wantNotice   string
+	}{
+		{name: "keeps matching instances", query: `{"monitors": ["mon_one"], "checks": ["check"], "instances": ["us-east-1"]}`, wantRequests: 1},
+		{name: "warns about a mismatched instance", query: `{"monitors": ["mon_one"], "checks": ["check"], "instances": ["us-east-1", "ap-south-1"]}`, wantRequests: 1, wantNotice: "Instances ap-south-1 do not belong to any of the selected monitors and were left out"},
+		{name: "skips the request when no instance matches", query: `{"monitors": ["mon_one"], "checks": ["check"], "instances": ["ap-south-1"]}`, wantRequests: 0, wantNotice: "Instances ap-south-1 do not belong to any of the selected monitors and were left out"},
+		{name: "expands patterns from the same instance list", query: `{"monitors": ["mon_one"], "checks": ["check"], "instances": ["us-*"]}`, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &countingInstancesClient{recordingTelemetryClient: recordingTelemetryClient{stubClient: stubClient{instancesResponse: instances}}}
+			resp, err := QueryMonitorTelemetry(context.Background(), backend.DataQuery{JSON: []byte(tt.query), TimeRange: timeRange}, client, log.DefaultLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(client.requests) != tt.wantRequests {
+				t.Errorf("expected %d telemetry requests, got %d", tt.wantRequests, len(client.requests))
+			}
+			if client.calls != 1 {
+				t.Errorf("expected the instance list to be fetched once, got %d requests", client.calls)
+			}
+			if len(resp.Frames) == 0 {
+				t.Fatal("expected frames")
+			}
+			for _, frame := range resp.Frames {
+				notices := frame.Meta.Notices
+				if tt.wantNotice == "" && len(notices) != 0 {
+					t.Errorf("expected no notices, got %v", notices)
+				}
+				if tt.wantNotice != "" && (len(notices) != 1 || notices[0].Text != tt.wantNotice || notices[0].Severity != data.NoticeSeverityWarning) {
+					t.Errorf("expected the warning %q, got %v", tt.wantNotice, notices)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyRowLimit(t *testing.T) {
+	newFrames := func(rows ...int) data.Frames {
+		frames := make(data.Frames, len(rows))
+		for i, n := range rows {
+			values := make([]int64, n)
+			frames[i] = data.NewFrame(fmt.Sprint(i), data.NewField("count", nil, values))
+		}
+		return frames
+	}
+
+	tests := []struct {
+		name       string
+		rows       []int
+		limit      int
+		wantRows   []int
+		wantNotice bool
+	}{
+		{name: "within the limit", rows: []int{2, 3}, limit: 5, wantRows: []int{2, 3}},
+		{name: "trims the frame crossing the limit", rows: []int{2, 3, 4}, limit: 4, wantRows: []int{2, 2}, wantNotice: true},
+		{name: "drops frames past the limit", rows: []int{2, 3, 4}, limit: 2, wantRows: []int{2}, wantNotice: true},
+		{name: "trims a single frame", rows: []int{10}, limit: 3, wantRows: []int{3}, wantNotice: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames := applyRowLimit(newFrames(tt.rows...), tt.limit)
+			gotRows := make([]int, len(frames))
+			for i, frame := range frames {
+				gotRows[i] = frame.Rows()
+			}
+			if diff := cmp.Diff(tt.wantRows, gotRows); diff != "" {
+				t.Errorf("rows mismatch (-want +got):\n%s", diff)
+			}
+			hasNotice := frames[0].Meta != nil && len(frames[0].Meta.Notices) == 1
+			if hasNotice != tt.wantNotice {
+				t.Errorf("notice = %v, want %v", frames[0].Meta, tt.wantNotice)
+			}
+		})
+	}
+}
+
+func TestApplyFrameStats(t *testing.T) {
+	graph := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{time.Unix(0, 0), time.Unix(60, 0), time.Unix(120, 0)}),
+		data.NewField("response time (ms)", data.Labels{"instance": "us-east-1"}, []float32{1, 2, 3}),
+		data.NewField("count", data.Labels{"instance": "us-east-1"}, []int64{1, 0, 2}),
+	)
+	graph.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeGraph}
+	table := data.NewFrame("", data.NewField("time", nil, []time.Time{time.Unix(0, 0)}))
+	table.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+
+	applyFrameStats([]*data.Frame{graph, table})
+
+	want := []data.QueryStat{
+		{FieldConfig: data.FieldConfig{DisplayName: "Series"}, Value: 2},
+		{FieldConfig: data.FieldConfig{DisplayName: "Total points"}, Value: 6},
+	}
+	if diff := cmp.Diff(want, graph.Meta.Stats); diff != "" {
+		t.Errorf("graph frame stats mismatch (-want +got):\n%s", diff)
+	}
+	if table.Meta.Stats != nil {
+		t.Errorf("expected no stats on table frames, got %v", table.Meta.Stats)
+	}
+}
+
+// timeRangeErrorClient returns the error counts falling within the requested time range, inclusive of both ends
+// like the upstream API
+type timeRangeErrorClient struct {
+	stubClient
+	entries []internal.MonitorErrorCount
+}
+
+func (c *timeRangeErrorClient) BackendWebMonitorErrorControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorErrorControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorErrorControllerGetResponse, error) {
+	entries := make([]internal.MonitorErrorCount, 0)
+	for _, entry := range c.entries {
+		timestamp := strToTime(*entry.Timestamp)
+		if !timestamp.Before(params.From) && !timestamp.After(params.To) {
+			entries = append(entries, entry)
+		}
+	}
+	return &internal.BackendWebMonitorErrorControllerGetResponse{
+		JSON200: &internal.MonitorErrorResponse{Entries: &entries, Metadata: &internal.PagingMetadata{}},
+	}, nil
+}
+
+func TestFetchAllMonitorErrorsShards(t *testing.T) {
+	from := strToTime("2022-12-07T00:00:00Z")
+	client := &timeRangeErrorClient{}
+	// Every 5 minutes, so errors fall exactly on the boundary of 2 shards
+	for minute := 0; minute < 100; minute += 5 {
+		client.entries = append(client.entries, internal.MonitorErrorCount{
+			Check:              ptr("check"),
+			Count:              ptr(minute),
+			Instance:           ptr("us-east-1"),
+			MonitorLogicalName: ptr("mon"),
+			Timestamp:          ptr(from.Add(time.Duration(minute) * time.Minute).Format(time.RFC3339)),
+		})
+	}
+	tr := backend.TimeRange{From: from, To: from.Add(100 * time.Minute)}
+
+	sequential, _, err := fetchAllMonitorErrors(context.Background(), client, monitorTelemetryQuery{}, tr, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sequential) != len(client.entries) {
+		t.Fatalf("expected %d errors, got %d", len(client.entries), len(sequential))
+	}
+
+	for _, shards := range []int{2, 3, 7} {
+		sharded, _, err := fetchAllMonitorErrors(context.Background(), client, monitorTelemetryQuery{Shards: shards}, tr, log.DefaultLogger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(sequential, sharded, cmp.AllowUnexported(monitorErrorCount{})); diff != "" {
+			t.Errorf("%d shards: merged output mismatch (-sequential +sharded):\n%s", shards, diff)
+		}
+	}
+}
+
+func TestFetchAllMonitorErrorsMaxResultsShards(t *testing.T) {
+	from := strToTime("2022-12-07T00:00:00Z")
+	client := &timeRangeErrorClient{}
+	for minute := 0; minute < 100; minute += 7 {
+		client.entries = append(client.entries, internal.MonitorErrorCount{
+			Check:              ptr("check"),
+			Count:              ptr(minute),
+			Instance:           ptr("us-east-1"),
+			MonitorLogicalName: ptr("mon"),
+			Timestamp:          ptr(from.Add(time.Duration(minute) * time.Minute).Format(time.RFC3339)),
+		})
+	}
+	tr := backend.TimeRange{From: from, To: from.Add(100 * time.Minute)}
+
+	// The shards finish in any order, yet the earliest errors are always the ones kept
+	for run := 0; run < 20; run++ {
+		errorCounts, notices, err := fetchAllMonitorErrors(context.Background(), client, monitorTelemetryQuery{Shards: 4, MaxResults: 5}, tr, log.DefaultLogger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notices) != 1 {
+			t.Errorf("expected a truncation notice, got %v", notices)
+		}
+		got := make([]int, len(errorCounts))
+		for i, errorCount := range errorCounts {
+			got[i] = *errorCount.Count
+		}
+		if diff := cmp.Diff([]int{0, 7, 14, 21, 28}, got); diff != "" {
+			t.Fatalf("run %d: kept errors mismatch (-want +got):\n%s", run, diff)
+		}
+	}
+}
+
+// endlessErrorClient returns pages of pageSize error counts, always with a cursor to the next page
+type endlessErrorClient struct {
+	stubClient
+	pageSize int
+	calls    int
+}
+
+func (c *endlessErrorClient) BackendWebMonitorErrorControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorErrorControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorErrorControllerGetResponse, error) {
+	c.calls++
+	entries := make([]internal.MonitorErrorCount, c.pageSize)
+	for i := range entries {
+		entries[i] = internal.MonitorErrorCount{
+			Check:              ptr("check"),
+			Count:              ptr(1),
+			Instance:           ptr("us-east-1"),
+			MonitorLogicalName: ptr("mon"),
+			Timestamp:          ptr(params.From.Add(time.Duration(c.calls*c.pageSize+i) * time.Second).Format(time.RFC3339)),
+		}
+	}
+	return &internal.BackendWebMonitorErrorControllerGetResponse{
+		JSON200: &internal.MonitorErrorResponse{Entries: &entries, Metadata: &internal.PagingMetadata{CursorAfter: ptr("next")}},
+	}, nil
+}
+
+func TestFetchAllMonitorErrorsMaxResults(t *testing.T) {
+	tr := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	client := &endlessErrorClient{pageSize: 4}
+	errorCounts, notices, err := fetchAllMonitorErrors(context.Background(), client, monitorTelemetryQuery{MaxResults: 10}, tr, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notices) != 1 {
+		t.Error("expected the results to be truncated")
+	}
+	if len(errorCounts) != 10 {
+		t.Errorf("expected 10 errors, got %d", len(errorCounts))
+	}
+	if client.calls != 3 {
+		t.Errorf("expected paging to stop once the cap was reached, got %d requests", client.calls)
+	}
+
+	client = &endlessErrorClient{pageSize: 4}
+	errorCounts, notices, err = fetchAllMonitorErrors(context.Background(), client, monitorTelemetryQuery{MaxResults: 1000}, tr, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notices) != 0 {
+		t.Error("expected the page count cap alone not to be reported as truncated")
+	}
+	if len(errorCounts) != maxPageCount*4 {
+		t.Errorf("expected %d errors, got %d", maxPageCount*4, len(errorCounts))
+	}
+}
+
+func TestQueryMonitorErrorsTruncatedNotice(t *testing.T) {
+	query := backend.DataQuery{
+		JSON:      []byte(`{"monitors": ["mon"], "maxResults": 6}`),
+		TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+	}
+
+	resp, err := QueryMonitorErrors(context.Background(), query, &endlessErrorClient{pageSize: 4}, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Frames) == 0 {
+		t.Fatal("expected frames")
+	}
+	for _, frame := range resp.Frames {
+		if len(frame.Meta.Notices) != 1 || frame.Meta.Notices[0].Severity != data.NoticeSeverityWarning {
+			t.Errorf("expected a truncation warning on frame %q, got %v", frame.Name, frame.Meta.Notices)
+		}
+	}
+}
+
+// failingScopeErrorClient returns a single error count per request, failing either the owned or the shared requests
+type failingScopeErrorClient struct {
+	stubClient
+	failShared bool
+	failOwned  bool
+}
+
+func (c *failingScopeErrorClient) BackendWebMonitorErrorControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorErrorControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorErrorControllerGetResponse, error) {
+	shared := params.OnlyShared != nil && *params.OnlyShared
+	if (shared && c.failShared) || (!shared && c.failOwned) {
+		return nil, errors.New("connection reset")
+	}
+	entries := []internal.MonitorErrorCount{{
+		Check:              ptr("check"),
+		Count:              ptr(1),
+		Instance:           ptr("us-east-1"),
+		MonitorLogicalName: ptr("mon"),
+		Timestamp:          ptr(params.From.Format(time.RFC3339)),
+	}}
+	return &internal.BackendWebMonitorErrorControllerGetResponse{
+		JSON200: &internal.MonitorErrorResponse{Entries: &entries, Metadata: &internal.PagingMetadata{}},
+	}, nil
+}
+
+func TestFetchAllMonitorErrorsPartialResults(t *testing.T) {
+	tr := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+	withShared := monitorTelemetryQuery{IncludeShared: ptr(true)}
+
+	for _, tc := range []struct {
+		name        string
+		client      *failingScopeErrorClient
+		query       monitorTelemetryQuery
+		wantErr     bool
+		wantShared  []bool
+		wantNotices int
+	}{
+		{name: "shared fails", client: &failingScopeErrorClient{failShared: true}, query: withShared, wantShared: []bool{false}, wantNotices: 1},
+		{name: "owned fails", client: &failingScopeErrorClient{failOwned: true}, query: withShared, wantShared: []bool{true}, wantNotices: 1},
+		{name: "both fail", client: &failingScopeErrorClient{failOwned: true, failShared: true}, query: withShared, wantErr: true},
+		{name: "owned fails without shared", client: &failingScopeErrorClient{failOwned: true}, query: monitorTelemetryQuery{IncludeShared: ptr(false)}, wantErr: true},
+		{name: "none fail", client: &failingScopeErrorClient{}, query: withShared, wantShared: []bool{false, true}},
+	} {
+		errorCounts, notices, err := fetchAllMonitorErrors(context.Background(), tc.client, tc.query, tr, log.DefaultLogger)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		gotShared := make([]bool, len(errorCounts))
+		for i, errorCount := range errorCounts {
+			gotShared[i] = errorCount.shared
+		}
+		sort.Slice(gotShared, func(i, j int) bool { return !gotShared[i] && gotShared[j] })
+		if diff := cmp.Diff(tc.wantShared, gotShared); diff != "" {
+			t.Errorf("%s: shared mismatch (-want +got):\n%s", tc.name, diff)
+		}
+		if len(notices) != tc.wantNotices {
+			t.Errorf("%s: expected %d notices, got %v", tc.name, tc.wantNotices, notices)
+		}
+	}
+}
+
+func TestQueryMonitorErrorsPartialResultsNotice(t *testing.T) {
+	query := backend.DataQuery{
+		JSON:      []byte(`{"monitors": ["mon"], "includeShared": true}`),
+		TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+	}
+
+	resp, err := QueryMonitorErrors(context.Background(), query, &failingScopeErrorClient{failShared: true}, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Frames) == 0 {
+		t.Fatal("expected the owned errors to still be returned")
+	}
+	for _, frame := range resp.Frames {
+		if len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, "shared") {
+			t.Errorf("expected a partial results warning on frame %q, got %v", frame.Name, frame.Meta.Notices)
+		}
+	}
+}
+
+func TestOverviewQueriesPartialResultsNotice(t *testing.T) {
+	query := backend.DataQuery{
+		JSON:      []byte(`{"monitors": ["mon"], "includeShared": true, "sloTarget": 99.9}`),
+		TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		Interval:  time.Minute,
+	}
+
+	for name, handler := range map[string]func(context.Context, backend.DataQuery, internal.ClientWithResponsesInterface, log.Logger) (backend.DataResponse, error){
+		"overview":              QueryMonitorOverview,
+		"slo burn rate":         QuerySloBurnRate,
+		"error ratio":           QueryErrorRatio,
+		"instance count":        QueryInstanceCount,
+		"instance availability": QueryInstanceAvailability,
+	} {
+		resp, err := handler(context.Background(), query, &failingScopeErrorClient{failShared: true}, log.DefaultLogger)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(resp.Frames) == 0 {
+			t.Fatalf("%s: expected the owned errors to still be returned", name)
+		}
+		for _, frame := range resp.Frames {
+			if len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, "shared") {
+				t.Errorf("%s: expected a partial results warning on frame %q, got %v", name, frame.Name, frame.Meta.Notices)
+			}
+		}
+	}
+}
+
+func TestShardTimeRange(t *testing.T) {
+	tr := backend.TimeRange{From: strToTime("2022-12-07T00:00:00Z"), To: strToTime("2022-12-07T00:00:10Z")}
+
+	shards := shardTimeRange(tr, 3)
+	if len(shards) != 3 {
+		t.Fatalf("expected 3 shards, got %d", len(shards))
+	}
+	if !shards[0].From.Equal(tr.From) || !shards[2].To.Equal(tr.To) {
+		t.Errorf("shards do not cover the time range: %v", shards)
+	}
+	for i := 1; i < len(shards); i++ {
+		if !shards[i].From.Equal(shards[i-1].To.Add(time.Nanosecond)) {
+			t.Errorf("shard %d does not start right after shard %d ends", i, i-1)
+		}
+	}
+
+	if got := shardTimeRange(tr, 1000); len(got) != maxShards {
+		t.Errorf("expected the shards to be capped at %d, got %d", maxShards, len(got))
+	}
+
+	if got := shardTimeRange(tr, 0); len(got) != 1 || got[0] != tr {
+		t.Errorf("expected the time range to be left whole by default, got %v", got)
+	}
+}
+
+// countingPageClient always returns an empty page which still carries a cursor
+type countingPageClient struct {
+	stubClient
+	errorCalls      int
+	statusPageCalls int
+}
+
+func (c *countingPageClient) BackendWebMonitorErrorControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorErrorControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorErrorControllerGetResponse, error) {
+	c.errorCalls++
+	return &internal.BackendWebMonitorErrorControllerGetResponse{
+		JSON200: &internal.MonitorErrorResponse{Entries: &[]internal.MonitorErrorCount{}, Metadata: &internal.PagingMetadata{CursorAfter: ptr("next")}},
+	}, nil
+}
+
+func (c *countingPageClient) BackendWebStatusPageChangeControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebStatusPageChangeControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebStatusPageChangeControllerGetResponse, error) {
+	c.statusPageCalls++
+	return &internal.BackendWebStatusPageChangeControllerGetResponse{
+		JSON200: &internal.StatusPageChangesResponse{Entries: &[]internal.StatusPageComponentChange{}, Metadata: &internal.PagingMetadata{CursorAfter: ptr("next")}},
+	}, nil
+}
+
+func TestPagingStopsOnEmptyPageWithCursor(t *testing.T) {
+	client := &countingPageClient{}
+	tr := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	if _, _, err := fetchAllMonitorErrors(context.Background(), client, monitorTelemetryQuery{}, tr, log.DefaultLogger); err != nil {
+		t.Fatal(err)
+	}
+	if client.errorCalls != 1 {
+		t.Errorf("expected errors paging to stop after the empty page, got %d requests", client.errorCalls)
+	}
+
+	if _, _, err := fetchAllStatusPageMonitor(context.Background(), client, monitorTelemetryQuery{}, tr, log.DefaultLogger); err != nil {
+		t.Fatal(err)
+	}
+	if client.statusPageCalls != 1 {
+		t.Errorf("expected status page paging to stop after the empty page, got %d requests", client.statusPageCalls)
+	}
+}
+
+func TestFetchAllStatusPageMonitorComponent(t *testing.T) {
+	client := &stubClient{
+		statusPageResponse: internal.BackendWebStatusPageChangeControllerGetResponse{
+			JSON200: &internal.StatusPageChangesResponse{
+				Metadata: &internal.PagingMetadata{},
+				Entries: &[]internal.StatusPageComponentChange{
+					{Component: ptr("api"), MonitorLogicalName: ptr("mon"), Status: ptr("up"), Timestamp: ptr("2022-12-07T17:28:06Z")},
+					{Component: ptr("dashboard"), MonitorLogicalName: ptr("mon"), Status: ptr("degraded"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+					{Component: ptr("api"), MonitorLogicalName: ptr("mon"), Status: ptr("down"), Timestamp: ptr("2022-12-07T19:28:06Z")},
+				},
+			},
+		},
+	}
+	tr := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	changes, _, err := fetchAllStatusPageMonitor(context.Background(), client, monitorTelemetryQuery{Component: "api"}, tr, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(changes))
+	for i, change := range changes {
+		got[i] = *change.Component + "@" + *change.Timestamp
+	}
+	want := []string{"api@2022-12-07T17:28:06Z", "api@2022-12-07T19:28:06Z"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("changes mismatch (-want +got):\n%s", diff)
+	}
+
+	all, _, err := fetchAllStatusPageMonitor(context.Background(), client, monitorTelemetryQuery{}, tr, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Errorf("expected every component without a filter, got %d changes", len(all))
+	}
+}
+
+// recordingTelemetryClient records the monitors and checks of every telemetry request
+type recordingTelemetryClient struct {
+	stubClient
+	mu       sync.Mutex
+	requests []checkScope
+}
+
+func (c *recordingTelemetryClient) BackendWebMonitorTelemetryControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorTelemetryControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorTelemetryControllerGetResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, checkScope{monitors: params.M, checks: params.C})
+	telemetry := make(internal.MonitorTelemetryResponse, 0)
+	for i, monitor := range params.M {
+		telemetry = append(telemetry, internal.MonitorTelemetry{
+			Check:              ptr((*params.C)[0]),
+			Instance:           ptr("us-east-1"),
+			MonitorLogicalName: ptr(monitor),
+			Timestamp:          ptr(time.Date(2022, 12, 7, 18, len(c.requests)+i, 0, 0, time.UTC).Format(time.RFC3339)),
+			Value:              ptr(float32(1)),
+		})
+	}
+	return &internal.BackendWebMonitorTelemetryControllerGetResponse{JSON200: &telemetry}, nil
+}
+
+func TestCheckScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		monitors []string
+		checks   *[]string
+		want     []checkScope
+	}{
+		{
+			name:     "plain checks are sent as is",
+			monitors: []string{"mon_one", "mon_two"},
+			checks:   &[]string{"ping", "login"},
+			want:     []checkScope{{monitors: []string{"mon_one", "mon_two"}, checks: &[]string{"ping", "login"}}},
+		},
+		{
+			name:     "qualified checks from two monitors are scoped to their monitor",
+			monitors: []string{"mon_one", "mon_two"},
+			checks:   &[]string{"mon_one:ping", "mon_two:login"},
+			want: []checkScope{
+				{monitors: []string{"mon_one"}, checks: &[]string{"ping"}},
+				{monitors: []string{"mon_two"}, checks: &[]string{"login"}},
+			},
+		},
+		{
+			name:     "plain checks apply to every monitor and monitors without qualified checks are grouped",
+			monitors: []string{"mon_one", "mon_two", "mon_three"},
+			checks:   &[]string{"mon_one:ping", "login"},
+			want: []checkScope{
+				{monitors: []string{"mon_one"}, checks: &[]string{"ping", "login"}},
+				{monitors: []string{"mon_two", "mon_three"}, checks: &[]string{"login"}},
+			},
+		},
+		{
+			name:     "monitors without a selected check are not queried",
+			monitors: []string{"mon_one", "mon_two", "mon_three"},
+			checks:   &[]string{"mon_one:ping"},
+			want:     []checkScope{{monitors: []string{"mon_one"}, checks: &[]string{"ping"}}},
+		},
+		{
+			name:     "checks shared by several monitors filter each of them",
+			monitors: []string{"mon_one", "mon_two", "mon_three"},
+			checks:   &[]string{"mon_one,mon_two:ping"},
+			want:     []checkScope{{monitors: []string{"mon_one", "mon_two"}, checks: &[]string{"ping"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkScopes(monitorTelemetryQuery{Monitors: tt.monitors, Checks: tt.checks})
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(checkScope{})); diff != "" {
+				t.Errorf("checkScopes() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFetchMonitorTelemetryQualifiedChecks(t *testing.T) {
+	client := &recordingTelemetryClient{}
+	query := monitorTelemetryQuery{Monitors: []string{"mon_one", "mon_two"}, Checks: &[]string{"mon_one:ping", "mon_two:login"}}
+	tr := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	telemetry, err := fetchMonitorTelemetry(context.Background(), client, query, tr, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// scopes are requested concurrently, so the order of the requests is not deterministic
+	sort.Slice(client.requests, func(i, j int) bool { return client.requests[i].monitors[0] < client.requests[j].monitors[0] })
+	want := []checkScope{
+		{monitors: []string{"mon_one"}, checks: &[]string{"ping"}},
+		{monitors: []string{"mon_two"}, checks: &[]string{"login"}},
+	}
+	if diff := cmp.Diff(want, client.requests, cmp.AllowUnexported(checkScope{})); diff != "" {
+		t.Errorf("requests mismatch (-want +got):\n%s", diff)
+	}
+
+	if len(telemetry) != 2 {
+		t.Fatalf("expected telemetry of both scopes, got %d items", len(telemetry))
+	}
+	if !strToTime(*telemetry[0].Timestamp).Before(strToTime(*telemetry[1].Timestamp)) {
+		t.Errorf("expected merged telemetry to be sorted by timestamp, got %s then %s", *telemetry[0].Timestamp, *telemetry[1].Timestamp)
+	}
+}
+
+func TestBuildFramesSkipsIncompleteItems(t *testing.T) {
+	errorCounts := []internal.MonitorErrorCount{
+		{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+		{Check: ptr("check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:29:06Z")},
+		{Check: ptr("check"), Count: ptr(1), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:30:06Z")},
+		{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon")},
+	}
+	frameData := make([]internal.FrameData, len(errorCounts))
+	for i := range errorCounts {
+		frameData[i] = &errorCounts[i]
+	}
+
+	for _, frameType := range []frameType{GraphFrameType, TableFrameType} {
+		frames := buildFrames(frameData, frameType, nil, frameOptions{}, log.DefaultLogger)
+		if len(frames) != 1 || frames[0].Rows() != 1 {
+			t.Errorf("frame type %d: expected a single frame with only the complete row", frameType)
+		}
+	}
+
+	if got := completeOnly(errorCounts, log.DefaultLogger); len(got) != 1 {
+		t.Errorf("completeOnly() kept %d items, want 1", len(got))
+	}
+}
+
+func TestBuildFramesTelemetryWithMissingFields(t *testing.T) {
+	telemetry := []internal.MonitorTelemetry{
+		{Check: ptr("check"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr(float32(10))},
+		{Check: ptr("check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:29:06Z")},
+	}
+	frameData := make([]internal.FrameData, len(telemetry))
+	for i := range telemetry {
+		frameData[i] = &telemetry[i]
+	}
+
+	frames := buildFrames(frameData, GraphFrameType, nil, frameOptions{}, log.DefaultLogger)
+	if len(frames) != 2 {
+		t.Fatalf("expected the telemetry without a value to be kept, got %d frames", len(frames))
+	}
+	want := data.Labels{"instance": "", "check": "check", "monitor": "mon"}
+	if diff := cmp.Diff(want, frames[0].Fields[1].Labels); diff != "" {
+		t.Errorf("expected the missing instance to be left empty (-want +got):\n%s", diff)
+	}
+	if got := frames[0].Fields[1].At(0).(*float32); got == nil || *got != 10 {
+		t.Errorf("value = %v, want 10", got)
+	}
+	if got := frames[1].Fields[1].At(0).(*float32); got != nil {
+		t.Errorf("value without a sample = %v, want null", *got)
+	}
+}
+
+func TestBucketStart(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 02:00 UTC is still the previous evening in New York
+	timestamp := strToTime("2022-12-07T02:00:00Z")
+
+	utcBucket := bucketStart(timestamp, 24*time.Hour, time.UTC)
+	if !utcBucket.Equal(strToTime("2022-12-07T00:00:00Z")) {
+		t.Errorf("UTC daily bucket = %v, want midnight UTC on Dec 7", utcBucket)
+	}
+
+	newYorkBucket := bucketStart(timestamp, 24*time.Hour, newYork)
+	if !newYorkBucket.Equal(strToTime("2022-12-06T05:00:00Z")) {
+		t.Errorf("New York daily bucket = %v, want midnight in New York on Dec 6", newYorkBucket)
+	}
+
+	if got := bucketStart(timestamp.Add(90*time.Second), time.Minute, newYork); !got.Equal(timestamp.Add(time.Minute)) {
+		t.Errorf("minute bucket = %v, want it unaffected by the time zone", got)
+	}
+}
+
+func TestBucketLocation(t *testing.T) {
+	if loc, err := bucketLocation(monitorTelemetryQuery{}); err != nil || loc != time.UTC {
+		t.Errorf("expected UTC by default, got %v, %v", loc, err)
+	}
+	if loc, err := bucketLocation(monitorTelemetryQuery{Timezone: "Europe/Amsterdam"}); err != nil || loc.String() != "Europe/Amsterdam" {
+		t.Errorf("expected Europe/Amsterdam, got %v, %v", loc, err)
+	}
+	if _, err := bucketLocation(monitorTelemetryQuery{Timezone: "Mars/Olympus_Mons"}); err == nil {
+		t.Errorf("expected an error for an unknown time zone")
+	}
+}
+
+func TestQueryMonitorErrorsMinCount(t *testing.T) {
+	client := &stubClient{errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+		JSON200: &internal.MonitorErrorResponse{
+			Entries: &[]internal.MonitorErrorCount{
+				{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:28:06Z")},
+				{Check: ptr("check"), Count: ptr(2), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:29:06Z")},
+				{Check: ptr("check"), Count: ptr(5), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T18:30:06Z")},
+			},
+			Metadata: &internal.PagingMetadata{},
+		},
+	}}
+	tr := backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int64
+	}{
+		{name: "drops counts below the threshold", query: `{"monitors": ["mon"], "minCount": 2}`, want: []int64{2, 5}},
+		{name: "keeps every count without a threshold", query: `{"monitors": ["mon"]}`, want: []int64{1, 2, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := QueryMonitorErrors(context.Background(), backend.DataQuery{JSON: []byte(tt.query), TimeRange: tr}, client, log.DefaultLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Frames) != 2 {
+				t.Fatalf("expected a graph and a table frame, got %d frames", len(resp.Frames))
+			}
+			for _, frame := range resp.Frames {
+				counts := frame.Fields[1]
+				got := make([]int64, counts.Len())
+				for i := range got {
+					got[i] = counts.At(i).(int64)
+				}
+				if diff := cmp.Diff(tt.want, got); diff != "" {
+					t.Errorf("%s counts mismatch (-want +got):\n%s", frame.Meta.PreferredVisualization, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildErrorHeatmapFrameTimezone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errorCounts := []monitorErrorCount{
+		{MonitorErrorCount: internal.MonitorErrorCount{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-06T23:00:00Z")}},
+		{MonitorErrorCount: internal.MonitorErrorCount{Check: ptr("check"), Count: ptr(1), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("mon"), Timestamp: ptr("2022-12-07T02:00:00Z")}},
+	}
+
+	// Both errors fall on Dec 6 in New York, but on different days in UTC
+	if rows := buildErrorHeatmapFrame(errorCounts, 24*time.Hour, time.UTC, log.DefaultLogger).Rows(); rows != 2 {
+		t.Errorf("expected 2 daily buckets in UTC, got %d", rows)
+	}
+	if rows := buildErrorHeatmapFrame(errorCounts, 24*time.Hour, newYork, log.DefaultLogger).Rows(); rows != 1 {
+		t.Errorf("expected 1 daily bucket in New York, got %d", rows)
+	}
+}
+
+func TestExecutedQueryString(t *testing.T) {
+	includeShared := true
+	query := monitorTelemetryQuery{
+		Monitors:      []string{"mon_one", "mon_two"},
+		Checks:        &[]string{"Ping"},
+		Instances:     &[]string{"us-east-1", "us-west-1"},
+		IncludeShared: &includeShared,
+	}
+	tr := backend.TimeRange{From: strToTime("2022-12-07T00:00:00Z"), To: strToTime("2022-12-08T00:00:00Z")}
+
+	got := executedQueryString(query, tr, endpointMonitorTelemetry, endpointMonitorErrors)
+	for _, want := range []string{
+		"/api/v0/monitor-telemetry, /api/v0/monitor-error",
+		"monitors=mon_one,mon_two",
+		"checks=Ping",
+		"instances=us-east-1,us-west-1",
+		"from=2022-12-07T00:00:00Z",
+		"to=2022-12-08T00:00:00Z",
+		"includeShared=true",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q to contain %q", got, want)
+		}
+	}
+
+	if got := executedQueryString(monitorTelemetryQuery{Monitors: []string{"mon"}}, tr, endpointMonitorErrors); strings.Contains(got, "checks=") || strings.Contains(got, "instances=") {
+		t.Errorf("expected unset filters to be left out, got %q", got)
+	}
+}
+
+func TestQueryMonitorErrorsExecutedQueryString(t *testing.T) {
+	query := backend.DataQuery{
+		JSON:      []byte(`{"monitors": ["mon"], "checks": ["check"]}`),
+		TimeRange: backend.TimeRange{From: strToTime("2022-12-07T00:00:00Z"), To: strToTime("2022-12-08T00:00:00Z")},
+	}
+
+	resp, err := QueryMonitorErrors(context.Background(), query, &endlessErrorClient{pageSize: 1}, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "GET /api/v0/monitor-error monitors=mon checks=check from=2022-12-07T00:00:00Z to=2022-12-08T00:00:00Z includeShared=false"
+	for _, frame := range resp.Frames {
+		if frame.Meta.ExecutedQueryString != want {
+			t.Errorf("expected executed query string %q, got %q", want, frame.Meta.ExecutedQueryString)
+		}
+	}
+}
@@ -1,62 +1,201 @@
 package plugin
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"html"
 	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/exp/slices"
 
 	"github.com/Metrist-Software/metrist-grafana-datasource/pkg/internal"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/sync/errgroup"
 )
 
-// ResourceMonitorList returns a list of monitors which is can be used by a select box
-func ResourceMonitorList(ctx context.Context, client internal.ClientWithResponsesInterface) (backend.CallResourceResponse, error) {
-	resp, err := client.BackendWebMonitorListControllerGetWithResponse(ctx)
+// activeMonitorWindow is how recently a monitor must have been checked to be considered active
+const activeMonitorWindow = 24 * time.Hour
+
+// exportWarningHeader holds the notices of a partial or truncated CSV export, one value per notice
+const exportWarningHeader = "X-Metrist-Warning"
+
+// maxFanOutRequests caps the concurrent per monitor requests of a fanned out resource, so a long monitor list
+// doesn't exhaust the upstream rate limit
+const maxFanOutRequests = 4
+
+// resourceFormatString makes the list resources return a flat array of values, as template variables expect
+const resourceFormatString = "string"
+
+// optionsResponse encodes options as label/value pairs, or as a flat array of their values when format is
+// resourceFormatString
+func optionsResponse(options selectOptions, format string) (backend.CallResourceResponse, error) {
+	var optionsJson []byte
+	var err error
+	if format == resourceFormatString {
+		values := make([]string, len(options))
+		for i, option := range options {
+			values[i] = option.Value
+		}
+		optionsJson, err = json.Marshal(values)
+	} else {
+		optionsJson, err = json.Marshal(options)
+	}
 	if err != nil {
 		return backend.CallResourceResponse{}, err
 	}
 
+	return backend.CallResourceResponse{
+		Status: http.StatusOK,
+		Body:   optionsJson,
+	}, nil
+}
+
+// ResourceMonitorList returns a list of monitors which is can be used by a select box.
+// When onlyActive is set, monitors which Metrist has not checked within activeMonitorWindow are left out.
+// Monitors with an alias are labeled with it instead of their name
+func ResourceMonitorList(ctx context.Context, client internal.ClientWithResponsesInterface, onlyActive bool, aliases map[string]string, format string) (backend.CallResourceResponse, error) {
+	options, err := monitorOptions(ctx, client, onlyActive, aliases)
+	if err != nil {
+		return backend.CallResourceResponse{}, err
+	}
+
+	return optionsResponse(options, format)
+}
+
+// ResourceMonitorSearch returns the monitors whose name or logical name contains search, ignoring case,
+// so a select box can filter large monitor lists server side. An empty search returns every monitor
+func ResourceMonitorSearch(ctx context.Context, client internal.ClientWithResponsesInterface, search string, aliases map[string]string, format string) (backend.CallResourceResponse, error) {
+	options, err := monitorOptions(ctx, client, false, aliases)
+	if err != nil {
+		return backend.CallResourceResponse{}, err
+	}
+
+	search = strings.ToLower(search)
+	matches := make(selectOptions, 0)
+	for _, option := range options {
+		if strings.Contains(strings.ToLower(option.Label), search) || strings.Contains(strings.ToLower(option.Value), search) {
+			matches = append(matches, option)
+		}
+	}
+
+	return optionsResponse(matches, format)
+}
+
+// monitorOptions returns the monitors as select options. The monitor list endpoint takes no cursor and
+// returns every monitor of the account in a single response, so unlike the other endpoints there is no paging
+func monitorOptions(ctx context.Context, client internal.ClientWithResponsesInterface, onlyActive bool, aliases map[string]string) (selectOptions, error) {
+	resp, err := client.BackendWebMonitorListControllerGetWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// A partial list would silently hide monitors, so anything but a complete response is an error
+	if resp.JSON200 == nil {
+		return nil, fmt.Errorf("%w: monitor list status %s", errRemoteResponse, resp.Status())
+	}
+
+	var active map[string]bool
+	if onlyActive {
+		if active, err = activeMonitors(ctx, client); err != nil {
+			return nil, err
+		}
+	}
+
 	monitorList := *resp.JSON200
 	options := make(selectOptions, 0)
 
 	for _, monitor := range monitorList {
+		if onlyActive && !active[*monitor.LogicalName] {
+			continue
+		}
+		label := *monitor.Name
+		if alias, ok := aliases[*monitor.LogicalName]; ok {
+			label = alias
+		}
 		options = append(options, selectOption{
-			Label: *monitor.Name,
+			Label: label,
 			Value: *monitor.LogicalName,
 		})
 	}
+	return options, nil
+}
 
-	optionsJson, err := json.Marshal(options)
+// activeMonitors returns the logical names of the monitors which were checked within activeMonitorWindow
+func activeMonitors(ctx context.Context, client internal.ClientWithResponsesInterface) (map[string]bool, error) {
+	resp, err := client.BackendWebMonitorStatusControllerGetWithResponse(ctx, &internal.BackendWebMonitorStatusControllerGetParams{})
 	if err != nil {
-		return backend.CallResourceResponse{}, err
+		return nil, err
+	}
+	if resp.JSON200 == nil {
+		return nil, fmt.Errorf("%w: monitor status %s", errRemoteResponse, resp.Status())
 	}
 
-	return backend.CallResourceResponse{
-		Status: http.StatusOK,
-		Body:   optionsJson,
-	}, nil
+	active := make(map[string]bool)
+	cutoff := now().Add(-activeMonitorWindow)
+	for _, status := range *resp.JSON200 {
+		if status.MonitorLogicalName == nil || status.LastChecked == nil {
+			continue
+		}
+		lastChecked, err := time.Parse(time.RFC3339, *status.LastChecked)
+		if err != nil {
+			continue
+		}
+		if lastChecked.After(cutoff) {
+			active[*status.MonitorLogicalName] = true
+		}
+	}
+	return active, nil
 }
 
-func ResourceCheckList(ctx context.Context, client internal.ClientWithResponsesInterface, monitors []string, includeShared bool) (backend.CallResourceResponse, error) {
-	params := internal.BackendWebMonitorCheckControllerGetParams{M: monitors, IncludeShared: &includeShared}
-
-	resp, err := client.BackendWebMonitorCheckControllerGetWithResponse(ctx, &params)
+// ResourceCheckList returns the checks of monitors. With fanOut the checks of each monitor are requested
+// concurrently rather than in a single request
+func ResourceCheckList(ctx context.Context, client internal.ClientWithResponsesInterface, monitors []string, includeShared bool, fanOut bool, format string) (backend.CallResourceResponse, error) {
+	checkList, err := fetchPerMonitor(ctx, monitors, fanOut, func(ctx context.Context, monitors []string) (internal.MonitorChecksResponse, error) {
+		params := internal.BackendWebMonitorCheckControllerGetParams{M: monitors, IncludeShared: &includeShared}
+		resp, err := client.BackendWebMonitorCheckControllerGetWithResponse(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+		if resp.JSON200 == nil {
+			return nil, fmt.Errorf("%w: check list status %s", errRemoteResponse, resp.Status())
+		}
+		return *resp.JSON200, nil
+	})
 	if err != nil {
 		return backend.CallResourceResponse{}, err
 	}
 
-	checkList := *resp.JSON200
 	options := make(selectOptions, 0)
 
+	// Check values are the check logical names qualified with their monitor, e.g. "mon_one:check_one", which
+	// the telemetry and error queries scope to that monitor. A check shared by several monitors gets a single
+	// option whose label and value list every monitor it belongs to, e.g. "mon_one,mon_two:Check One", so that
+	// option values stay unique
+	optionIndex := make(map[string]int)
 	for _, item := range checkList {
 		for _, check := range *item.Checks {
+			if i, ok := optionIndex[*check.LogicalName]; ok {
+				monitors, name, _ := strings.Cut(options[i].Label, ":")
+				if !slices.Contains(strings.Split(monitors, ","), *item.MonitorLogicalName) {
+					options[i].Label = fmt.Sprintf("%s,%s:%s", monitors, *item.MonitorLogicalName, name)
+					options[i].Value = fmt.Sprintf("%s,%s:%s", monitors, *item.MonitorLogicalName, *check.LogicalName)
+				}
+				continue
+			}
+			optionIndex[*check.LogicalName] = len(options)
 			options = append(options, selectOption{
 				Label: fmt.Sprintf("%s:%s", *item.MonitorLogicalName, *check.Name),
-				Value: *check.LogicalName,
+				Value: fmt.Sprintf("%s:%s", *item.MonitorLogicalName, *check.LogicalName),
 			})
 		}
 	}
@@ -65,27 +204,27 @@ func ResourceCheckList(ctx context.Context, client internal.ClientWithResponsesI
 		return options[i].Label < options[j].Label
 	})
 
-	optionsJson, err := json.Marshal(options)
-	if err != nil {
-		return backend.CallResourceResponse{}, err
-	}
-
-	return backend.CallResourceResponse{
-		Status: http.StatusOK,
-		Body:   optionsJson,
-	}, nil
+	return optionsResponse(options, format)
 }
 
-func ResourceInstanceList(ctx context.Context, client internal.ClientWithResponsesInterface, monitors []string, includeShared bool) (backend.CallResourceResponse, error) {
-	params := internal.BackendWebMonitorInstanceControllerGetParams{M: monitors, IncludeShared: &includeShared}
-
-	resp, err := client.BackendWebMonitorInstanceControllerGetWithResponse(ctx, &params)
+// ResourceInstanceList returns the instances of monitors. With fanOut the instances of each monitor are
+// requested concurrently rather than in a single request
+func ResourceInstanceList(ctx context.Context, client internal.ClientWithResponsesInterface, monitors []string, includeShared bool, fanOut bool, format string) (backend.CallResourceResponse, error) {
+	instanceList, err := fetchPerMonitor(ctx, monitors, fanOut, func(ctx context.Context, monitors []string) (internal.MonitorInstancesResponse, error) {
+		params := internal.BackendWebMonitorInstanceControllerGetParams{M: monitors, IncludeShared: &includeShared}
+		resp, err := client.BackendWebMonitorInstanceControllerGetWithResponse(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+		if resp.JSON200 == nil {
+			return nil, fmt.Errorf("%w: instance list status %s", errRemoteResponse, resp.Status())
+		}
+		return *resp.JSON200, nil
+	})
 	if err != nil {
 		return backend.CallResourceResponse{}, err
 	}
 
-	instanceList := *resp.JSON200
-
 	all_instances := make([]string, 0)
 	for _, item := range instanceList {
 		all_instances = append(all_instances, *item.Instances...)
@@ -102,7 +241,318 @@ func ResourceInstanceList(ctx context.Context, client internal.ClientWithRespons
 		})
 	}
 
-	optionsJson, err := json.Marshal(options)
+	return optionsResponse(options, format)
+}
+
+// fetchPerMonitor calls fetch once for all monitors, or with fanOut concurrently once per monitor, at most
+// maxFanOutRequests at a time, in which case the results are concatenated in monitor order
+func fetchPerMonitor[T any](ctx context.Context, monitors []string, fanOut bool, fetch func(ctx context.Context, monitors []string) ([]T, error)) ([]T, error) {
+	if !fanOut || len(monitors) <= 1 {
+		return fetch(ctx, monitors)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxFanOutRequests)
+	results := make([][]T, len(monitors))
+	for i, monitor := range monitors {
+		i, monitor := i, monitor // https://golang.org/doc/faq#closures_and_goroutines
+		goRecovering(g, func() error {
+			var err error
+			results[i], err = fetch(ctx, []string{monitor})
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	merged := make([]T, 0)
+	for _, result := range results {
+		merged = append(merged, result...)
+	}
+	return merged, nil
+}
+
+// ResourceExportCsv runs a telemetry or errors query described by the query string parameters and returns the
+// rows of its table frame as a CSV download. The parameters are type (telemetry or errors), from and to as
+// RFC3339 timestamps, and the optional monitors, checks, instances and includeShared filters. Errors which were
+// only partially fetched or truncated are reported in the exportWarningHeader
+func ResourceExportCsv(ctx context.Context, client internal.ClientWithResponsesInterface, timeRangeLimit time.Duration, values url.Values, logger log.Logger) (backend.CallResourceResponse, error) {
+	tr, err := parseExportTimeRange(values, timeRangeLimit)
+	if err != nil {
+		return badRequest(err.Error()), nil
+	}
+
+	includeShared := values.Get("includeShared") == "true"
+	checks, instances := values["checks"], values["instances"]
+	query := monitorTelemetryQuery{
+		Monitors:      values["monitors"],
+		Checks:        nilIfEmpty(&checks),
+		Instances:     nilIfEmpty(&instances),
+		IncludeShared: &includeShared,
+	}
+
+	var frameData []internal.FrameData
+	var definition data.Frame
+	var notices []data.Notice
+	switch exportType := values.Get("type"); exportType {
+	case "", "telemetry":
+		if err := ensureTelemetryRequestWithinLast90Days(tr.From); err != nil {
+			return badRequest(err.Error()), nil
+		}
+		telemetry, err := fetchMonitorTelemetry(ctx, client, query, tr, logger)
+		if err != nil {
+			return backend.CallResourceResponse{}, err
+		}
+		for i := range telemetry {
+			frameData = append(frameData, &telemetry[i])
+		}
+		definition = (&internal.MonitorTelemetry{}).GetTableFrameDefinition()
+	case "errors":
+		errorCounts, errorNotices, err := fetchAllMonitorErrors(ctx, client, query, tr, logger)
+		if err != nil {
+			return backend.CallResourceResponse{}, err
+		}
+		notices = errorNotices
+		for i := range errorCounts {
+			frameData = append(frameData, &errorCounts[i].MonitorErrorCount)
+		}
+		definition = (&internal.MonitorErrorCount{}).GetTableFrameDefinition()
+	default:
+		return badRequest(fmt.Sprintf("unsupported export type %q", exportType)), nil
+	}
+
+	frame := &definition
+	if frames := buildFrames(frameData, TableFrameType, nil, frameOptions{}, logger); len(frames) > 0 {
+		frame = frames[0]
+	}
+
+	body, err := frameToCsv(frame)
+	if err != nil {
+		return backend.CallResourceResponse{}, err
+	}
+
+	headers := map[string][]string{
+		"Content-Type":        {"text/csv"},
+		"Content-Disposition": {`attachment; filename="metrist-export.csv"`},
+	}
+	// A CSV has no room for notices, so partial or truncated exports are flagged in a header instead
+	for _, notice := range notices {
+		headers[exportWarningHeader] = append(headers[exportWarningHeader], notice.Text)
+	}
+	return backend.CallResourceResponse{
+		Status:  http.StatusOK,
+		Headers: headers,
+		Body:    body,
+	}, nil
+}
+
+// ResourceRawQuery makes a single upstream request described by the query string parameters and returns its
+// body unmodified, so what the API returned can be compared with the frames. The parameters are type (telemetry,
+// errors or statuspage), from and to as RFC3339 timestamps, the optional monitors, checks and instances filters,
+// includeShared for telemetry, onlyShared for errors and a cursor to fetch the following page of errors or
+// status page changes
+func ResourceRawQuery(ctx context.Context, client internal.ClientWithResponsesInterface, timeRangeLimit time.Duration, values url.Values) (backend.CallResourceResponse, error) {
+	tr, err := parseExportTimeRange(values, timeRangeLimit)
+	if err != nil {
+		return badRequest(err.Error()), nil
+	}
+
+	includeShared := values.Get("includeShared") == "true"
+	onlyShared := values.Get("onlyShared") == "true"
+	checks, instances := values["checks"], values["instances"]
+	var cursor *string
+	if values.Has("cursor") {
+		value := values.Get("cursor")
+		cursor = &value
+	}
+
+	var status int
+	var body []byte
+	switch queryType := values.Get("type"); queryType {
+	case "telemetry":
+		resp, err := client.BackendWebMonitorTelemetryControllerGetWithResponse(ctx, &internal.BackendWebMonitorTelemetryControllerGetParams{
+			From:          tr.From,
+			To:            tr.To,
+			M:             values["monitors"],
+			IncludeShared: &includeShared,
+			C:             nilIfEmpty(&checks),
+			I:             nilIfEmpty(&instances),
+		})
+		if err != nil {
+			return backend.CallResourceResponse{}, err
+		}
+		status, body = resp.StatusCode(), resp.Body
+	case "errors":
+		resp, err := client.BackendWebMonitorErrorControllerGetWithResponse(ctx, &internal.BackendWebMonitorErrorControllerGetParams{
+			From:        tr.From,
+			To:          tr.To,
+			M:           values["monitors"],
+			OnlyShared:  &onlyShared,
+			C:           nilIfEmpty(&checks),
+			I:           nilIfEmpty(&instances),
+			CursorAfter: cursor,
+		})
+		if err != nil {
+			return backend.CallResourceResponse{}, err
+		}
+		status, body = resp.StatusCode(), resp.Body
+	case "statuspage":
+		resp, err := client.BackendWebStatusPageChangeControllerGetWithResponse(ctx, &internal.BackendWebStatusPageChangeControllerGetParams{
+			From:        tr.From,
+			To:          &tr.To,
+			M:           values["monitors"],
+			CursorAfter: cursor,
+		})
+		if err != nil {
+			return backend.CallResourceResponse{}, err
+		}
+		status, body = resp.StatusCode(), resp.Body
+	default:
+		return badRequest(fmt.Sprintf("unsupported query type %q", queryType)), nil
+	}
+
+	return backend.CallResourceResponse{
+		Status:  status,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	}, nil
+}
+
+// statusBadgeTemplate is a flat shields.io style badge. Its arguments are the total width, the label width,
+// the message width, the color, the label and message centers and the label and message
+const statusBadgeTemplate = `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[7]s: %[8]s">` +
+	`<title>%[7]s: %[8]s</title>` +
+	`<rect width="%[2]d" height="20" fill="#555"/>` +
+	`<rect x="%[2]d" width="%[3]d" height="20" fill="%[4]s"/>` +
+	`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">` +
+	`<text x="%[5]d" y="14">%[7]s</text>` +
+	`<text x="%[6]d" y="14">%[8]s</text>` +
+	`</g></svg>`
+
+// ResourceStatusBadge renders an SVG badge with the latest status page status of a monitor, so it can be
+// embedded in wikis. The parameters are monitor and the optional component. Without a component the badge
+// shows the most severe latest status across the components of the monitor. The badge is colored from palette
+func ResourceStatusBadge(ctx context.Context, client internal.ClientWithResponsesInterface, palette map[string]string, values url.Values, logger log.Logger) (backend.CallResourceResponse, error) {
+	monitor := values.Get("monitor")
+	if monitor == "" {
+		return badRequest("monitor is required"), nil
+	}
+	component := values.Get("component")
+
+	// Status pages only record changes, so look as far back as a query may to find the latest one
+	to := now()
+	tr := backend.TimeRange{From: to.Add(-durationThreeMonths), To: to}
+	changes, _, err := fetchAllStatusPageMonitor(ctx, client, monitorTelemetryQuery{Monitors: []string{monitor}, Component: component}, tr, logger)
+	if err != nil {
+		return backend.CallResourceResponse{}, err
+	}
+
+	coercedChanges := make([]internal.FrameData, len(changes))
+	for i := range changes {
+		coercedChanges[i] = &changes[i]
+	}
+	state := statusPageStates[0]
+	for _, change := range latestOnly(coercedChanges, logger) {
+		value := change.GetGraphVals(time.Time{})[1].(int8)
+		for _, candidate := range statusPageStates {
+			if candidate.value == value && candidate.severity > state.severity {
+				state = candidate
+			}
+		}
+	}
+
+	label := monitor
+	if component != "" {
+		label = component
+	}
+	return backend.CallResourceResponse{
+		Status: http.StatusOK,
+		Headers: map[string][]string{
+			"Content-Type":  {"image/svg+xml"},
+			"Cache-Control": {"no-cache"},
+		},
+		Body: statusBadge(label, state.text, paletteColor(palette, state.color)),
+	}, nil
+}
+
+// statusBadge renders statusBadgeTemplate, sizing each half of the badge by an estimate of its text width
+func statusBadge(label string, message string, color string) []byte {
+	textWidth := func(text string) int {
+		return 7*len([]rune(text)) + 10
+	}
+	labelWidth, messageWidth := textWidth(label), textWidth(message)
+	return []byte(fmt.Sprintf(statusBadgeTemplate,
+		labelWidth+messageWidth, labelWidth, messageWidth, html.EscapeString(color),
+		labelWidth/2, labelWidth+messageWidth/2,
+		html.EscapeString(label), html.EscapeString(message)))
+}
+
+func parseExportTimeRange(values url.Values, limit time.Duration) (backend.TimeRange, error) {
+	from, err := time.Parse(time.RFC3339, values.Get("from"))
+	if err != nil {
+		return backend.TimeRange{}, fmt.Errorf("invalid from: %w", err)
+	}
+	to, err := time.Parse(time.RFC3339, values.Get("to"))
+	if err != nil {
+		return backend.TimeRange{}, fmt.Errorf("invalid to: %w", err)
+	}
+
+	tr := backend.TimeRange{From: from, To: to}
+	if err := ensureTimeRangeWithinLimits(tr.Duration(), limit); err != nil {
+		return backend.TimeRange{}, err
+	}
+	return tr, nil
+}
+
+// frameToCsv writes a header with the field names followed by one record per frame row
+func frameToCsv(frame *data.Frame) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := make([]string, len(frame.Fields))
+	for i, field := range frame.Fields {
+		header[i] = field.Name
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	for row := 0; row < frame.Rows(); row++ {
+		record := make([]string, len(frame.Fields))
+		for i, field := range frame.Fields {
+			// Null values are left empty
+			value, ok := field.ConcreteAt(row)
+			if !ok {
+				continue
+			}
+			switch value := value.(type) {
+			case time.Time:
+				record[i] = value.Format(time.RFC3339)
+			default:
+				record[i] = fmt.Sprint(value)
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func badRequest(message string) backend.CallResourceResponse {
+	body, _ := json.Marshal(map[string]string{"message": message})
+	return backend.CallResourceResponse{
+		Status: http.StatusBadRequest,
+		Body:   body,
+	}
+}
+
+// ResourceQueryTypes returns the query types supported by the backend
+func ResourceQueryTypes() (backend.CallResourceResponse, error) {
+	optionsJson, err := json.Marshal(queryTypes)
 	if err != nil {
 		return backend.CallResourceResponse{}, err
 	}
@@ -112,3 +562,101 @@ func ResourceInstanceList(ctx context.Context, client internal.ClientWithRespons
 		Body:   optionsJson,
 	}, nil
 }
+
+// ResourceStatusMappings returns how upstream status page statuses are interpreted: the numeric value each
+// status maps to, and what each value is displayed as and colored with. Statuses which are not listed map to 0
+func ResourceStatusMappings(palette map[string]string) (backend.CallResourceResponse, error) {
+	values := make(map[string]string, len(statusPageStates))
+	colors := make(map[string]string, len(statusPageStates))
+	for _, state := range statusPageStates {
+		values[strconv.Itoa(int(state.value))] = state.text
+		colors[strconv.Itoa(int(state.value))] = paletteColor(palette, state.color)
+	}
+
+	mappingsJson, err := json.Marshal(struct {
+		Statuses map[string]int8   `json:"statuses"`
+		Values   map[string]string `json:"values"`
+		Colors   map[string]string `json:"colors"`
+	}{Statuses: internal.StatusPageStatuses(), Values: values, Colors: colors})
+	if err != nil {
+		return backend.CallResourceResponse{}, err
+	}
+
+	return backend.CallResourceResponse{
+		Status: http.StatusOK,
+		Body:   mappingsJson,
+	}, nil
+}
+
+// ResourceHealth verifies the API key against Metrist so external tooling can probe the datasource
+func ResourceHealth(ctx context.Context, client internal.ClientWithResponsesInterface) (backend.CallResourceResponse, error) {
+	resp, err := client.BackendWebVerifyAuthControllerGetWithResponse(ctx)
+	if err != nil {
+		return backend.CallResourceResponse{}, err
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusOK:
+		return backend.CallResourceResponse{
+			Status: http.StatusOK,
+			Body:   []byte(`{"status":"ok"}`),
+		}, nil
+	case http.StatusUnauthorized:
+		return backend.CallResourceResponse{
+			Status: http.StatusUnauthorized,
+			Body:   []byte(`{"status":"unauthorized"}`),
+		}, nil
+	default:
+		return backend.CallResourceResponse{
+			Status: http.StatusBadGateway,
+			Body:   []byte(`{"status":"error"}`),
+		}, nil
+	}
+}
+
+const monitorNameCacheTTL = 5 * time.Minute
+
+// monitorNameCache caches the mapping of monitor logical names to display names of each environment
+type monitorNameCache struct {
+	mu      sync.Mutex
+	entries map[string]monitorNames
+}
+
+// monitorNames are the display names of the monitors of an environment
+type monitorNames struct {
+	names     map[string]string
+	fetchedAt time.Time
+}
+
+// get returns the cached monitor names of environment, refreshing them from the monitor list of client once
+// they expire. client has to be the client of environment
+func (c *monitorNameCache) get(ctx context.Context, environment string, client internal.ClientWithResponsesInterface) (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[environment]; ok && time.Since(entry.fetchedAt) < monitorNameCacheTTL {
+		return entry.names, nil
+	}
+
+	resp, err := client.BackendWebMonitorListControllerGetWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.JSON200 == nil {
+		return nil, fmt.Errorf("%w: monitor list status %s", errRemoteResponse, resp.Status())
+	}
+
+	names := make(map[string]string)
+	for _, monitor := range *resp.JSON200 {
+		if monitor.LogicalName == nil || monitor.Name == nil {
+			continue
+		}
+		names[*monitor.LogicalName] = *monitor.Name
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[string]monitorNames)
+	}
+	c.entries[environment] = monitorNames{names: names, fetchedAt: time.Now()}
+	return names, nil
+}
@@ -2,12 +2,20 @@ package plugin
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/Metrist-Software/metrist-grafana-datasource/pkg/internal"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 )
 
 type testArgsWithClientWithResponse struct {
@@ -49,10 +57,20 @@ func TestResourceMonitorList(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "returns an error for a non 200 response",
+			args: testArgsWithClientWithResponse{
+				client: &stubClient{monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+					HTTPResponse: &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"},
+				}},
+			},
+			want:    backend.CallResourceResponse{},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ResourceMonitorList(context.Background(), tt.args.client)
+			got, err := ResourceMonitorList(context.Background(), tt.args.client, false, nil, "")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ResourceMonitorList() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -64,6 +82,68 @@ func TestResourceMonitorList(t *testing.T) {
 	}
 }
 
+func TestResourceMonitorListAliases(t *testing.T) {
+	client := &stubClient{monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+		JSON200: &internal.MonitorListResponse{{LogicalName: ptr("awslambda"), Name: ptr("AWS Lambda")}, {LogicalName: ptr("github"), Name: ptr("GitHub")}},
+	}}
+
+	got, err := ResourceMonitorList(context.Background(), client, false, map[string]string{"awslambda": "Lambda"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"label":"Lambda","value":"awslambda"},{"label":"GitHub","value":"github"}]`; string(got.Body) != want {
+		t.Errorf("ResourceMonitorList() = %s, want %s", got.Body, want)
+	}
+}
+
+func TestResourceMonitorListOnlyActive(t *testing.T) {
+	recent := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	stale := time.Now().Add(-7 * 24 * time.Hour).Format(time.RFC3339)
+	client := &stubClient{
+		monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+			JSON200: &internal.MonitorListResponse{
+				{LogicalName: ptr("awslambda"), Name: ptr("AWS Lambda")},
+				{LogicalName: ptr("azuread"), Name: ptr("Azure AD")},
+				{LogicalName: ptr("gcal"), Name: ptr("Google Calendar")},
+			},
+		},
+		statusResponse: internal.BackendWebMonitorStatusControllerGetResponse{
+			JSON200: &internal.MonitorStatusesResponse{
+				{MonitorLogicalName: ptr("awslambda"), LastChecked: ptr(recent)},
+				{MonitorLogicalName: ptr("azuread"), LastChecked: ptr(stale)},
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		onlyActive bool
+		want       string
+	}{
+		{
+			name:       "returns all monitors by default",
+			onlyActive: false,
+			want:       `[{"label":"AWS Lambda","value":"awslambda"},{"label":"Azure AD","value":"azuread"},{"label":"Google Calendar","value":"gcal"}]`,
+		},
+		{
+			name:       "filters out monitors not checked recently",
+			onlyActive: true,
+			want:       `[{"label":"AWS Lambda","value":"awslambda"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResourceMonitorList(context.Background(), client, tt.onlyActive, nil, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got.Body) != tt.want {
+				t.Errorf("ResourceMonitorList() = %s, want %s", got.Body, tt.want)
+			}
+		})
+	}
+}
+
 func TestResourceChecksList(t *testing.T) {
 	tests := []testWithCallResourceResponse{
 		{
@@ -94,7 +174,43 @@ func TestResourceChecksList(t *testing.T) {
 			},
 			want: backend.CallResourceResponse{
 				Status: http.StatusOK,
-				Body:   []byte(`[{"label":"mon_one:Check One","value":"check1"},{"label":"mon_two:Check Three","value":"check3"}]`),
+				Body:   []byte(`[{"label":"mon_one:Check One","value":"mon_one:check1"},{"label":"mon_two:Check Three","value":"mon_two:check3"}]`),
+			},
+			wantErr: false,
+		},
+		{
+			name: "merges checks shared by several monitors into a single option",
+			args: testArgsWithClientWithResponse{
+				client: &stubClient{checksResponse: internal.BackendWebMonitorCheckControllerGetResponse{
+					JSON200: &internal.MonitorChecksResponse{
+						{
+							Checks: &[]internal.MonitorCheck{
+								{
+									LogicalName: ptr("ping"),
+									Name:        ptr("Ping"),
+								},
+							},
+							MonitorLogicalName: ptr("mon_one"),
+						},
+						{
+							Checks: &[]internal.MonitorCheck{
+								{
+									LogicalName: ptr("ping"),
+									Name:        ptr("Ping"),
+								},
+								{
+									LogicalName: ptr("check3"),
+									Name:        ptr("Check Three"),
+								},
+							},
+							MonitorLogicalName: ptr("mon_two"),
+						},
+					},
+				}},
+			},
+			want: backend.CallResourceResponse{
+				Status: http.StatusOK,
+				Body:   []byte(`[{"label":"mon_one,mon_two:Ping","value":"mon_one,mon_two:ping"},{"label":"mon_two:Check Three","value":"mon_two:check3"}]`),
 			},
 			wantErr: false,
 		},
@@ -114,7 +230,7 @@ func TestResourceChecksList(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ResourceCheckList(context.Background(), tt.args.client, []string{"testsignal"}, true)
+			got, err := ResourceCheckList(context.Background(), tt.args.client, []string{"testsignal"}, true, false, "")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ResourceChecks() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -171,7 +287,7 @@ func TestInstancesList(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ResourceInstanceList(context.Background(), tt.args.client, []string{"testsignal"}, true)
+			got, err := ResourceInstanceList(context.Background(), tt.args.client, []string{"testsignal"}, true, false, "")
 			println(string(got.Body))
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ResourceInstances() error = %v, wantErr %v", err, tt.wantErr)
@@ -183,3 +299,561 @@ func TestInstancesList(t *testing.T) {
 		})
 	}
 }
+
+// perMonitorClient answers the check and instance lists for a single monitor at a time, like an upstream
+// requiring per monitor requests
+type perMonitorClient struct {
+	stubClient
+	mu        sync.Mutex
+	requested [][]string
+}
+
+func (c *perMonitorClient) record(monitors []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requested = append(c.requested, monitors)
+	if len(monitors) != 1 {
+		return fmt.Errorf("expected a single monitor, got %v", monitors)
+	}
+	return nil
+}
+
+func (c *perMonitorClient) BackendWebMonitorCheckControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorCheckControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorCheckControllerGetResponse, error) {
+	if err := c.record(params.M); err != nil {
+		return nil, err
+	}
+	checks := map[string][]internal.MonitorCheck{
+		"mon_one": {{LogicalName: ptr("ping"), Name: ptr("Ping")}},
+		"mon_two": {{LogicalName: ptr("ping"), Name: ptr("Ping")}, {LogicalName: ptr("check3"), Name: ptr("Check Three")}},
+	}[params.M[0]]
+	return &internal.BackendWebMonitorCheckControllerGetResponse{
+		JSON200: &internal.MonitorChecksResponse{{Checks: &checks, MonitorLogicalName: ptr(params.M[0])}},
+	}, nil
+}
+
+func (c *perMonitorClient) BackendWebMonitorInstanceControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorInstanceControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorInstanceControllerGetResponse, error) {
+	if err := c.record(params.M); err != nil {
+		return nil, err
+	}
+	instances := map[string][]string{
+		"mon_one": {"us-east-1"},
+		"mon_two": {"us-east-1", "eu-west-1"},
+	}[params.M[0]]
+	return &internal.BackendWebMonitorInstanceControllerGetResponse{
+		JSON200: &internal.MonitorInstancesResponse{{Instances: &instances, MonitorLogicalName: ptr(params.M[0])}},
+	}, nil
+}
+
+func TestResourceListsFanOut(t *testing.T) {
+	monitors := []string{"mon_one", "mon_two"}
+	tests := []struct {
+		name     string
+		resource func(client internal.ClientWithResponsesInterface) (backend.CallResourceResponse, error)
+		wantBody string
+	}{
+		{
+			name: "checks",
+			resource: func(client internal.ClientWithResponsesInterface) (backend.CallResourceResponse, error) {
+				return ResourceCheckList(context.Background(), client, monitors, false, true, "")
+			},
+			wantBody: `[{"label":"mon_one,mon_two:Ping","value":"mon_one,mon_two:ping"},{"label":"mon_two:Check Three","value":"mon_two:check3"}]`,
+		},
+		{
+			name: "instances",
+			resource: func(client internal.ClientWithResponsesInterface) (backend.CallResourceResponse, error) {
+				return ResourceInstanceList(context.Background(), client, monitors, false, true, "")
+			},
+			wantBody: `[{"label":"eu-west-1","value":"eu-west-1"},{"label":"us-east-1","value":"us-east-1"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &perMonitorClient{}
+			got, err := tt.resource(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got.Body) != tt.wantBody {
+				t.Errorf("body = %s, want %s", got.Body, tt.wantBody)
+			}
+			if len(client.requested) != len(monitors) {
+				t.Errorf("made %d requests, want one per monitor", len(client.requested))
+			}
+		})
+	}
+}
+
+func TestFetchPerMonitorLimit(t *testing.T) {
+	monitors := make([]string, 3*maxFanOutRequests)
+	for i := range monitors {
+		monitors[i] = fmt.Sprintf("mon_%d", i)
+	}
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	got, err := fetchPerMonitor(context.Background(), monitors, true, func(ctx context.Context, monitors []string) ([]string, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return monitors, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, monitors) {
+		t.Errorf("results = %v, want them in monitor order", got)
+	}
+	if maxRunning > maxFanOutRequests {
+		t.Errorf("ran %d requests at once, want at most %d", maxRunning, maxFanOutRequests)
+	}
+}
+
+func TestResourceStringFormat(t *testing.T) {
+	client := &stubClient{
+		monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+			JSON200: &internal.MonitorListResponse{
+				{LogicalName: ptr("awslambda"), Name: ptr("AWS Lambda")},
+				{LogicalName: ptr("gcal"), Name: ptr("Google Calendar")},
+			},
+		},
+		checksResponse: internal.BackendWebMonitorCheckControllerGetResponse{
+			JSON200: &internal.MonitorChecksResponse{
+				{Checks: &[]internal.MonitorCheck{{LogicalName: ptr("ping"), Name: ptr("Ping")}}, MonitorLogicalName: ptr("awslambda")},
+			},
+		},
+		instancesResponse: internal.BackendWebMonitorInstanceControllerGetResponse{
+			JSON200: &internal.MonitorInstancesResponse{
+				{Instances: &[]string{"us-west-1", "us-east-1"}, MonitorLogicalName: ptr("awslambda")},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		resource func() (backend.CallResourceResponse, error)
+		want     string
+	}{
+		{
+			name: "monitors",
+			resource: func() (backend.CallResourceResponse, error) {
+				return ResourceMonitorList(context.Background(), client, false, nil, "string")
+			},
+			want: `["awslambda","gcal"]`,
+		},
+		{
+			name: "monitor search",
+			resource: func() (backend.CallResourceResponse, error) {
+				return ResourceMonitorSearch(context.Background(), client, "lambda", nil, "string")
+			},
+			want: `["awslambda"]`,
+		},
+		{
+			name: "checks",
+			resource: func() (backend.CallResourceResponse, error) {
+				return ResourceCheckList(context.Background(), client, []string{"awslambda"}, false, false, "string")
+			},
+			want: `["awslambda:ping"]`,
+		},
+		{
+			name: "instances",
+			resource: func() (backend.CallResourceResponse, error) {
+				return ResourceInstanceList(context.Background(), client, []string{"awslambda"}, false, false, "string")
+			},
+			want: `["us-east-1","us-west-1"]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.resource()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != http.StatusOK {
+				t.Errorf("status = %d, want %d", got.Status, http.StatusOK)
+			}
+			if string(got.Body) != tt.want {
+				t.Errorf("body = %s, want %s", got.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceHealth(t *testing.T) {
+	tests := []testWithCallResourceResponse{
+		{
+			name: "reports ok when the api key is valid",
+			args: testArgsWithClientWithResponse{
+				client: &stubClient{verifyAuthResponse: internal.BackendWebVerifyAuthControllerGetResponse{
+					HTTPResponse: &http.Response{StatusCode: http.StatusOK},
+				}},
+			},
+			want: backend.CallResourceResponse{
+				Status: http.StatusOK,
+				Body:   []byte(`{"status":"ok"}`),
+			},
+			wantErr: false,
+		},
+		{
+			name: "reports unauthorized when the api key is invalid",
+			args: testArgsWithClientWithResponse{
+				client: &stubClient{verifyAuthResponse: internal.BackendWebVerifyAuthControllerGetResponse{
+					HTTPResponse: &http.Response{StatusCode: http.StatusUnauthorized},
+				}},
+			},
+			want: backend.CallResourceResponse{
+				Status: http.StatusUnauthorized,
+				Body:   []byte(`{"status":"unauthorized"}`),
+			},
+			wantErr: false,
+		},
+		{
+			name: "returns an error when the api is unreachable",
+			args: testArgsWithClientWithResponse{
+				client: &stubClient{err: errors.New("connection refused")},
+			},
+			want:    backend.CallResourceResponse{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResourceHealth(context.Background(), tt.args.client)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ResourceHealth() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResourceHealth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceQueryTypes(t *testing.T) {
+	got, err := ResourceQueryTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var options selectOptions
+	if err := json.Unmarshal(got.Body, &options); err != nil {
+		t.Fatal(err)
+	}
+
+	values := make([]string, len(options))
+	for i, option := range options {
+		values[i] = option.Value
+	}
+	want := []string{"GetMonitorErrors", "GetMonitorTelemetry", "GetMonitorStatusPageChanges", "GetTopErrors", "GetMonitorOverview", "GetSloBurnRate", "GetErrorRatio", "GetInstanceCount", "GetLastSeen", "GetStatusPageAvailability", "GetTelemetryHistogram", "GetErroringCheckCount", "GetInstanceAvailability"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("ResourceQueryTypes() = %v, want %v", values, want)
+	}
+}
+
+func TestResourceStatusMappings(t *testing.T) {
+	got, err := ResourceStatusMappings(map[string]string{statusColorUp: "#1f77b4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", got.Status, http.StatusOK)
+	}
+
+	var mappings struct {
+		Statuses map[string]int8   `json:"statuses"`
+		Values   map[string]string `json:"values"`
+		Colors   map[string]string `json:"colors"`
+	}
+	if err := json.Unmarshal(got.Body, &mappings); err != nil {
+		t.Fatal(err)
+	}
+
+	for status, want := range map[string]int8{"under_maintenance": 1, "operational": 2, "degraded": 3, "major_outage": 4} {
+		if value, ok := mappings.Statuses[status]; !ok || value != want {
+			t.Errorf("status %q maps to %d (listed %v), want %d", status, value, ok, want)
+		}
+	}
+	if len(mappings.Statuses) != len(internal.StatusPageStatuses()) {
+		t.Errorf("expected every default status, got %d of %d", len(mappings.Statuses), len(internal.StatusPageStatuses()))
+	}
+	wantValues := map[string]string{"0": "unknown", "1": "maintenance", "2": "up", "3": "degraded", "4": "error"}
+	if !reflect.DeepEqual(mappings.Values, wantValues) {
+		t.Errorf("values = %v, want %v", mappings.Values, wantValues)
+	}
+	wantColors := map[string]string{"0": statusColorUnknown, "1": statusColorMaintenance, "2": "#1f77b4", "3": statusColorDegraded, "4": statusColorError}
+	if !reflect.DeepEqual(mappings.Colors, wantColors) {
+		t.Errorf("colors = %v, want %v", mappings.Colors, wantColors)
+	}
+}
+
+func TestResourceExportCsv(t *testing.T) {
+	from := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	to := time.Now().UTC().Truncate(time.Second)
+	client := &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			JSON200: &internal.MonitorTelemetryResponse{
+				{Check: ptr("Check"), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z"), Value: ptr[float32](100.5)},
+			},
+		},
+		errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+			JSON200: &internal.MonitorErrorResponse{
+				Entries:  &[]internal.MonitorErrorCount{{Check: ptr("Check"), Count: ptr(2), Instance: ptr("us-east-1"), MonitorLogicalName: ptr("awslambda"), Timestamp: ptr("2022-12-07T18:28:06Z")}},
+				Metadata: &internal.PagingMetadata{},
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		values     url.Values
+		wantStatus int
+		want       string
+	}{
+		{
+			name:       "exports telemetry",
+			values:     url.Values{"type": {"telemetry"}, "monitors": {"awslambda"}, "from": {from.Format(time.RFC3339)}, "to": {to.Format(time.RFC3339)}},
+			wantStatus: http.StatusOK,
+			want:       "time,response time (ms),instance,check,monitor\n2022-12-07T18:28:06Z,100.5,us-east-1,Check,awslambda\n",
+		},
+		{
+			name:       "exports errors",
+			values:     url.Values{"type": {"errors"}, "monitors": {"awslambda"}, "from": {from.Format(time.RFC3339)}, "to": {to.Format(time.RFC3339)}},
+			wantStatus: http.StatusOK,
+			want:       "time,count,instance,check,monitor,error_message\n2022-12-07T18:28:06Z,2,us-east-1,Check,awslambda,Check failed\n",
+		},
+		{
+			name:       "rejects a missing time range",
+			values:     url.Values{"type": {"telemetry"}, "monitors": {"awslambda"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "rejects an unknown type",
+			values:     url.Values{"type": {"statuses"}, "from": {from.Format(time.RFC3339)}, "to": {to.Format(time.RFC3339)}},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResourceExportCsv(context.Background(), client, durationThreeMonths, tt.values, log.DefaultLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", got.Status, tt.wantStatus)
+			}
+			if tt.want == "" {
+				return
+			}
+			if string(got.Body) != tt.want {
+				t.Errorf("body = %q, want %q", got.Body, tt.want)
+			}
+			if contentType := got.Headers["Content-Type"]; len(contentType) != 1 || contentType[0] != "text/csv" {
+				t.Errorf("Content-Type = %v, want text/csv", contentType)
+			}
+		})
+	}
+}
+
+func TestResourceExportCsvWarning(t *testing.T) {
+	values := url.Values{
+		"type":          {"errors"},
+		"monitors":      {"mon"},
+		"includeShared": {"true"},
+		"from":          {time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)},
+		"to":            {time.Now().UTC().Format(time.RFC3339)},
+	}
+
+	got, err := ResourceExportCsv(context.Background(), &failingScopeErrorClient{failShared: true}, durationThreeMonths, values, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", got.Status, http.StatusOK)
+	}
+	if warnings := got.Headers[exportWarningHeader]; len(warnings) != 1 || !strings.Contains(warnings[0], "shared") {
+		t.Errorf("%s = %v, want the partial results warning", exportWarningHeader, warnings)
+	}
+
+	got, err = ResourceExportCsv(context.Background(), &failingScopeErrorClient{}, durationThreeMonths, values, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warnings, ok := got.Headers[exportWarningHeader]; ok {
+		t.Errorf("expected no warning for a complete export, got %v", warnings)
+	}
+}
+
+func TestResourceRawQuery(t *testing.T) {
+	from := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	to := time.Now().UTC().Truncate(time.Second)
+	// The raw body is passed through even when it does not match what the client decoded
+	rawBody := `[{"check":"Check","unexpected":true}]`
+	client := &stubClient{
+		telemetryResponse: internal.BackendWebMonitorTelemetryControllerGetResponse{
+			Body:         []byte(rawBody),
+			HTTPResponse: &http.Response{StatusCode: http.StatusOK},
+			JSON200:      &internal.MonitorTelemetryResponse{},
+		},
+		errorResponse: internal.BackendWebMonitorErrorControllerGetResponse{
+			Body:         []byte(`{"message":"bad gateway"}`),
+			HTTPResponse: &http.Response{StatusCode: http.StatusBadGateway},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		values     url.Values
+		wantStatus int
+		want       string
+	}{
+		{
+			name:       "passes the telemetry body through",
+			values:     url.Values{"type": {"telemetry"}, "monitors": {"awslambda"}, "from": {from.Format(time.RFC3339)}, "to": {to.Format(time.RFC3339)}},
+			wantStatus: http.StatusOK,
+			want:       rawBody,
+		},
+		{
+			name:       "passes the upstream status through",
+			values:     url.Values{"type": {"errors"}, "monitors": {"awslambda"}, "from": {from.Format(time.RFC3339)}, "to": {to.Format(time.RFC3339)}},
+			wantStatus: http.StatusBadGateway,
+			want:       `{"message":"bad gateway"}`,
+		},
+		{
+			name:       "rejects an unknown type",
+			values:     url.Values{"type": {"statuses"}, "from": {from.Format(time.RFC3339)}, "to": {to.Format(time.RFC3339)}},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResourceRawQuery(context.Background(), client, durationThreeMonths, tt.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", got.Status, tt.wantStatus)
+			}
+			if tt.want != "" && string(got.Body) != tt.want {
+				t.Errorf("body = %q, want %q", got.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceMonitorSearch(t *testing.T) {
+	client := &stubClient{monitorListResponse: internal.BackendWebMonitorListControllerGetResponse{
+		JSON200: &internal.MonitorListResponse{
+			{LogicalName: ptr("awslambda"), Name: ptr("AWS Lambda")},
+			{LogicalName: ptr("awsecs"), Name: ptr("AWS ECS")},
+			{LogicalName: ptr("gcal"), Name: ptr("Google Calendar")},
+		},
+	}}
+
+	tests := []struct {
+		name   string
+		search string
+		want   string
+	}{
+		{name: "matches the name ignoring case", search: "lambda", want: `[{"label":"AWS Lambda","value":"awslambda"}]`},
+		{name: "matches the logical name", search: "AWSE", want: `[{"label":"AWS ECS","value":"awsecs"}]`},
+		{name: "returns every monitor for an empty search", search: "", want: `[{"label":"AWS Lambda","value":"awslambda"},{"label":"AWS ECS","value":"awsecs"},{"label":"Google Calendar","value":"gcal"}]`},
+		{name: "returns an empty list when nothing matches", search: "azure", want: `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResourceMonitorSearch(context.Background(), client, tt.search, nil, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got.Body) != tt.want {
+				t.Errorf("ResourceMonitorSearch() = %s, want %s", got.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceStatusBadge(t *testing.T) {
+	changes := func(statuses ...string) *stubClient {
+		entries := make([]internal.StatusPageComponentChange, len(statuses))
+		for i, status := range statuses {
+			entries[i] = internal.StatusPageComponentChange{
+				Component:          ptr(fmt.Sprintf("component%d", i)),
+				MonitorLogicalName: ptr("awslambda"),
+				Status:             ptr(status),
+				Timestamp:          ptr(time.Now().Add(-time.Hour).Format(time.RFC3339)),
+			}
+		}
+		return &stubClient{statusPageResponse: internal.BackendWebStatusPageChangeControllerGetResponse{
+			JSON200: &internal.StatusPageChangesResponse{Entries: &entries, Metadata: &internal.PagingMetadata{}},
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		client      *stubClient
+		palette     map[string]string
+		values      url.Values
+		wantColor   string
+		wantText    string
+		wantLabel   string
+		wantMissing string
+	}{
+		{name: "up", client: changes("operational"), values: url.Values{"monitor": {"awslambda"}}, wantColor: statusColorUp, wantText: "up", wantLabel: "awslambda"},
+		{name: "maintenance", client: changes("under_maintenance"), values: url.Values{"monitor": {"awslambda"}}, wantColor: statusColorMaintenance, wantText: "maintenance", wantLabel: "awslambda"},
+		{name: "degraded", client: changes("degraded"), values: url.Values{"monitor": {"awslambda"}}, wantColor: statusColorDegraded, wantText: "degraded", wantLabel: "awslambda"},
+		{name: "error", client: changes("major_outage"), values: url.Values{"monitor": {"awslambda"}}, wantColor: statusColorError, wantText: "error", wantLabel: "awslambda"},
+		{name: "unknown without changes", client: changes(), values: url.Values{"monitor": {"awslambda"}}, wantColor: statusColorUnknown, wantText: "unknown", wantLabel: "awslambda"},
+		{name: "most severe component", client: changes("operational", "major_outage", "under_maintenance"), values: url.Values{"monitor": {"awslambda"}}, wantColor: statusColorError, wantText: "error", wantLabel: "awslambda"},
+		{name: "single component", client: changes("operational", "major_outage"), values: url.Values{"monitor": {"awslambda"}, "component": {"component0"}}, wantColor: statusColorUp, wantText: "up", wantLabel: "component0", wantMissing: statusColorError},
+		{name: "palette", client: changes("major_outage"), palette: map[string]string{statusColorError: "#d62728"}, values: url.Values{"monitor": {"awslambda"}}, wantColor: "#d62728", wantText: "error", wantLabel: "awslambda", wantMissing: statusColorError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResourceStatusBadge(context.Background(), tt.client, tt.palette, tt.values, log.DefaultLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != http.StatusOK {
+				t.Fatalf("status = %d, want %d", got.Status, http.StatusOK)
+			}
+			if contentType := got.Headers["Content-Type"]; len(contentType) != 1 || contentType[0] != "image/svg+xml" {
+				t.Errorf("Content-Type = %v, want image/svg+xml", contentType)
+			}
+			body := string(got.Body)
+			for _, want := range []string{`fill="` + tt.wantColor + `"`, ">" + tt.wantText + "<", ">" + tt.wantLabel + "<"} {
+				if !strings.Contains(body, want) {
+					t.Errorf("expected the badge to contain %s, got %s", want, body)
+				}
+			}
+			if tt.wantMissing != "" && strings.Contains(body, tt.wantMissing) {
+				t.Errorf("expected the badge not to contain %s, got %s", tt.wantMissing, body)
+			}
+		})
+	}
+
+	got, err := ResourceStatusBadge(context.Background(), changes(), nil, url.Values{}, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != http.StatusBadRequest {
+		t.Errorf("expected a bad request without a monitor, got %d", got.Status)
+	}
+}
+
+func TestStatusBadgeEscapesText(t *testing.T) {
+	badge := string(statusBadge("<script>", "up", statusColorUp))
+	if strings.Contains(badge, "<script>") {
+		t.Errorf("expected the label to be escaped, got %s", badge)
+	}
+}
@@ -26,6 +26,8 @@ type stubClient struct {
 	monitorListResponse internal.BackendWebMonitorListControllerGetResponse
 	checksResponse      internal.BackendWebMonitorCheckControllerGetResponse
 	instancesResponse   internal.BackendWebMonitorInstanceControllerGetResponse
+	verifyAuthResponse  internal.BackendWebVerifyAuthControllerGetResponse
+	statusResponse      internal.BackendWebMonitorStatusControllerGetResponse
 }
 
 func (m *stubClient) BackendWebMonitorTelemetryControllerGetWithResponse(ctx context.Context,
@@ -62,3 +64,23 @@ func (m *stubClient) BackendWebMonitorInstanceControllerGetWithResponse(ctx cont
 	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorInstanceControllerGetResponse, error) {
 	return &m.instancesResponse, m.err
 }
+
+func (m *stubClient) BackendWebMonitorStatusControllerGetWithResponse(ctx context.Context,
+	params *internal.BackendWebMonitorStatusControllerGetParams,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebMonitorStatusControllerGetResponse, error) {
+	return &m.statusResponse, m.err
+}
+
+func (m *stubClient) BackendWebVerifyAuthControllerGetWithResponse(ctx context.Context,
+	reqEditors ...internal.RequestEditorFn) (*internal.BackendWebVerifyAuthControllerGetResponse, error) {
+	return &m.verifyAuthResponse, m.err
+}
+
+type stubSender struct {
+	responses []*backend.CallResourceResponse
+}
+
+func (s *stubSender) Send(resp *backend.CallResourceResponse) error {
+	s.responses = append(s.responses, resp)
+	return nil
+}
@@ -1,7 +1,170 @@
 package plugin
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/Metrist-Software/metrist-grafana-datasource/pkg/internal"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// datasourceSettings holds the datasource JSONData configuration
+type datasourceSettings struct {
+	// SkipTlsVerify disables TLS verification. Only honored for local builds
+	SkipTlsVerify bool `json:"skipTlsVerify"`
+	// MaxIdleConns limits the number of idle keep-alive connections to Metrist
+	MaxIdleConns int `json:"maxIdleConns"`
+	// MaxConnsPerHost limits the number of concurrent connections to Metrist
+	MaxConnsPerHost int `json:"maxConnsPerHost"`
+	// RequestTimeoutSeconds is the timeout of a single request to Metrist
+	RequestTimeoutSeconds int `json:"requestTimeoutSeconds"`
+	// RequestsPerSecond throttles requests to Metrist, unlimited when unset
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	// DefaultIncludeShared is applied to queries which do not set includeShared
+	DefaultIncludeShared bool `json:"defaultIncludeShared"`
+	// Debug enables the debugging resources, such as RawQuery
+	Debug bool `json:"debug"`
+	// The status colors replace the default colors of the status page value mappings and badges, e.g. for colorblind
+	// friendly palettes. They take any color Grafana understands, such as a name or a hex code
+	StatusColorUp          string `json:"statusColorUp"`
+	StatusColorDegraded    string `json:"statusColorDegraded"`
+	StatusColorError       string `json:"statusColorError"`
+	StatusColorMaintenance string `json:"statusColorMaintenance"`
+	// Environments maps environment names to additional Metrist endpoints, which queries select with their
+	// environment field. Queries without an environment use the endpoint the plugin was built for
+	Environments map[string]string `json:"environments"`
+	// AuthHeaderName is the header the API key is sent in, Authorization when unset
+	AuthHeaderName string `json:"authHeaderName"`
+	// AuthScheme prefixes the API key in the header, e.g. "Bearer". The key is sent bare when unset
+	AuthScheme string `json:"authScheme"`
+	// QueryCacheTTLSeconds caches query responses for this long, so identical queries are only fetched once.
+	// Responses are not cached when unset
+	QueryCacheTTLSeconds int `json:"queryCacheTtlSeconds"`
+	// PageSize is the number of entries requested per page from the paginated endpoints. Larger pages take
+	// fewer round trips but longer each. The upstream default is used when unset
+	PageSize int `json:"pageSize"`
+	// ResourceFanOut requests the checks and instances of each monitor concurrently for the Checks and Instances
+	// resources, rather than those of every selected monitor in a single request
+	ResourceFanOut bool `json:"resourceFanOut"`
+	// LogLevel is the lowest level, one of debug, info, warn and error, the datasource logs at. Every line is
+	// logged when unset
+	LogLevel string `json:"logLevel"`
+	// MonitorAliases are display names by monitor logical name, used instead of the monitor names of the API
+	MonitorAliases map[string]string `json:"monitorAliases"`
+	// RetentionDays is the longest time range, in days, a query or export may span. 90 days when unset. The API
+	// only keeps 90 days of data, so longer retentions are clamped to 90 days
+	RetentionDays int `json:"retentionDays"`
+	// RequiredMonitors are the logical names of the monitors the API key must give access to. The health check
+	// names the ones it can't access
+	RequiredMonitors []string `json:"requiredMonitors"`
+}
+
+// withMonitorAliases returns names, the monitor display names by logical name, with the configured aliases
+// taking precedence. names is left as is
+func (s datasourceSettings) withMonitorAliases(names map[string]string) map[string]string {
+	if len(s.MonitorAliases) == 0 {
+		return names
+	}
+	aliased := make(map[string]string, len(names)+len(s.MonitorAliases))
+	for monitor, name := range names {
+		aliased[monitor] = name
+	}
+	for monitor, alias := range s.MonitorAliases {
+		aliased[monitor] = alias
+	}
+	return aliased
+}
+
+// timeRangeLimit returns the longest time range a query may span, durationThreeMonths unless a shorter
+// RetentionDays is set. It never exceeds durationThreeMonths, the bound ensureTelemetryRequestWithinLast90Days
+// and the status page lookbacks rely on
+func (s datasourceSettings) timeRangeLimit() time.Duration {
+	limit := time.Duration(s.RetentionDays) * 24 * time.Hour
+	if s.RetentionDays <= 0 || limit > durationThreeMonths {
+		return durationThreeMonths
+	}
+	return limit
+}
+
+// logLevel returns the configured log level, log.NoLevel when unset
+func (s datasourceSettings) logLevel() (log.Level, error) {
+	if s.LogLevel == "" {
+		return log.NoLevel, nil
+	}
+	level, ok := logLevels[strings.ToLower(s.LogLevel)]
+	if !ok {
+		return log.NoLevel, fmt.Errorf("unknown log level %q", s.LogLevel)
+	}
+	return level, nil
+}
+
+// authHeaderName returns the configured name of the API key header, defaultAuthHeaderName when unset
+func (s datasourceSettings) authHeaderName() string {
+	if s.AuthHeaderName == "" {
+		return defaultAuthHeaderName
+	}
+	return s.AuthHeaderName
+}
+
+// statusPalette maps the default status colors to the configured ones, leaving out the unset colors
+func (s datasourceSettings) statusPalette() map[string]string {
+	palette := make(map[string]string)
+	for defaultColor, color := range map[string]string{
+		statusColorUp:          s.StatusColorUp,
+		statusColorDegraded:    s.StatusColorDegraded,
+		statusColorError:       s.StatusColorError,
+		statusColorMaintenance: s.StatusColorMaintenance,
+	} {
+		if color != "" {
+			palette[defaultColor] = color
+		}
+	}
+	return palette
+}
+
+const (
+	queryTypeMonitorErrors            = "GetMonitorErrors"
+	queryTypeMonitorTelemetry         = "GetMonitorTelemetry"
+	queryTypeMonitorStatusPageChanges = "GetMonitorStatusPageChanges"
+	queryTypeTopErrors                = "GetTopErrors"
+	queryTypeMonitorOverview          = "GetMonitorOverview"
+	queryTypeSloBurnRate              = "GetSloBurnRate"
+	queryTypeErrorRatio               = "GetErrorRatio"
+	queryTypeInstanceCount            = "GetInstanceCount"
+	queryTypeLastSeen                 = "GetLastSeen"
+	queryTypeStatusPageAvailability   = "GetStatusPageAvailability"
+	queryTypeTelemetryHistogram       = "GetTelemetryHistogram"
+	queryTypeErroringCheckCount       = "GetErroringCheckCount"
+	queryTypeInstanceAvailability     = "GetInstanceAvailability"
+)
+
+// queryTypes lists the supported query types, in the order they are shown in the query editor
+var queryTypes = selectOptions{
+	{Label: "Errors", Value: queryTypeMonitorErrors},
+	{Label: "Telemetry", Value: queryTypeMonitorTelemetry},
+	{Label: "Status Page Changes", Value: queryTypeMonitorStatusPageChanges},
+	{Label: "Top Errors", Value: queryTypeTopErrors},
+	{Label: "Overview", Value: queryTypeMonitorOverview},
+	{Label: "SLO Burn Rate", Value: queryTypeSloBurnRate},
+	{Label: "Error Ratio", Value: queryTypeErrorRatio},
+	{Label: "Instance Count", Value: queryTypeInstanceCount},
+	{Label: "Last Seen", Value: queryTypeLastSeen},
+	{Label: "Status Page Availability", Value: queryTypeStatusPageAvailability},
+	{Label: "Telemetry Histogram", Value: queryTypeTelemetryHistogram},
+	{Label: "Erroring Check Count", Value: queryTypeErroringCheckCount},
+	{Label: "Instance Availability", Value: queryTypeInstanceAvailability},
+}
+
 type queryModel struct {
-	QueryType string `json:"queryType"`
+	QueryType string   `json:"queryType"`
+	Monitors  []string `json:"monitors"`
+	// Environment selects one of the datasource environments to query instead of the default endpoint
+	Environment string `json:"environment"`
+	// Limit caps the total number of rows returned across all frames of the query, unlimited when unset
+	Limit int `json:"limit"`
 }
 
 // Right now our query editor share most of the fields
@@ -11,8 +174,103 @@ type monitorTelemetryQuery struct {
 	Monitors      []string  `json:"monitors"`
 	Checks        *[]string `json:"checks"`
 	Instances     *[]string `json:"instances"`
-	IncludeShared bool      `json:"includeshared"`
+	IncludeShared *bool     `json:"includeshared"`
 	FromAlerting  bool      `json:"fromalerting"`
+	GroupBy       []string  `json:"groupby"`
+	FillMissing   bool      `json:"fillmissing"`
+	SortOrder     string    `json:"sortorder"`
+	TopN          int       `json:"topn"`
+	Heatmap       bool      `json:"heatmap"`
+	LastN         int       `json:"lastn"`
+	TableFormat   string    `json:"tableformat"`
+	// FromOverride and ToOverride are RFC3339 timestamps which replace the dashboard time range when set
+	FromOverride string `json:"fromoverride"`
+	ToOverride   string `json:"tooverride"`
+	// SloTarget is the availability objective in percent, e.g. 99.9, used by the burn rate query
+	SloTarget float64 `json:"slotarget"`
+	// Window is the duration, e.g. "1h", over which the burn rate is computed. Defaults to the query interval
+	Window string `json:"window"`
+	// Shards splits the errors time range into this many sub-ranges, at most maxShards, which are paged concurrently
+	Shards int `json:"shards"`
+	// Timezone is the IANA time zone, e.g. "Europe/Amsterdam", aggregation buckets are aligned in
+	Timezone string `json:"timezone"`
+	// BinaryStatus collapses status page statuses into 1 when up and 0 otherwise
+	BinaryStatus bool `json:"binarystatus"`
+	// StatusText adds the upstream status wording as a status_text column of the status page table, or as a
+	// status_text label on the latest status when alerting
+	StatusText bool `json:"statustext"`
+	// MaxResults caps the number of error entries fetched, defaultMaxResults when unset
+	MaxResults int `json:"maxresults"`
+	// Component restricts status page queries to the changes of a single component
+	Component string `json:"component"`
+	// SeriesName prefixes the value field name, e.g. "prod errors" names the errors field "prod errors count"
+	SeriesName string `json:"seriesname"`
+	// MinCount drops error counts below this threshold from error queries
+	MinCount int `json:"mincount"`
+	// MaxSeries caps the number of graph series, defaultMaxSeries when unset
+	MaxSeries int `json:"maxseries"`
+	// AggregateInstances merges the instances of a monitor check into a single series, summing their error counts
+	// and averaging their response times
+	AggregateInstances bool `json:"aggregateinstances"`
+	// ComponentWeights weighs the availability of status page components by name, components left out weigh 1
+	ComponentWeights map[string]float64 `json:"componentweights"`
+	// WithBands aggregates telemetry series into buckets, adding min and max fields Grafana can render as a band
+	WithBands bool `json:"withbands"`
+	// The exclusions drop the entries of these monitors, checks and instances once they are fetched
+	ExcludeMonitors  []string `json:"excludemonitors"`
+	ExcludeChecks    []string `json:"excludechecks"`
+	ExcludeInstances []string `json:"excludeinstances"`
+	// Reducer reduces each telemetry and errors series of alerting queries to a single value, e.g. "last". The
+	// series are returned whole when unset
+	Reducer string `json:"reducer"`
+	// Labels selects which of the instance, check and monitor labels graph series keep, all of them when unset.
+	// Series left with the same labels are merged at each timestamp, summing error counts and averaging response
+	// times
+	Labels []string `json:"labels"`
+	// CompareOffset is the duration, e.g. "168h", a previous period series of telemetry and errors queries is
+	// shifted back by. No previous period is fetched when unset
+	CompareOffset string `json:"compareoffset"`
+	// Cumulative turns the error counts of graph series into a running total over the time range
+	Cumulative bool `json:"cumulative"`
+	// ComponentGroups maps status page component names to a group, e.g. "API", whose components are rolled up into
+	// a single component holding their worst status. Components left out are returned as is
+	ComponentGroups map[string]string `json:"componentgroups"`
+	// HistogramBuckets are the increasing bounds, in ms, of the telemetry histogram bins, defaultHistogramBuckets
+	// when unset
+	HistogramBuckets []float64 `json:"histogrambuckets"`
+	// SortByValue orders the rows of table frames by their value, asc or desc, rather than by time
+	SortByValue string `json:"sortbyvalue"`
+}
+
+func (q monitorTelemetryQuery) includeShared() bool {
+	return q.IncludeShared != nil && *q.IncludeShared
+}
+
+// excludes returns whether frameData belongs to one of the excluded monitors, checks or instances
+func (q monitorTelemetryQuery) excludes(frameData internal.FrameData) bool {
+	labels := internal.GetLabels(frameData)
+	return slices.Contains(q.ExcludeMonitors, labels["monitor"]) ||
+		slices.Contains(q.ExcludeChecks, labels["check"]) ||
+		slices.Contains(q.ExcludeInstances, labels["instance"])
+}
+
+func (q monitorTelemetryQuery) maxResults() int64 {
+	if q.MaxResults <= 0 {
+		return defaultMaxResults
+	}
+	return int64(q.MaxResults)
+}
+
+// tableFrameType returns the frame type used for the table frames, wide unless the long format was requested
+func (q monitorTelemetryQuery) tableFrameType() frameType {
+	if q.TableFormat == tableFormatLong {
+		return LongTableFrameType
+	}
+	return TableFrameType
+}
+
+func (q monitorTelemetryQuery) frameOptions() frameOptions {
+	return frameOptions{groupBy: q.GroupBy, seriesName: q.SeriesName, maxSeries: q.MaxSeries, aggregateInstances: q.AggregateInstances, labels: q.Labels}
 }
 
 type selectOption struct {
@@ -1,5 +1,7 @@
 package plugin
 
+import "container/heap"
+
 func uniqStrings(xs []string) []string {
 	set := make(map[string]bool)
 	for _, x := range xs {
@@ -14,3 +16,66 @@ func uniqStrings(xs []string) []string {
 	}
 	return res
 }
+
+// mergeSorted merges slices which are each already sorted by less into a single sorted slice. Equal items
+// keep the order of their slices, so the result matches a stable sort of the concatenated slices
+func mergeSorted[T any](sorted [][]T, less func(a, b T) bool) []T {
+	h := &mergeHeap[T]{sorted: sorted, less: less}
+	total := 0
+	for i, s := range sorted {
+		total += len(s)
+		if len(s) > 0 {
+			h.cursors = append(h.cursors, mergeCursor{slice: i})
+		}
+	}
+	heap.Init(h)
+
+	merged := make([]T, 0, total)
+	for h.Len() > 0 {
+		cursor := &h.cursors[0]
+		merged = append(merged, sorted[cursor.slice][cursor.pos])
+		if cursor.pos++; cursor.pos < len(sorted[cursor.slice]) {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return merged
+}
+
+// mergeCursor is the position of the next item to merge from one of the sorted slices
+type mergeCursor struct {
+	slice int
+	pos   int
+}
+
+// mergeHeap orders the cursors of mergeSorted by their next item
+type mergeHeap[T any] struct {
+	sorted  [][]T
+	cursors []mergeCursor
+	less    func(a, b T) bool
+}
+
+func (h *mergeHeap[T]) Len() int { return len(h.cursors) }
+
+func (h *mergeHeap[T]) Less(i, j int) bool {
+	a, b := h.cursors[i], h.cursors[j]
+	x, y := h.sorted[a.slice][a.pos], h.sorted[b.slice][b.pos]
+	if h.less(x, y) {
+		return true
+	}
+	if h.less(y, x) {
+		return false
+	}
+	return a.slice < b.slice
+}
+
+func (h *mergeHeap[T]) Swap(i, j int) { h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i] }
+
+func (h *mergeHeap[T]) Push(x any) { h.cursors = append(h.cursors, x.(mergeCursor)) }
+
+func (h *mergeHeap[T]) Pop() any {
+	last := h.cursors[len(h.cursors)-1]
+	h.cursors = h.cursors[:len(h.cursors)-1]
+	return last
+}
@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Metrist-Software/metrist-grafana-datasource/pkg/internal"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMergeSorted(t *testing.T) {
+	type item struct {
+		key   int
+		slice string
+	}
+	less := func(a, b item) bool { return a.key < b.key }
+
+	tests := []struct {
+		name   string
+		sorted [][]item
+		want   []item
+	}{
+		{
+			name:   "interleaves the slices",
+			sorted: [][]item{{{1, "a"}, {4, "a"}, {6, "a"}}, {{2, "b"}, {3, "b"}}, {{5, "c"}}},
+			want:   []item{{1, "a"}, {2, "b"}, {3, "b"}, {4, "a"}, {5, "c"}, {6, "a"}},
+		},
+		{
+			name:   "keeps equal items in slice order",
+			sorted: [][]item{{{1, "a"}, {2, "a"}}, {{1, "b"}, {2, "b"}}, {{1, "c"}}},
+			want:   []item{{1, "a"}, {1, "b"}, {1, "c"}, {2, "a"}, {2, "b"}},
+		},
+		{
+			name:   "skips empty slices",
+			sorted: [][]item{nil, {{1, "b"}}, {}},
+			want:   []item{{1, "b"}},
+		},
+		{
+			name:   "returns an empty slice without input",
+			sorted: nil,
+			want:   []item{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeSorted(tt.sorted, less)
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(item{})); diff != "" {
+				t.Errorf("mergeSorted() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMergeSortedMatchesStableSort(t *testing.T) {
+	sorted := randomErrorCountPages(rand.New(rand.NewSource(1)), 8, 500)
+
+	concatenated := make([]monitorErrorCount, 0)
+	for _, s := range sorted {
+		concatenated = append(concatenated, s...)
+	}
+	sort.SliceStable(concatenated, func(i, j int) bool { return errorCountBefore(concatenated[i], concatenated[j]) })
+
+	if diff := cmp.Diff(concatenated, mergeSorted(sorted, errorCountBefore), cmp.AllowUnexported(monitorErrorCount{})); diff != "" {
+		t.Errorf("merged output mismatch (-sorted +merged):\n%s", diff)
+	}
+}
+
+// randomErrorCountPages returns n time ordered slices of size error counts, with overlapping timestamps
+func randomErrorCountPages(r *rand.Rand, n int, size int) [][]monitorErrorCount {
+	from := strToTime("2022-12-07T00:00:00Z")
+	sorted := make([][]monitorErrorCount, n)
+	for i := range sorted {
+		offset := 0
+		for j := 0; j < size; j++ {
+			offset += r.Intn(3)
+			sorted[i] = append(sorted[i], monitorErrorCount{
+				MonitorErrorCount: internal.MonitorErrorCount{
+					Check:     ptr(fmt.Sprintf("check%d", i)),
+					Count:     ptr(j),
+					Timestamp: ptr(from.Add(time.Duration(offset) * time.Second).Format(time.RFC3339)),
+				},
+			})
+		}
+	}
+	return sorted
+}
+
+func BenchmarkMergeErrorCounts(b *testing.B) {
+	sorted := randomErrorCountPages(rand.New(rand.NewSource(1)), 16, 2000)
+
+	b.Run("stable sort", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			concatenated := make([]monitorErrorCount, 0)
+			for _, s := range sorted {
+				concatenated = append(concatenated, s...)
+			}
+			sort.SliceStable(concatenated, func(i, j int) bool { return errorCountBefore(concatenated[i], concatenated[j]) })
+		}
+	})
+	b.Run("k-way merge", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			mergeSorted(sorted, errorCountBefore)
+		}
+	})
+}